@@ -107,8 +107,12 @@ type hubOptions struct {
 	hubActionChanSize int
 	workerPoolSize    int
 	laneIdleTimeout   time.Duration
+	interceptors      []Interceptor
 }
 
+// Interceptor 事件拦截器，在 Post / Send 分发前执行，返回错误时事件不再分发
+type Interceptor func(ev Event) *cd.Error
+
 const defaultMaxPerLaneChanSize = 64
 const defaultMaxHubActionChanSize = 1024
 const defaultMaxWorkerPoolSize = 256
@@ -175,6 +179,17 @@ func WithWorkerPoolSize(size int) HubOption {
 	}
 }
 
+// WithInterceptor 添加事件拦截器，多个拦截器按添加顺序执行
+func WithInterceptor(interceptors ...Interceptor) HubOption {
+	return func(o *hubOptions) {
+		for _, val := range interceptors {
+			if val != nil {
+				o.interceptors = append(o.interceptors, val)
+			}
+		}
+	}
+}
+
 // WithLaneIdleTimeout 配置每个 lane 空闲多久后自动回收。
 func WithLaneIdleTimeout(timeout time.Duration) HubOption {
 	return func(o *hubOptions) {
@@ -261,6 +276,7 @@ func NewHubWithOptions(capacitySize int, opts ...HubOption) Hub {
 		laneKey2ActionChannel: LaneKey2ActionChannelMap{},
 		perLaneChanSize:       hubOpts.perLaneChanSize,
 		laneIdleTimeout:       hubOpts.laneIdleTimeout,
+		interceptors:          hubOpts.interceptors,
 		eventMatchCache:       map[string]ObserverList{},
 	}
 	go hub.run()
@@ -486,6 +502,7 @@ type hubImpl struct {
 
 	perLaneChanSize int
 	laneIdleTimeout time.Duration
+	interceptors    []Interceptor
 
 	// eventMatchCache 以 eventID 为 key 缓存匹配到的 ObserverList
 	// 仅作为加速读路径使用，订阅关系变更时整体失效
@@ -712,6 +729,10 @@ func (s *hubImpl) Post(ev Event) {
 	if ev == nil {
 		return
 	}
+	if err := s.intercept(ev); err != nil {
+		slog.Warn("post event rejected by interceptor", "event_id", ev.ID(), "source", ev.Source(), "destination", ev.Destination(), "error", err)
+		return
+	}
 
 	laneKey := eventLaneKey(ev)
 	if isReentrantLaneExecution(ev, laneKey) {
@@ -740,6 +761,27 @@ func (s *hubImpl) Post(ev Event) {
 	}
 }
 
+// intercept 依次执行拦截器，拦截器 panic 视为拒绝
+func (s *hubImpl) intercept(ev Event) (err *cd.Error) {
+	if len(s.interceptors) == 0 {
+		return nil
+	}
+
+	defer func() {
+		if info := recover(); info != nil {
+			slog.Warn("event interceptor exception", "event_id", ev.ID(), "panic", info)
+			err = cd.NewError(cd.Unexpected, fmt.Sprintf("%v", info))
+		}
+	}()
+
+	for _, val := range s.interceptors {
+		if err = val(ev); err != nil {
+			return
+		}
+	}
+	return nil
+}
+
 func (s *hubImpl) Send(ev Event) (ret Result) {
 	if s.terminateFlag.Load() {
 		return
@@ -749,6 +791,11 @@ func (s *hubImpl) Send(ev Event) (ret Result) {
 		result.Set(nil, cd.NewError(cd.IllegalParam, "event is nil"))
 		return result
 	}
+	if err := s.intercept(ev); err != nil {
+		result := NewResult(ev.ID(), ev.Source(), ev.Destination())
+		result.Set(nil, err)
+		return result
+	}
 
 	replay := make(chan Result, 1)
 	defer close(replay)
@@ -3,6 +3,8 @@ package event
 import (
 	"context"
 	"testing"
+
+	cd "github.com/muidea/magicCommon/def"
 )
 
 func TestDefaultHubOptionsCapControlPlaneBuffers(t *testing.T) {
@@ -44,3 +46,32 @@ func TestNewHubWithOptions(t *testing.T) {
 		t.Fatalf("handler should be called")
 	}
 }
+
+func TestHubInterceptorRejectsEvents(t *testing.T) {
+	hub := NewHubWithOptions(10, WithInterceptor(func(ev Event) *cd.Error {
+		if ev.Source() == "/blocked" {
+			return cd.NewError(cd.Forbidden, "blocked source")
+		}
+		return nil
+	}))
+	defer hub.Terminate(context.Background())
+
+	handler := &eventHandler{handlerID: "/intercept-handler"}
+	hub.Subscribe("/intercept-event", handler)
+
+	result := hub.Send(NewEvent("/intercept-event", "/blocked", handler.ID(), NewValues(), "data"))
+	if _, err := result.Get(); err == nil || err.Code != cd.Forbidden {
+		t.Fatalf("expected forbidden error, got %v", err)
+	}
+	if handler.handled {
+		t.Fatalf("handler should not be called for rejected event")
+	}
+
+	result = hub.Send(NewEvent("/intercept-event", "/allowed", handler.ID(), NewValues(), "data"))
+	if _, err := result.Get(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !handler.handled {
+		t.Fatalf("handler should be called for allowed event")
+	}
+}
@@ -18,16 +18,37 @@ type Execute struct {
 	queueLength   int
 	activeCount   int
 	capacitySize  int
+
+	hooks         Hooks
+	slowThreshold time.Duration
+	metrics       *executeMetrics
+	failures      *failureRing
 }
 
 func NewExecute(capacitySize int) Execute {
+	return NewExecuteWithOptions(capacitySize)
+}
+
+// NewExecuteWithOptions 创建带可选配置的 Execute，支持任务回调、慢任务检测和失败记录
+func NewExecuteWithOptions(capacitySize int, opts ...Option) Execute {
 	if capacitySize <= 0 {
 		capacitySize = 10
 	}
 
+	executeOpts := defaultOptions()
+	for _, opt := range opts {
+		if opt != nil {
+			opt(executeOpts)
+		}
+	}
+
 	return Execute{
 		capacitySize:  capacitySize,
 		capacityQueue: make(chan bool, capacitySize),
+		hooks:         executeOpts.hooks,
+		slowThreshold: executeOpts.slowThreshold,
+		metrics:       &executeMetrics{},
+		failures:      newFailureRing(executeOpts.failureHistorySize),
 	}
 }
 
@@ -36,15 +57,22 @@ func (s *Execute) Lock() { /* for noCopy */ }
 func (s *Execute) Unlock() { /* for noCopy */ }
 
 func (s *Execute) Run(funcPtr func()) {
+	s.RunTask(defaultTaskName, funcPtr)
+}
+
+// RunTask 以指定名称执行任务，名称会传递给 Hooks 并出现在失败记录中
+func (s *Execute) RunTask(name string, funcPtr func()) {
+	if name == "" {
+		name = defaultTaskName
+	}
+
 	s.mu.Lock()
-	if s.queueLength >= s.capacitySize {
-		s.mu.Unlock()
-		slog.Warn("execute queue is full, length:s.queueLength, capacity:s.capacitySize", "field", s.queueLength, "error", s.capacitySize)
-	} else if s.queueLength >= int(math.Floor(float64(s.capacitySize)*0.8)) {
-		s.mu.Unlock()
-		slog.Warn("queue lengths are at warning levels, length:s.queueLength, capacity:s.capacitySize", "field", s.queueLength, "error", s.capacitySize)
-	} else {
-		s.mu.Unlock()
+	queueLength := s.queueLength
+	s.mu.Unlock()
+	if queueLength >= s.capacitySize {
+		slog.Warn("execute queue is full, length:s.queueLength, capacity:s.capacitySize", "field", queueLength, "error", s.capacitySize)
+	} else if queueLength >= int(math.Floor(float64(s.capacitySize)*0.8)) {
+		slog.Warn("queue lengths are at warning levels, length:s.queueLength, capacity:s.capacitySize", "field", queueLength, "error", s.capacitySize)
 	}
 
 	s.capacityQueue <- true
@@ -53,11 +81,18 @@ func (s *Execute) Run(funcPtr func()) {
 	s.activeCount++
 	s.mu.Unlock()
 	go func() {
+		info := TaskInfo{Name: name, StartTime: time.Now()}
 		defer func() {
+			info.Duration = time.Since(info.StartTime)
 			if err := recover(); err != nil {
 				stackInfo := util.GetStack(3)
-				slog.Error("PANIC: err\nstackInfo", "field", err, "error", stackInfo)
+				slog.Error("PANIC: err\nstackInfo", "task", name, "field", err, "error", stackInfo)
+
+				info.Recovered = err
+				info.Stack = string(stackInfo)
+				s.onPanic(info)
 			}
+			s.onFinish(info)
 
 			<-s.capacityQueue
 			s.mu.Lock()
@@ -66,10 +101,63 @@ func (s *Execute) Run(funcPtr func()) {
 			s.mu.Unlock()
 		}()
 
+		s.onStart(info)
 		funcPtr()
 	}()
 }
 
+func (s *Execute) onStart(info TaskInfo) {
+	if s.metrics != nil {
+		s.metrics.started.Add(1)
+	}
+	invokeHook(s.hooks.OnStart, info)
+}
+
+func (s *Execute) onPanic(info TaskInfo) {
+	if s.metrics != nil {
+		s.metrics.panicked.Add(1)
+	}
+	if s.failures != nil {
+		s.failures.push(newTaskFailure(info))
+	}
+	invokeHook(s.hooks.OnPanic, info)
+}
+
+func (s *Execute) onFinish(info TaskInfo) {
+	if s.metrics != nil {
+		s.metrics.finished.Add(1)
+	}
+	if s.slowThreshold > 0 && info.Duration > s.slowThreshold {
+		if s.metrics != nil {
+			s.metrics.slow.Add(1)
+		}
+		invokeHook(s.hooks.OnSlow, info)
+	}
+	invokeHook(s.hooks.OnFinish, info)
+}
+
+// Stats 返回任务执行统计
+func (s *Execute) Stats() Stats {
+	s.mu.Lock()
+	active := s.activeCount
+	s.mu.Unlock()
+
+	if s.metrics == nil {
+		return Stats{Active: active}
+	}
+
+	return s.metrics.snapshot(active)
+}
+
+// RecentFailures 返回最近 panic 的任务记录，按时间从新到旧排列
+func (s *Execute) RecentFailures() []TaskFailure {
+	if s.failures == nil {
+		return []TaskFailure{}
+	}
+
+	return s.failures.list()
+}
+
 func (s *Execute) Wait() {
 	_ = s.WaitTimeout(defaultWaitTimeout)
 }
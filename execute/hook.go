@@ -0,0 +1,183 @@
+package execute
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/muidea/magicCommon/foundation/util"
+)
+
+const (
+	defaultTaskName           = "anonymous"
+	defaultFailureHistorySize = 32
+)
+
+// TaskInfo 描述一次任务执行，作为 Hook 的入参
+type TaskInfo struct {
+	Name      string
+	StartTime time.Time
+	Duration  time.Duration
+	// Recovered 仅在任务 panic 时非空
+	Recovered any
+	Stack     string
+}
+
+// TaskHook 任务执行回调
+type TaskHook func(info TaskInfo)
+
+// Hooks 任务生命周期回调，未设置的回调会被忽略。
+// 回调在任务所在 goroutine 中同步执行，回调自身的 panic 会被吞掉并记录日志。
+type Hooks struct {
+	OnStart  TaskHook
+	OnFinish TaskHook
+	OnPanic  TaskHook
+	OnSlow   TaskHook
+}
+
+// Option Execute 配置项
+type Option func(*options)
+
+type options struct {
+	hooks              Hooks
+	slowThreshold      time.Duration
+	failureHistorySize int
+}
+
+func defaultOptions() *options {
+	return &options{
+		failureHistorySize: defaultFailureHistorySize,
+	}
+}
+
+// WithHooks 配置任务生命周期回调
+func WithHooks(hooks Hooks) Option {
+	return func(o *options) {
+		o.hooks = hooks
+	}
+}
+
+// WithSlowThreshold 配置慢任务阈值，任务执行时间超过阈值时触发 OnSlow。
+// 非正数表示不做慢任务检测。
+func WithSlowThreshold(threshold time.Duration) Option {
+	return func(o *options) {
+		o.slowThreshold = threshold
+	}
+}
+
+// WithFailureHistorySize 配置最近失败记录的保留条数
+func WithFailureHistorySize(size int) Option {
+	return func(o *options) {
+		if size > 0 {
+			o.failureHistorySize = size
+		}
+	}
+}
+
+// Stats Execute 运行统计
+type Stats struct {
+	Active   int
+	Started  int64
+	Finished int64
+	Panicked int64
+	Slow     int64
+}
+
+type executeMetrics struct {
+	started  atomic.Int64
+	finished atomic.Int64
+	panicked atomic.Int64
+	slow     atomic.Int64
+}
+
+func (s *executeMetrics) snapshot(active int) Stats {
+	return Stats{
+		Active:   active,
+		Started:  s.started.Load(),
+		Finished: s.finished.Load(),
+		Panicked: s.panicked.Load(),
+		Slow:     s.slow.Load(),
+	}
+}
+
+// TaskFailure 一次任务 panic 的记录
+type TaskFailure struct {
+	Name      string        `json:"name"`
+	Time      time.Time     `json:"time"`
+	Duration  time.Duration `json:"duration"`
+	Recovered string        `json:"recovered"`
+	Stack     string        `json:"stack,omitempty"`
+}
+
+// failureRing 固定容量的最近失败记录，写满后覆盖最旧的记录
+type failureRing struct {
+	mu    sync.Mutex
+	items []TaskFailure
+	next  int
+	full  bool
+}
+
+func newFailureRing(size int) *failureRing {
+	if size <= 0 {
+		size = defaultFailureHistorySize
+	}
+
+	return &failureRing{items: make([]TaskFailure, size)}
+}
+
+func (s *failureRing) push(failure TaskFailure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items[s.next] = failure
+	s.next++
+	if s.next == len(s.items) {
+		s.next = 0
+		s.full = true
+	}
+}
+
+// list 按时间从新到旧返回记录
+func (s *failureRing) list() []TaskFailure {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := s.next
+	if s.full {
+		count = len(s.items)
+	}
+
+	ret := make([]TaskFailure, 0, count)
+	for idx := 0; idx < count; idx++ {
+		pos := (s.next - 1 - idx + len(s.items)) % len(s.items)
+		ret = append(ret, s.items[pos])
+	}
+
+	return ret
+}
+
+func invokeHook(hook TaskHook, info TaskInfo) {
+	if hook == nil {
+		return
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			slog.Error("execute hook panic", "task", info.Name, "panic", err, "stack", string(util.GetStack(3)))
+		}
+	}()
+
+	hook(info)
+}
+
+func newTaskFailure(info TaskInfo) TaskFailure {
+	return TaskFailure{
+		Name:      info.Name,
+		Time:      info.StartTime.Add(info.Duration),
+		Duration:  info.Duration,
+		Recovered: fmt.Sprintf("%v", info.Recovered),
+		Stack:     info.Stack,
+	}
+}
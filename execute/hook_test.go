@@ -0,0 +1,152 @@
+package execute
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExecuteHooksReportPanicAndFinish(t *testing.T) {
+	var mu sync.Mutex
+	var started, finished, panicked []TaskInfo
+	exec := NewExecuteWithOptions(2, WithHooks(Hooks{
+		OnStart: func(info TaskInfo) {
+			mu.Lock()
+			started = append(started, info)
+			mu.Unlock()
+		},
+		OnFinish: func(info TaskInfo) {
+			mu.Lock()
+			finished = append(finished, info)
+			mu.Unlock()
+		},
+		OnPanic: func(info TaskInfo) {
+			mu.Lock()
+			panicked = append(panicked, info)
+			mu.Unlock()
+		},
+	}))
+
+	exec.RunTask("crashing-job", func() {
+		panic("boom")
+	})
+	if !exec.WaitTimeout(500 * time.Millisecond) {
+		t.Fatalf("expected task to drain")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(started) != 1 || len(finished) != 1 || len(panicked) != 1 {
+		t.Fatalf("unexpected hook calls, started:%d finished:%d panicked:%d", len(started), len(finished), len(panicked))
+	}
+	if panicked[0].Name != "crashing-job" || panicked[0].Recovered != "boom" {
+		t.Fatalf("unexpected panic info: %+v", panicked[0])
+	}
+	if panicked[0].Stack == "" {
+		t.Fatalf("expected panic stack to be captured")
+	}
+
+	stats := exec.Stats()
+	if stats.Started != 1 || stats.Finished != 1 || stats.Panicked != 1 || stats.Active != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	failures := exec.RecentFailures()
+	if len(failures) != 1 || failures[0].Name != "crashing-job" || failures[0].Recovered != "boom" {
+		t.Fatalf("unexpected failures: %+v", failures)
+	}
+}
+
+func TestExecuteSlowHook(t *testing.T) {
+	slowCh := make(chan TaskInfo, 1)
+	exec := NewExecuteWithOptions(1,
+		WithSlowThreshold(10*time.Millisecond),
+		WithHooks(Hooks{
+			OnSlow: func(info TaskInfo) {
+				slowCh <- info
+			},
+		}),
+	)
+
+	exec.RunTask("fast-job", func() {})
+	exec.RunTask("slow-job", func() {
+		time.Sleep(30 * time.Millisecond)
+	})
+
+	select {
+	case info := <-slowCh:
+		if info.Name != "slow-job" || info.Duration < 10*time.Millisecond {
+			t.Fatalf("unexpected slow info: %+v", info)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatalf("expected slow hook to fire")
+	}
+
+	exec.WaitTimeout(500 * time.Millisecond)
+	if exec.Stats().Slow != 1 {
+		t.Fatalf("expected one slow task, got %d", exec.Stats().Slow)
+	}
+}
+
+func TestExecuteRecentFailuresKeepsNewest(t *testing.T) {
+	exec := NewExecuteWithOptions(1, WithFailureHistorySize(2))
+	for _, name := range []string{"first", "second", "third"} {
+		exec.RunTask(name, func() {
+			panic(name)
+		})
+		exec.WaitTimeout(500 * time.Millisecond)
+	}
+
+	failures := exec.RecentFailures()
+	if len(failures) != 2 {
+		t.Fatalf("expected 2 failures, got %d", len(failures))
+	}
+	if failures[0].Name != "third" || failures[1].Name != "second" {
+		t.Fatalf("unexpected failure order: %s, %s", failures[0].Name, failures[1].Name)
+	}
+}
+
+func TestExecuteHookPanicDoesNotBreakExecutor(t *testing.T) {
+	exec := NewExecuteWithOptions(1, WithHooks(Hooks{
+		OnStart: func(TaskInfo) {
+			panic("hook failure")
+		},
+	}))
+
+	done := make(chan struct{})
+	exec.RunTask("job", func() {
+		close(done)
+	})
+
+	select {
+	case <-done:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatalf("expected task to run despite hook panic")
+	}
+	if !exec.WaitTimeout(500 * time.Millisecond) {
+		t.Fatalf("expected executor to drain")
+	}
+}
+
+func TestMetricProviderCollectsStats(t *testing.T) {
+	exec := NewExecute(1)
+	exec.RunTask("job", func() {})
+	exec.WaitTimeout(500 * time.Millisecond)
+
+	provider := NewMetricProvider("background", &exec)
+	metrics, err := provider.Collect()
+	if err != nil {
+		t.Fatalf("collect failed: %v", err)
+	}
+	if len(metrics) != len(provider.Metrics()) {
+		t.Fatalf("expected %d metrics, got %d", len(provider.Metrics()), len(metrics))
+	}
+	for _, val := range metrics {
+		if val.Labels["executor"] != "background" {
+			t.Fatalf("unexpected labels: %v", val.Labels)
+		}
+		if val.Name == metricTasksFinished && val.Value != 1 {
+			t.Fatalf("expected one finished task, got %v", val.Value)
+		}
+	}
+}
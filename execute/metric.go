@@ -0,0 +1,56 @@
+package execute
+
+import (
+	cd "github.com/muidea/magicCommon/def"
+	"github.com/muidea/magicCommon/monitoring/types"
+)
+
+const (
+	metricTasksStarted  = "execute_tasks_started_total"
+	metricTasksFinished = "execute_tasks_finished_total"
+	metricTasksPanicked = "execute_tasks_panicked_total"
+	metricTasksSlow     = "execute_tasks_slow_total"
+	metricTasksActive   = "execute_tasks_active"
+)
+
+type metricProvider struct {
+	*types.BaseProvider
+	executor string
+	execute  *Execute
+}
+
+// NewMetricProvider 将 Execute 的运行统计暴露给 monitoring，executor 作为指标标签区分不同的执行器
+func NewMetricProvider(executor string, execute *Execute) types.MetricProvider {
+	return &metricProvider{
+		BaseProvider: types.NewBaseProvider("execute_"+executor, "1.0.0", "execute task metrics"),
+		executor:     executor,
+		execute:      execute,
+	}
+}
+
+func (s *metricProvider) Metrics() []types.MetricDefinition {
+	labelNames := []string{"executor"}
+	return []types.MetricDefinition{
+		types.NewCounterDefinition(metricTasksStarted, "Total number of started tasks", labelNames, nil),
+		types.NewCounterDefinition(metricTasksFinished, "Total number of finished tasks", labelNames, nil),
+		types.NewCounterDefinition(metricTasksPanicked, "Total number of panicked tasks", labelNames, nil),
+		types.NewCounterDefinition(metricTasksSlow, "Total number of tasks exceeding slow threshold", labelNames, nil),
+		types.NewGaugeDefinition(metricTasksActive, "Number of running tasks", labelNames, nil),
+	}
+}
+
+func (s *metricProvider) Collect() ([]types.Metric, *cd.Error) {
+	if s.execute == nil {
+		return []types.Metric{}, nil
+	}
+
+	stats := s.execute.Stats()
+	labels := map[string]string{"executor": s.executor}
+	return []types.Metric{
+		types.NewCounter(metricTasksStarted, float64(stats.Started), labels),
+		types.NewCounter(metricTasksFinished, float64(stats.Finished), labels),
+		types.NewCounter(metricTasksPanicked, float64(stats.Panicked), labels),
+		types.NewCounter(metricTasksSlow, float64(stats.Slow), labels),
+		types.NewGauge(metricTasksActive, float64(stats.Active), labels),
+	}, nil
+}
@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// Entry 缓存条目，CacheTime 为最近一次写入或访问刷新的时间
+type Entry[V any] struct {
+	Value     V
+	MaxAge    int64
+	CacheTime time.Time
+}
+
+func (s Entry[V]) expired(now time.Time) bool {
+	if s.MaxAge == ForeverAgeValue {
+		return false
+	}
+	return int64(now.Sub(s.CacheTime).Seconds()) > s.MaxAge
+}
+
+// Backend 缓存存储层，缓存对象负责过期、容量和统计，Backend 只负责保存条目。
+// Range 回调中不能调用同一个 Backend 的写方法。
+type Backend[K comparable, V any] interface {
+	Load(key K) (Entry[V], bool)
+	Store(key K, entry Entry[V]) error
+	Delete(key K) error
+	// Touch 刷新条目的访问时间
+	Touch(key K, cacheTime time.Time)
+	Range(fn func(key K, entry Entry[V]) bool)
+	Len() int
+	Clear() error
+	Close() error
+}
+
+// memoryShardCount MemoryBackend 分片数量，必须为 2 的幂
+const memoryShardCount = 32
+
+type memoryShard[K comparable, V any] struct {
+	mu   sync.RWMutex
+	data map[K]*Entry[V]
+}
+
+// MemoryBackend 进程内存储，按键的哈希分片，不同分片的读写互不阻塞
+type MemoryBackend[K comparable, V any] struct {
+	seed   maphash.Seed
+	shards [memoryShardCount]memoryShard[K, V]
+}
+
+// NewMemoryBackend 创建进程内存储
+func NewMemoryBackend[K comparable, V any]() *MemoryBackend[K, V] {
+	backend := &MemoryBackend[K, V]{seed: maphash.MakeSeed()}
+	for idx := range backend.shards {
+		backend.shards[idx].data = map[K]*Entry[V]{}
+	}
+	return backend
+}
+
+func (s *MemoryBackend[K, V]) shard(key K) *memoryShard[K, V] {
+	return &s.shards[maphash.Comparable(s.seed, key)&(memoryShardCount-1)]
+}
+
+func (s *MemoryBackend[K, V]) Load(key K) (Entry[V], bool) {
+	shard := s.shard(key)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	entryPtr, ok := shard.data[key]
+	if !ok {
+		return Entry[V]{}, false
+	}
+	return *entryPtr, true
+}
+
+func (s *MemoryBackend[K, V]) Store(key K, entry Entry[V]) error {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	shard.data[key] = &entry
+	return nil
+}
+
+func (s *MemoryBackend[K, V]) Delete(key K) error {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	delete(shard.data, key)
+	return nil
+}
+
+func (s *MemoryBackend[K, V]) Touch(key K, cacheTime time.Time) {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if entryPtr, ok := shard.data[key]; ok {
+		entryPtr.CacheTime = cacheTime
+	}
+}
+
+// Range 逐个分片复制快照后回调，不保证所有分片处于同一时刻
+func (s *MemoryBackend[K, V]) Range(fn func(key K, entry Entry[V]) bool) {
+	for idx := range s.shards {
+		shard := &s.shards[idx]
+		shard.mu.RLock()
+		keys := make([]K, 0, len(shard.data))
+		entries := make([]Entry[V], 0, len(shard.data))
+		for k, v := range shard.data {
+			keys = append(keys, k)
+			entries = append(entries, *v)
+		}
+		shard.mu.RUnlock()
+
+		for idx := range keys {
+			if !fn(keys[idx], entries[idx]) {
+				return
+			}
+		}
+	}
+}
+
+func (s *MemoryBackend[K, V]) Len() int {
+	ret := 0
+	for idx := range s.shards {
+		shard := &s.shards[idx]
+		shard.mu.RLock()
+		ret += len(shard.data)
+		shard.mu.RUnlock()
+	}
+	return ret
+}
+
+func (s *MemoryBackend[K, V]) Clear() error {
+	for idx := range s.shards {
+		shard := &s.shards[idx]
+		shard.mu.Lock()
+		shard.data = map[K]*Entry[V]{}
+		shard.mu.Unlock()
+	}
+	return nil
+}
+
+func (s *MemoryBackend[K, V]) Close() error {
+	return nil
+}
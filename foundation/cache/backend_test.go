@@ -0,0 +1,260 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileBackendSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lookup.cache")
+
+	backend, err := NewFileBackend[string, any](path, nil)
+	if !assert.Nil(t, err) {
+		return
+	}
+	cache := NewKVCacheWithBackend(nil, backend, nil)
+	cache.Put("k1", "v1", ForeverAgeValue)
+	cache.Put("k2", "v2", ForeverAgeValue)
+	cache.Put("k3", "v3", 1)
+	cache.Remove("k2")
+	cache.Release()
+
+	backend, err = NewFileBackend[string, any](path, nil)
+	if !assert.Nil(t, err) {
+		return
+	}
+	cache = NewKVCacheWithBackend(nil, backend, nil)
+	defer cache.Release()
+	assert.Equal(t, "v1", cache.Fetch("k1"))
+	assert.Nil(t, cache.Fetch("k2"))
+	assert.Equal(t, "v3", cache.Fetch("k3"))
+	assert.Len(t, cache.GetAll(), 2)
+}
+
+func TestFileBackendTruncatesDamagedTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "damaged.cache")
+
+	backend, err := NewFileBackend[string, int](path, GobCodec{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Nil(t, backend.Store("k1", Entry[int]{Value: 1, MaxAge: ForeverAgeValue, CacheTime: time.Now()}))
+	assert.Nil(t, backend.Close())
+
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	_, _ = file.Write([]byte{0, 0, 1, 0, 'x'})
+	_ = file.Close()
+
+	backend, err = NewFileBackend[string, int](path, GobCodec{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer backend.Close()
+	entry, ok := backend.Load("k1")
+	assert.True(t, ok)
+	assert.Equal(t, 1, entry.Value)
+	assert.Nil(t, backend.Store("k2", Entry[int]{Value: 2, MaxAge: ForeverAgeValue, CacheTime: time.Now()}))
+	assert.Equal(t, 2, backend.Len())
+}
+
+func TestFileBackendCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "compact.cache")
+
+	backend, err := NewFileBackend[string, string](path, nil)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer backend.Close()
+
+	value := strings.Repeat("x", 8*1024)
+	for idx := 0; idx < 300; idx++ {
+		assert.Nil(t, backend.Store("hot", Entry[string]{Value: value, MaxAge: ForeverAgeValue, CacheTime: time.Now()}))
+	}
+	info, _ := os.Stat(path)
+	assert.Less(t, info.Size(), int64(fileCompactMinSize*2))
+
+	entry, ok := backend.Load("hot")
+	assert.True(t, ok)
+	assert.Equal(t, value, entry.Value)
+}
+
+func TestFileBackendRejectsOversizedRecordHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "oversized.cache")
+
+	backend, err := NewFileBackend[string, int](path, GobCodec{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Nil(t, backend.Store("k1", Entry[int]{Value: 1, MaxAge: ForeverAgeValue, CacheTime: time.Now()}))
+	assert.Nil(t, backend.Close())
+	info, _ := os.Stat(path)
+
+	// 损坏的记录头声明接近 4 GiB 的长度
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	_, _ = file.Write([]byte{0xff, 0xff, 0xff, 0xf0, 'x', 'y'})
+	_ = file.Close()
+
+	backend, err = NewFileBackend[string, int](path, GobCodec{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer backend.Close()
+	entry, ok := backend.Load("k1")
+	assert.True(t, ok)
+	assert.Equal(t, 1, entry.Value)
+	truncated, _ := os.Stat(path)
+	assert.Equal(t, info.Size(), truncated.Size())
+}
+
+func TestFileBackendCompactionFailureKeepsWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "compact.cache")
+
+	backend, err := NewFileBackend[string, string](path, nil)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer backend.Close()
+
+	// 压缩使用的临时文件路径被目录占用，压缩失败
+	assert.Nil(t, os.Mkdir(path+".compact", 0o700))
+	value := strings.Repeat("x", 8*1024)
+	for idx := 0; idx < 300; idx++ {
+		assert.Nil(t, backend.Store("hot", Entry[string]{Value: value + string(rune('a'+idx%26)), MaxAge: ForeverAgeValue, CacheTime: time.Now()}))
+	}
+	assert.Nil(t, backend.Delete("hot"))
+	_, ok := backend.Load("hot")
+	assert.False(t, ok)
+	info, _ := os.Stat(path)
+	assert.Greater(t, info.Size(), int64(fileCompactMinSize*2))
+}
+
+func TestTieredBackend(t *testing.T) {
+	l1 := NewMemoryBackend[string, int]()
+	l2, err := NewFileBackend[string, int](filepath.Join(t.TempDir(), "l2.cache"), nil)
+	if !assert.Nil(t, err) {
+		return
+	}
+	cache := NewGenericKVCacheWithBackend[string, int](nil, NewTieredBackend[string, int](l1, l2, 2), nil)
+	defer cache.Release()
+
+	cache.Put("k1", 1, ForeverAgeValue)
+	cache.Put("k2", 2, ForeverAgeValue)
+	cache.Put("k3", 3, ForeverAgeValue)
+	assert.Equal(t, 2, l1.Len())
+	assert.Equal(t, 3, l2.Len())
+
+	// L1 未命中时从 L2 读取并回填
+	_, inL1 := l1.Load("k1")
+	assert.False(t, inL1)
+	assert.Equal(t, 1, cache.Fetch("k1"))
+	_, inL1 = l1.Load("k1")
+	assert.True(t, inL1)
+
+	cache.Remove("k1")
+	assert.Equal(t, 0, cache.Fetch("k1"))
+	assert.Equal(t, 2, l2.Len())
+}
+
+// blockingBackend Load 读到数据后等待 release，模拟回填前的并发写入
+type blockingBackend[K comparable, V any] struct {
+	*MemoryBackend[K, V]
+	loaded  chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingBackend[K, V]) Load(key K) (Entry[V], bool) {
+	entry, ok := s.MemoryBackend.Load(key)
+	if s.loaded != nil {
+		s.loaded <- struct{}{}
+		<-s.release
+	}
+	return entry, ok
+}
+
+func TestTieredBackendBackfillRace(t *testing.T) {
+	testCases := []struct {
+		name  string
+		write func(backend *TieredBackend[string, int]) error
+		want  int
+		found bool
+	}{
+		{"delete", func(backend *TieredBackend[string, int]) error { return backend.Delete("k1") }, 0, false},
+		{"store", func(backend *TieredBackend[string, int]) error {
+			return backend.Store("k1", Entry[int]{Value: 2, MaxAge: ForeverAgeValue, CacheTime: time.Now()})
+		}, 2, true},
+		{"clear", func(backend *TieredBackend[string, int]) error { return backend.Clear() }, 0, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l1 := NewMemoryBackend[string, int]()
+			l2 := &blockingBackend[string, int]{MemoryBackend: NewMemoryBackend[string, int]()}
+			_ = l2.Store("k1", Entry[int]{Value: 1, MaxAge: ForeverAgeValue, CacheTime: time.Now()})
+			backend := NewTieredBackend[string, int](l1, l2, 0)
+			l2.loaded = make(chan struct{})
+			l2.release = make(chan struct{})
+
+			// 读取方从 L2 读到旧值后，写入方完成写入，旧值不能回填到 L1
+			done := make(chan Entry[int])
+			go func() {
+				entry, _ := backend.Load("k1")
+				done <- entry
+			}()
+			<-l2.loaded
+			assert.Nil(t, tc.write(backend))
+			close(l2.release)
+			assert.Equal(t, 1, (<-done).Value)
+
+			l2.loaded = nil
+			entry, ok := l1.Load("k1")
+			assert.Equal(t, tc.found, ok)
+			assert.Equal(t, tc.want, entry.Value)
+			entry, ok = backend.Load("k1")
+			assert.Equal(t, tc.found, ok)
+			assert.Equal(t, tc.want, entry.Value)
+		})
+	}
+}
+
+func TestTieredBackendConcurrentLoadDelete(t *testing.T) {
+	l1 := NewMemoryBackend[int, int]()
+	l2 := NewMemoryBackend[int, int]()
+	backend := NewTieredBackend[int, int](l1, l2, 8)
+
+	wg := sync.WaitGroup{}
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for idx := 0; idx < 500; idx++ {
+				key := idx % 16
+				switch (worker + idx) % 3 {
+				case 0:
+					_ = backend.Store(key, Entry[int]{Value: idx, MaxAge: ForeverAgeValue, CacheTime: time.Now()})
+				case 1:
+					_ = backend.Delete(key)
+				default:
+					backend.Load(key)
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	// 全部删除后 L1 不能残留回填的数据
+	for key := 0; key < 16; key++ {
+		assert.Nil(t, backend.Delete(key))
+	}
+	for key := 0; key < 16; key++ {
+		_, ok := backend.Load(key)
+		assert.False(t, ok)
+	}
+	assert.Equal(t, 0, l1.Len())
+	assert.Empty(t, backend.loads)
+}
@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"strconv"
 	"testing"
 )
 
@@ -95,3 +96,94 @@ func BenchmarkMemoryCache_ConcurrentFetch(b *testing.B) {
 		}
 	})
 }
+
+const benchmarkKeyCount = 1024
+
+func benchmarkKeys() []string {
+	keys := make([]string, benchmarkKeyCount)
+	for idx := range keys {
+		keys[idx] = "key-" + strconv.Itoa(idx)
+	}
+	return keys
+}
+
+func BenchmarkGenericKVCache_ConcurrentPutDistinctKeys(b *testing.B) {
+	cache := NewGenericKVCache[string, int](nil)
+	defer cache.Release()
+	keys := benchmarkKeys()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		idx := 0
+		for pb.Next() {
+			cache.Put(keys[idx%benchmarkKeyCount], idx, 10)
+			idx++
+		}
+	})
+}
+
+func BenchmarkGenericKVCache_ConcurrentFetchDistinctKeys(b *testing.B) {
+	cache := NewGenericKVCache[string, int](nil)
+	defer cache.Release()
+	keys := benchmarkKeys()
+	for idx, key := range keys {
+		cache.Put(key, idx, 10)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		idx := 0
+		for pb.Next() {
+			cache.Fetch(keys[idx%benchmarkKeyCount])
+			idx++
+		}
+	})
+}
+
+// 读写比例 9:1，接近请求路径上的典型负载
+func BenchmarkGenericKVCache_ConcurrentMixed(b *testing.B) {
+	cache := NewGenericKVCache[string, int](nil)
+	defer cache.Release()
+	keys := benchmarkKeys()
+	for idx, key := range keys {
+		cache.Put(key, idx, 10)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		idx := 0
+		for pb.Next() {
+			key := keys[idx%benchmarkKeyCount]
+			if idx%10 == 0 {
+				cache.Put(key, idx, 10)
+			} else {
+				cache.Fetch(key)
+			}
+			idx++
+		}
+	})
+}
+
+func BenchmarkGenericKVCache_ConcurrentMixedWithEviction(b *testing.B) {
+	for _, policy := range []EvictionPolicy{EvictionLRU, EvictionLFU, EvictionTinyLFU} {
+		b.Run(policy.String(), func(b *testing.B) {
+			cache := NewGenericKVCacheWithOptions[string, int](nil, &CacheOptions{Capacity: benchmarkKeyCount / 2, Eviction: policy})
+			defer cache.Release()
+			keys := benchmarkKeys()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				idx := 0
+				for pb.Next() {
+					key := keys[idx%benchmarkKeyCount]
+					if idx%10 == 0 {
+						cache.Put(key, idx, 10)
+					} else {
+						cache.Fetch(key)
+					}
+					idx++
+				}
+			})
+		})
+	}
+}
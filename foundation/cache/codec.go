@@ -0,0 +1,39 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// Codec 缓存条目的序列化方式，用于持久化存储
+type Codec interface {
+	Marshal(val any) ([]byte, error)
+	Unmarshal(data []byte, val any) error
+}
+
+// JSONCodec JSON 编码，值类型为 any 时读取结果为 JSON 的通用类型（map[string]any 等）
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(val any) ([]byte, error) {
+	return json.Marshal(val)
+}
+
+func (JSONCodec) Unmarshal(data []byte, val any) error {
+	return json.Unmarshal(data, val)
+}
+
+// GobCodec gob 编码，可以保留具体类型，值类型为 any 时需要先通过 gob.Register 注册实际类型
+type GobCodec struct{}
+
+func (GobCodec) Marshal(val any) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	if err := gob.NewEncoder(buffer).Encode(val); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, val any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(val)
+}
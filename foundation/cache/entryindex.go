@@ -0,0 +1,144 @@
+package cache
+
+import "sync"
+
+// PutOptions 写入时附加的标签和依赖，每次写入都会替换条目原有的标签和依赖
+type PutOptions[K comparable] struct {
+	// Tags 条目标签，例如 "user:42"、"tenant:7"，InvalidateTag 删除带有该标签的全部条目
+	Tags []string
+	// DependsOn 条目依赖的其它条目，被依赖的条目删除、过期、淘汰或被重新写入时，该条目随之删除
+	DependsOn []K
+}
+
+// entryIndex 标签和依赖的反向索引
+type entryIndex[K comparable] struct {
+	mu         sync.Mutex
+	tagKeys    map[string]map[K]struct{}
+	keyTags    map[K][]string
+	dependents map[K]map[K]struct{}
+	keyParents map[K][]K
+}
+
+func newEntryIndex[K comparable]() *entryIndex[K] {
+	return &entryIndex[K]{
+		tagKeys:    map[string]map[K]struct{}{},
+		keyTags:    map[K][]string{},
+		dependents: map[K]map[K]struct{}{},
+		keyParents: map[K][]K{},
+	}
+}
+
+// replace 替换 key 的标签和依赖，overwrite 为 true 时返回依赖 key 的条目
+func (s *entryIndex[K]) replace(key K, options PutOptions[K], overwrite bool) []K {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.unlinkLocked(key)
+	for _, tag := range options.Tags {
+		keys, ok := s.tagKeys[tag]
+		if !ok {
+			keys = map[K]struct{}{}
+			s.tagKeys[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	if len(options.Tags) > 0 {
+		s.keyTags[key] = append([]string(nil), options.Tags...)
+	}
+	for _, parent := range options.DependsOn {
+		if parent == key {
+			continue
+		}
+		children, ok := s.dependents[parent]
+		if !ok {
+			children = map[K]struct{}{}
+			s.dependents[parent] = children
+		}
+		children[key] = struct{}{}
+		s.keyParents[key] = append(s.keyParents[key], parent)
+	}
+
+	if !overwrite {
+		return nil
+	}
+	return s.takeDependentsLocked(key)
+}
+
+// drop 删除 key 的索引，返回依赖 key 的条目
+func (s *entryIndex[K]) drop(key K) []K {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.unlinkLocked(key)
+	return s.takeDependentsLocked(key)
+}
+
+func (s *entryIndex[K]) keysOfTag(tag string) []K {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := s.tagKeys[tag]
+	ret := make([]K, 0, len(keys))
+	for key := range keys {
+		ret = append(ret, key)
+	}
+	return ret
+}
+
+// optionsOf 返回 key 当前的标签和依赖
+func (s *entryIndex[K]) optionsOf(key K) PutOptions[K] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return PutOptions[K]{
+		Tags:      append([]string(nil), s.keyTags[key]...),
+		DependsOn: append([]K(nil), s.keyParents[key]...),
+	}
+}
+
+func (s *entryIndex[K]) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tagKeys = map[string]map[K]struct{}{}
+	s.keyTags = map[K][]string{}
+	s.dependents = map[K]map[K]struct{}{}
+	s.keyParents = map[K][]K{}
+}
+
+// unlinkLocked 删除 key 自身的标签和对其它条目的依赖，保留其它条目对 key 的依赖
+func (s *entryIndex[K]) unlinkLocked(key K) {
+	for _, tag := range s.keyTags[key] {
+		if keys, ok := s.tagKeys[tag]; ok {
+			delete(keys, key)
+			if len(keys) == 0 {
+				delete(s.tagKeys, tag)
+			}
+		}
+	}
+	delete(s.keyTags, key)
+
+	for _, parent := range s.keyParents[key] {
+		if children, ok := s.dependents[parent]; ok {
+			delete(children, key)
+			if len(children) == 0 {
+				delete(s.dependents, parent)
+			}
+		}
+	}
+	delete(s.keyParents, key)
+}
+
+func (s *entryIndex[K]) takeDependentsLocked(key K) []K {
+	children := s.dependents[key]
+	if len(children) == 0 {
+		return nil
+	}
+
+	ret := make([]K, 0, len(children))
+	for child := range children {
+		ret = append(ret, child)
+	}
+	delete(s.dependents, key)
+	return ret
+}
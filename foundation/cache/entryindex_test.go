@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvalidateTag(t *testing.T) {
+	cache := NewGenericKVCache[string, string](nil)
+	defer cache.Release()
+
+	cache.PutWithOptions("order:1", "o1", ForeverAgeValue, PutOptions[string]{Tags: []string{"tenant:7", "user:42"}})
+	cache.PutWithOptions("order:2", "o2", ForeverAgeValue, PutOptions[string]{Tags: []string{"tenant:7"}})
+	cache.PutWithOptions("order:3", "o3", ForeverAgeValue, PutOptions[string]{Tags: []string{"tenant:8"}})
+	cache.Put("plain", "p", ForeverAgeValue)
+
+	assert.Equal(t, 2, cache.InvalidateTag("tenant:7"))
+	assert.Equal(t, "", cache.Fetch("order:1"))
+	assert.Equal(t, "", cache.Fetch("order:2"))
+	assert.Equal(t, "o3", cache.Fetch("order:3"))
+	assert.Equal(t, "p", cache.Fetch("plain"))
+	// 删除后标签索引同步清理
+	assert.Equal(t, 0, cache.InvalidateTag("user:42"))
+
+	// 重新写入替换原有标签
+	cache.PutWithOptions("order:3", "o3", ForeverAgeValue, PutOptions[string]{Tags: []string{"tenant:9"}})
+	assert.Equal(t, 0, cache.InvalidateTag("tenant:8"))
+	assert.Equal(t, 1, cache.InvalidateTag("tenant:9"))
+	assert.Equal(t, int64(3), cache.(*GenericKVCache[string, string]).Stats().Invalidations)
+}
+
+func TestDependencyCascade(t *testing.T) {
+	cache := NewGenericKVCache[string, string](nil)
+	defer cache.Release()
+
+	cache.Put("user:42", "alice", ForeverAgeValue)
+	cache.PutWithOptions("profile:42", "profile", ForeverAgeValue, PutOptions[string]{DependsOn: []string{"user:42"}})
+	cache.PutWithOptions("avatar:42", "avatar", ForeverAgeValue, PutOptions[string]{DependsOn: []string{"profile:42"}})
+	cache.PutWithOptions("team:1", "team", ForeverAgeValue, PutOptions[string]{DependsOn: []string{"user:42", "user:43"}})
+
+	cache.Remove("user:42")
+	assert.Equal(t, "", cache.Fetch("profile:42"))
+	assert.Equal(t, "", cache.Fetch("avatar:42"))
+	assert.Equal(t, "", cache.Fetch("team:1"))
+
+	// 被依赖的条目重新写入时，依赖它的条目随之删除
+	cache.Put("user:43", "bob", ForeverAgeValue)
+	cache.PutWithOptions("profile:43", "profile", ForeverAgeValue, PutOptions[string]{DependsOn: []string{"user:43"}})
+	cache.Put("user:43", "bob-updated", ForeverAgeValue)
+	assert.Equal(t, "", cache.Fetch("profile:43"))
+	assert.Equal(t, "bob-updated", cache.Fetch("user:43"))
+
+	// 先写入依赖方、后首次写入被依赖方不会触发删除
+	cache.PutWithOptions("profile:44", "profile", ForeverAgeValue, PutOptions[string]{DependsOn: []string{"user:44"}})
+	cache.Put("user:44", "carol", ForeverAgeValue)
+	assert.Equal(t, "profile", cache.Fetch("profile:44"))
+
+	// 循环依赖
+	cache.PutWithOptions("a", "a", ForeverAgeValue, PutOptions[string]{DependsOn: []string{"b"}})
+	cache.PutWithOptions("b", "b", ForeverAgeValue, PutOptions[string]{DependsOn: []string{"a"}})
+	cache.Remove("a")
+	assert.Equal(t, "", cache.Fetch("b"))
+}
+
+func TestDependencyCascadeOnEvictionAndTag(t *testing.T) {
+	cache := NewGenericKVCacheWithOptions[int, string](nil, &CacheOptions{Capacity: 3})
+	defer cache.Release()
+
+	cache.PutWithOptions(1, "tenant", ForeverAgeValue, PutOptions[int]{Tags: []string{"tenant:1"}})
+	cache.PutWithOptions(2, "child", ForeverAgeValue, PutOptions[int]{DependsOn: []int{1}})
+	cache.PutWithOptions(3, "grandchild", ForeverAgeValue, PutOptions[int]{DependsOn: []int{2}})
+	assert.Equal(t, 1, cache.InvalidateTag("tenant:1"))
+	assert.Equal(t, 0, cache.(*GenericKVCache[int, string]).Stats().Entries)
+
+	for idx := 10; idx < 13; idx++ {
+		cache.PutWithOptions(idx, fmt.Sprint(idx), ForeverAgeValue, PutOptions[int]{DependsOn: []int{idx - 1}})
+	}
+	// 淘汰 10 时级联删除 11、12
+	cache.Put(20, "20", ForeverAgeValue)
+	assert.Equal(t, "", cache.Fetch(11))
+	assert.Equal(t, "", cache.Fetch(12))
+	assert.Equal(t, "20", cache.Fetch(20))
+}
@@ -0,0 +1,597 @@
+package cache
+
+import (
+	"container/list"
+	"hash/maphash"
+	"sync"
+)
+
+// EvictionPolicy 超过容量时的淘汰策略
+type EvictionPolicy int
+
+const (
+	// EvictionLRU 淘汰最久未访问的条目
+	EvictionLRU EvictionPolicy = iota
+	// EvictionLFU 淘汰访问次数最少的条目，次数相同时淘汰最久未访问的条目
+	EvictionLFU
+	// EvictionTinyLFU W-TinyLFU：新条目先进入窗口 LRU，离开窗口时与主区的淘汰候选比较访问频率，频率更高才允许进入主区
+	EvictionTinyLFU
+)
+
+func (s EvictionPolicy) String() string {
+	switch s {
+	case EvictionLFU:
+		return "LFU"
+	case EvictionTinyLFU:
+		return "W-TinyLFU"
+	default:
+		return "LRU"
+	}
+}
+
+// evictionPolicy 淘汰策略的记录，所有操作均为 O(1)
+type evictionPolicy[K comparable] interface {
+	// add 写入条目，返回需要淘汰的条目，admitted 为 false 表示当前条目未被接纳
+	add(key K, cost int64) (victims []K, admitted bool)
+	access(key K)
+	miss(key K)
+	remove(key K)
+	// contains 条目是否仍在策略记录中，用于确认被淘汰的条目没有被重新写入
+	contains(key K) bool
+	reset()
+	totalCost() int64
+}
+
+// newEvictionPolicy 未限制容量和成本时返回 nil，不做任何记录
+func newEvictionPolicy[K comparable](options CacheOptions) evictionPolicy[K] {
+	if options.Capacity <= 0 && options.MaxCost <= 0 {
+		return nil
+	}
+
+	switch options.Eviction {
+	case EvictionLFU:
+		return newLFUPolicy[K](options.Capacity, options.MaxCost)
+	case EvictionTinyLFU:
+		return newTinyLFUPolicy[K](options.Capacity, options.MaxCost)
+	default:
+		return newLRUPolicy[K](options.Capacity, options.MaxCost)
+	}
+}
+
+type policyLimit struct {
+	capacity int
+	maxCost  int64
+}
+
+// overflow 条目数量和成本总和是否超过限制
+func (s policyLimit) overflow(entries int, totalCost int64) bool {
+	return (s.capacity > 0 && entries > s.capacity) || (s.maxCost > 0 && totalCost > s.maxCost)
+}
+
+type lruNode[K comparable] struct {
+	key  K
+	cost int64
+}
+
+type lruPolicy[K comparable] struct {
+	mu    sync.Mutex
+	limit policyLimit
+	order *list.List
+	items map[K]*list.Element
+	cost  int64
+}
+
+func newLRUPolicy[K comparable](capacity int, maxCost int64) *lruPolicy[K] {
+	return &lruPolicy[K]{limit: policyLimit{capacity: capacity, maxCost: maxCost}, order: list.New(), items: map[K]*list.Element{}}
+}
+
+func (s *lruPolicy[K]) add(key K, cost int64) ([]K, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.limit.maxCost > 0 && cost > s.limit.maxCost {
+		return s.removeLocked(key), false
+	}
+
+	if elem, ok := s.items[key]; ok {
+		nodePtr := elem.Value.(*lruNode[K])
+		s.cost += cost - nodePtr.cost
+		nodePtr.cost = cost
+		s.order.MoveToFront(elem)
+	} else {
+		s.items[key] = s.order.PushFront(&lruNode[K]{key: key, cost: cost})
+		s.cost += cost
+	}
+
+	var victims []K
+	for s.limit.overflow(s.order.Len(), s.cost) {
+		nodePtr := s.order.Back().Value.(*lruNode[K])
+		s.removeLocked(nodePtr.key)
+		victims = append(victims, nodePtr.key)
+	}
+	return victims, true
+}
+
+func (s *lruPolicy[K]) access(key K) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.items[key]; ok {
+		s.order.MoveToFront(elem)
+	}
+}
+
+func (s *lruPolicy[K]) miss(K) {}
+
+func (s *lruPolicy[K]) remove(key K) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeLocked(key)
+}
+
+func (s *lruPolicy[K]) removeLocked(key K) []K {
+	elem, ok := s.items[key]
+	if !ok {
+		return nil
+	}
+	s.cost -= elem.Value.(*lruNode[K]).cost
+	s.order.Remove(elem)
+	delete(s.items, key)
+	return []K{key}
+}
+
+func (s *lruPolicy[K]) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.order.Init()
+	s.items = map[K]*list.Element{}
+	s.cost = 0
+}
+
+func (s *lruPolicy[K]) contains(key K) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.items[key]
+	return ok
+}
+
+func (s *lruPolicy[K]) totalCost() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.cost
+}
+
+type lfuBucket[K comparable] struct {
+	freq  int
+	items *list.List
+}
+
+type lfuNode[K comparable] struct {
+	key    K
+	cost   int64
+	bucket *list.Element
+	elem   *list.Element
+}
+
+// lfuPolicy 访问次数相同的条目放在同一个桶中，桶按次数从小到大排列
+type lfuPolicy[K comparable] struct {
+	mu      sync.Mutex
+	limit   policyLimit
+	items   map[K]*lfuNode[K]
+	buckets *list.List
+	cost    int64
+}
+
+func newLFUPolicy[K comparable](capacity int, maxCost int64) *lfuPolicy[K] {
+	return &lfuPolicy[K]{limit: policyLimit{capacity: capacity, maxCost: maxCost}, items: map[K]*lfuNode[K]{}, buckets: list.New()}
+}
+
+func (s *lfuPolicy[K]) add(key K, cost int64) ([]K, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.limit.maxCost > 0 && cost > s.limit.maxCost {
+		return s.removeLocked(key), false
+	}
+
+	nodePtr, ok := s.items[key]
+	if ok {
+		s.cost += cost - nodePtr.cost
+		nodePtr.cost = cost
+		s.incrementLocked(nodePtr)
+	} else {
+		nodePtr = &lfuNode[K]{key: key, cost: cost}
+		front := s.buckets.Front()
+		if front == nil || front.Value.(*lfuBucket[K]).freq != 1 {
+			front = s.buckets.PushFront(&lfuBucket[K]{freq: 1, items: list.New()})
+		}
+		s.attachLocked(nodePtr, front)
+		s.items[key] = nodePtr
+		s.cost += cost
+	}
+
+	// 当前写入的条目不参与淘汰，避免新条目因访问次数最少被立即淘汰
+	var victims []K
+	for len(s.items) > 1 && s.limit.overflow(len(s.items), s.cost) {
+		victims = append(victims, s.evictLocked(key))
+	}
+	return victims, true
+}
+
+func (s *lfuPolicy[K]) attachLocked(nodePtr *lfuNode[K], bucket *list.Element) {
+	nodePtr.bucket = bucket
+	nodePtr.elem = bucket.Value.(*lfuBucket[K]).items.PushFront(nodePtr)
+}
+
+func (s *lfuPolicy[K]) detachLocked(nodePtr *lfuNode[K]) {
+	bucket := nodePtr.bucket.Value.(*lfuBucket[K])
+	bucket.items.Remove(nodePtr.elem)
+	if bucket.items.Len() == 0 {
+		s.buckets.Remove(nodePtr.bucket)
+	}
+}
+
+func (s *lfuPolicy[K]) incrementLocked(nodePtr *lfuNode[K]) {
+	current := nodePtr.bucket
+	freq := current.Value.(*lfuBucket[K]).freq
+	next := current.Next()
+	if next == nil || next.Value.(*lfuBucket[K]).freq != freq+1 {
+		next = s.buckets.InsertAfter(&lfuBucket[K]{freq: freq + 1, items: list.New()}, current)
+	}
+	s.detachLocked(nodePtr)
+	s.attachLocked(nodePtr, next)
+}
+
+// evictLocked 淘汰访问次数最少的桶中最久未访问的条目，跳过 skip
+func (s *lfuPolicy[K]) evictLocked(skip K) K {
+	for bucket := s.buckets.Front(); bucket != nil; bucket = bucket.Next() {
+		for elem := bucket.Value.(*lfuBucket[K]).items.Back(); elem != nil; elem = elem.Prev() {
+			nodePtr := elem.Value.(*lfuNode[K])
+			if nodePtr.key != skip {
+				s.removeLocked(nodePtr.key)
+				return nodePtr.key
+			}
+		}
+	}
+
+	var zero K
+	return zero
+}
+
+func (s *lfuPolicy[K]) access(key K) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if nodePtr, ok := s.items[key]; ok {
+		s.incrementLocked(nodePtr)
+	}
+}
+
+func (s *lfuPolicy[K]) miss(K) {}
+
+func (s *lfuPolicy[K]) remove(key K) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeLocked(key)
+}
+
+func (s *lfuPolicy[K]) removeLocked(key K) []K {
+	nodePtr, ok := s.items[key]
+	if !ok {
+		return nil
+	}
+
+	s.detachLocked(nodePtr)
+	delete(s.items, key)
+	s.cost -= nodePtr.cost
+	return []K{key}
+}
+
+func (s *lfuPolicy[K]) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items = map[K]*lfuNode[K]{}
+	s.buckets.Init()
+	s.cost = 0
+}
+
+func (s *lfuPolicy[K]) contains(key K) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.items[key]
+	return ok
+}
+
+func (s *lfuPolicy[K]) totalCost() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.cost
+}
+
+const (
+	sketchDepth      = 4
+	sketchMaxCounter = 15
+	sketchMinWidth   = 64
+	// sketchResetRatio 累计写入次数达到宽度的倍数时计数减半，使频率随时间衰减
+	sketchResetRatio = 10
+)
+
+// countMinSketch 访问频率估计
+type countMinSketch struct {
+	rows      [sketchDepth][]uint8
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+func newCountMinSketch(capacity int) *countMinSketch {
+	width := sketchMinWidth
+	for width < capacity {
+		width <<= 1
+	}
+
+	sketch := &countMinSketch{mask: uint64(width - 1), resetAt: width * sketchResetRatio}
+	for idx := range sketch.rows {
+		sketch.rows[idx] = make([]uint8, width)
+	}
+	return sketch
+}
+
+func (s *countMinSketch) index(hashVal uint64, row int) uint64 {
+	return (hashVal + uint64(row)*(hashVal>>32|1)) & s.mask
+}
+
+func (s *countMinSketch) increment(hashVal uint64) {
+	for row := range s.rows {
+		idx := s.index(hashVal, row)
+		if s.rows[row][idx] < sketchMaxCounter {
+			s.rows[row][idx]++
+		}
+	}
+
+	s.additions++
+	if s.additions >= s.resetAt {
+		for row := range s.rows {
+			for idx := range s.rows[row] {
+				s.rows[row][idx] >>= 1
+			}
+		}
+		s.additions /= 2
+	}
+}
+
+func (s *countMinSketch) estimate(hashVal uint64) uint8 {
+	ret := uint8(sketchMaxCounter)
+	for row := range s.rows {
+		ret = min(ret, s.rows[row][s.index(hashVal, row)])
+	}
+	return ret
+}
+
+type tinyLFUSegment int
+
+const (
+	segmentWindow tinyLFUSegment = iota
+	segmentProbation
+	segmentProtected
+)
+
+type tinyLFUNode[K comparable] struct {
+	key     K
+	cost    int64
+	segment tinyLFUSegment
+}
+
+// tinyLFUPolicy 窗口占容量的 1%，主区分为 probation 和 protected（占主区 80%）
+type tinyLFUPolicy[K comparable] struct {
+	mu           sync.Mutex
+	limit        policyLimit
+	seed         maphash.Seed
+	sketch       *countMinSketch
+	window       *list.List
+	probation    *list.List
+	protected    *list.List
+	items        map[K]*list.Element
+	windowCap    int
+	mainCap      int
+	protectedCap int
+	cost         int64
+}
+
+func newTinyLFUPolicy[K comparable](capacity int, maxCost int64) *tinyLFUPolicy[K] {
+	windowCap := max(1, capacity/100)
+	mainCap := max(0, capacity-windowCap)
+	return &tinyLFUPolicy[K]{
+		limit:        policyLimit{capacity: capacity, maxCost: maxCost},
+		seed:         maphash.MakeSeed(),
+		sketch:       newCountMinSketch(capacity),
+		window:       list.New(),
+		probation:    list.New(),
+		protected:    list.New(),
+		items:        map[K]*list.Element{},
+		windowCap:    windowCap,
+		mainCap:      mainCap,
+		protectedCap: mainCap * 8 / 10,
+	}
+}
+
+func (s *tinyLFUPolicy[K]) hash(key K) uint64 {
+	return maphash.Comparable(s.seed, key)
+}
+
+func (s *tinyLFUPolicy[K]) segmentList(segment tinyLFUSegment) *list.List {
+	switch segment {
+	case segmentProbation:
+		return s.probation
+	case segmentProtected:
+		return s.protected
+	default:
+		return s.window
+	}
+}
+
+func (s *tinyLFUPolicy[K]) add(key K, cost int64) ([]K, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.limit.maxCost > 0 && cost > s.limit.maxCost {
+		return s.removeLocked(key), false
+	}
+
+	s.sketch.increment(s.hash(key))
+	if elem, ok := s.items[key]; ok {
+		nodePtr := elem.Value.(*tinyLFUNode[K])
+		s.cost += cost - nodePtr.cost
+		nodePtr.cost = cost
+		s.accessLocked(elem)
+	} else {
+		s.items[key] = s.window.PushFront(&tinyLFUNode[K]{key: key, cost: cost, segment: segmentWindow})
+		s.cost += cost
+	}
+
+	var victims []K
+	if s.limit.capacity > 0 {
+		for s.window.Len() > s.windowCap {
+			victims = append(victims, s.admitLocked()...)
+		}
+	}
+	for s.limit.maxCost > 0 && s.cost > s.limit.maxCost {
+		victims = append(victims, s.evictMainLocked())
+	}
+
+	_, admitted := s.items[key]
+	return victims, admitted
+}
+
+// admitLocked 窗口尾部条目离开窗口，主区已满时与主区淘汰候选比较频率
+func (s *tinyLFUPolicy[K]) admitLocked() []K {
+	candidate := s.window.Back()
+	candidateNode := candidate.Value.(*tinyLFUNode[K])
+	if s.probation.Len()+s.protected.Len() < s.mainCap {
+		s.moveLocked(candidate, segmentProbation)
+		return nil
+	}
+
+	victim := s.probation.Back()
+	if victim == nil {
+		victim = s.protected.Back()
+	}
+	if victim == nil {
+		s.removeLocked(candidateNode.key)
+		return []K{candidateNode.key}
+	}
+
+	victimNode := victim.Value.(*tinyLFUNode[K])
+	if s.sketch.estimate(s.hash(candidateNode.key)) > s.sketch.estimate(s.hash(victimNode.key)) {
+		s.removeLocked(victimNode.key)
+		s.moveLocked(candidate, segmentProbation)
+		return []K{victimNode.key}
+	}
+
+	s.removeLocked(candidateNode.key)
+	return []K{candidateNode.key}
+}
+
+func (s *tinyLFUPolicy[K]) evictMainLocked() K {
+	for _, segmentVal := range []*list.List{s.probation, s.protected, s.window} {
+		if elem := segmentVal.Back(); elem != nil {
+			key := elem.Value.(*tinyLFUNode[K]).key
+			s.removeLocked(key)
+			return key
+		}
+	}
+
+	var zero K
+	return zero
+}
+
+func (s *tinyLFUPolicy[K]) moveLocked(elem *list.Element, segment tinyLFUSegment) {
+	nodePtr := elem.Value.(*tinyLFUNode[K])
+	s.segmentList(nodePtr.segment).Remove(elem)
+	nodePtr.segment = segment
+	s.items[nodePtr.key] = s.segmentList(segment).PushFront(nodePtr)
+}
+
+func (s *tinyLFUPolicy[K]) accessLocked(elem *list.Element) {
+	nodePtr := elem.Value.(*tinyLFUNode[K])
+	switch nodePtr.segment {
+	case segmentProbation:
+		s.moveLocked(elem, segmentProtected)
+		if s.protected.Len() > s.protectedCap {
+			s.moveLocked(s.protected.Back(), segmentProbation)
+		}
+	default:
+		s.segmentList(nodePtr.segment).MoveToFront(elem)
+	}
+}
+
+func (s *tinyLFUPolicy[K]) access(key K) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sketch.increment(s.hash(key))
+	if elem, ok := s.items[key]; ok {
+		s.accessLocked(elem)
+	}
+}
+
+// miss 未命中也计入频率，使反复访问的新数据能够进入主区
+func (s *tinyLFUPolicy[K]) miss(key K) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sketch.increment(s.hash(key))
+}
+
+func (s *tinyLFUPolicy[K]) remove(key K) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeLocked(key)
+}
+
+func (s *tinyLFUPolicy[K]) removeLocked(key K) []K {
+	elem, ok := s.items[key]
+	if !ok {
+		return nil
+	}
+	nodePtr := elem.Value.(*tinyLFUNode[K])
+	s.segmentList(nodePtr.segment).Remove(elem)
+	s.cost -= nodePtr.cost
+	delete(s.items, key)
+	return []K{key}
+}
+
+func (s *tinyLFUPolicy[K]) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.window.Init()
+	s.probation.Init()
+	s.protected.Init()
+	s.items = map[K]*list.Element{}
+	s.cost = 0
+}
+
+func (s *tinyLFUPolicy[K]) contains(key K) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.items[key]
+	return ok
+}
+
+func (s *tinyLFUPolicy[K]) totalCost() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.cost
+}
@@ -0,0 +1,185 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvictionLRU(t *testing.T) {
+	cache := newGenericKVCache[string, int](nil, NewMemoryBackend[string, int](), &CacheOptions{Capacity: 2})
+	defer cache.Release()
+
+	cache.Put("a", 1, ForeverAgeValue)
+	cache.Put("b", 2, ForeverAgeValue)
+	assert.Equal(t, 1, cache.Fetch("a"))
+	cache.Put("c", 3, ForeverAgeValue)
+
+	assert.Equal(t, 1, cache.Fetch("a"))
+	assert.Equal(t, 0, cache.Fetch("b"))
+	assert.Equal(t, 3, cache.Fetch("c"))
+	stats := cache.Stats()
+	assert.Equal(t, EvictionLRU, stats.Eviction)
+	assert.Equal(t, int64(1), stats.Evictions)
+	assert.Equal(t, 2, stats.Entries)
+}
+
+func TestEvictionLFU(t *testing.T) {
+	cache := newGenericKVCache[string, int](nil, NewMemoryBackend[string, int](), &CacheOptions{Capacity: 2, Eviction: EvictionLFU})
+	defer cache.Release()
+
+	cache.Put("a", 1, ForeverAgeValue)
+	cache.Put("b", 2, ForeverAgeValue)
+	cache.Fetch("a")
+	cache.Fetch("a")
+	cache.Fetch("b")
+	cache.Put("c", 3, ForeverAgeValue)
+	assert.Equal(t, 0, cache.Fetch("b"))
+
+	// 更新已有条目不会淘汰自身
+	cache.Put("c", 4, ForeverAgeValue)
+	assert.Equal(t, 4, cache.Fetch("c"))
+	cache.Fetch("a")
+	cache.Put("d", 5, ForeverAgeValue)
+	assert.Equal(t, 1, cache.Fetch("a"))
+	assert.Equal(t, 0, cache.Fetch("c"))
+	assert.Equal(t, 5, cache.Fetch("d"))
+	assert.Equal(t, int64(2), cache.Stats().Evictions)
+}
+
+func TestEvictionSizeWeighted(t *testing.T) {
+	options := &CacheOptions{MaxCost: 10, Cost: func(value any) int64 {
+		return int64(len(value.(string)))
+	}}
+	cache := newGenericKVCache[string, string](nil, NewMemoryBackend[string, string](), options)
+	defer cache.Release()
+
+	cache.Put("a", "aaaa", ForeverAgeValue)
+	cache.Put("b", "bbbb", ForeverAgeValue)
+	cache.Put("c", "cc", ForeverAgeValue)
+	assert.Equal(t, int64(10), cache.Stats().Cost)
+
+	cache.Put("d", "dd", ForeverAgeValue)
+	assert.Equal(t, "", cache.Fetch("a"))
+	assert.Equal(t, int64(8), cache.Stats().Cost)
+
+	// 单个条目超过 MaxCost 时拒绝写入
+	cache.Put("e", strings.Repeat("e", 11), ForeverAgeValue)
+	assert.Equal(t, "", cache.Fetch("e"))
+	stats := cache.Stats()
+	assert.Equal(t, int64(1), stats.Rejections)
+	assert.Equal(t, int64(1), stats.Evictions)
+	assert.Equal(t, int64(8), stats.Cost)
+
+	cache.Remove("b")
+	assert.Equal(t, int64(4), cache.Stats().Cost)
+	cache.ClearAll()
+	assert.Equal(t, int64(0), cache.Stats().Cost)
+}
+
+// 热点数据被一次性扫描冲刷时，W-TinyLFU 的命中率应高于 LRU
+func TestEvictionTinyLFUScanResistance(t *testing.T) {
+	run := func(policy EvictionPolicy) CacheStats {
+		cache := newGenericKVCache[string, int](nil, NewMemoryBackend[string, int](), &CacheOptions{Capacity: 100, Eviction: policy})
+		defer cache.Release()
+
+		access := func(key string) {
+			if cache.Fetch(key) == 0 {
+				cache.Put(key, 1, ForeverAgeValue)
+			}
+		}
+		for round := 0; round < 20; round++ {
+			for idx := 0; idx < 50; idx++ {
+				access(fmt.Sprintf("hot-%d", idx))
+			}
+			for idx := 0; idx < 200; idx++ {
+				access(fmt.Sprintf("scan-%d-%d", round, idx))
+			}
+		}
+		return cache.Stats()
+	}
+
+	lru := run(EvictionLRU)
+	tinyLFU := run(EvictionTinyLFU)
+	assert.Equal(t, EvictionTinyLFU, tinyLFU.Eviction)
+	assert.LessOrEqual(t, tinyLFU.Entries, 100)
+	assert.Greater(t, tinyLFU.HitRatio(), lru.HitRatio())
+}
+
+func TestEvictionRestoresPolicyFromBackend(t *testing.T) {
+	backend := NewMemoryBackend[string, int]()
+	cache := newGenericKVCache[string, int](nil, backend, nil)
+	for idx := 0; idx < 5; idx++ {
+		cache.Put(fmt.Sprint(idx), idx, ForeverAgeValue)
+	}
+
+	// 已有条目超过容量时在创建时淘汰
+	limited := newGenericKVCache[string, int](nil, backend, &CacheOptions{Capacity: 3})
+	defer limited.Release()
+	assert.Equal(t, 3, limited.Stats().Entries)
+	limited.Put("new", 1, ForeverAgeValue)
+	assert.Equal(t, 3, limited.Stats().Entries)
+	cache.Release()
+}
+
+func TestEvictionKeepsStripedKeyLocks(t *testing.T) {
+	cache := newGenericKVCache[int, int](nil, NewMemoryBackend[int, int](), &CacheOptions{Capacity: 64})
+	defer cache.Release()
+
+	// 其它分段被锁定时，有容量上限的缓存仍可写入
+	blocked := cache.lockKey(1)
+	other := 2
+	for cache.lockKey(other) == blocked {
+		other++
+	}
+	blocked.Lock()
+	done := make(chan struct{})
+	go func() {
+		cache.Put(other, other, ForeverAgeValue)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("put on another key was blocked")
+	}
+	blocked.Unlock()
+}
+
+func TestEvictionConcurrentPuts(t *testing.T) {
+	for _, eviction := range []EvictionPolicy{EvictionLRU, EvictionLFU, EvictionTinyLFU} {
+		t.Run(eviction.String(), func(t *testing.T) {
+			cache := newGenericKVCache[int, int](nil, NewMemoryBackend[int, int](), &CacheOptions{Capacity: 100, Eviction: eviction})
+			defer cache.Release()
+
+			var wg sync.WaitGroup
+			for worker := 0; worker < 8; worker++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for idx := 0; idx < 2000; idx++ {
+						key := (worker*7919 + idx) % 500
+						cache.Put(key, key, ForeverAgeValue)
+						if idx%5 == 0 {
+							cache.Remove(key)
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			// 存储中的条目与淘汰策略的记录保持一致
+			stats := cache.Stats()
+			assert.LessOrEqual(t, stats.Entries, 100)
+			cache.backend.Range(func(k int, _ Entry[int]) bool {
+				assert.True(t, cache.policy.contains(k), "key %d missing from policy", k)
+				return true
+			})
+			assert.Equal(t, int64(stats.Entries), cache.policy.totalCost())
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	fileRecordHeaderSize = 4
+	// fileCompactMinSize 失效数据超过该大小且超过文件一半时压缩文件
+	fileCompactMinSize = 1 << 20
+)
+
+// fileRecord 文件中的一条记录，Deleted 为 true 表示删除标记
+type fileRecord[K comparable, V any] struct {
+	Key       K
+	Value     V
+	MaxAge    int64
+	CacheTime int64
+	Deleted   bool
+}
+
+type fileIndex struct {
+	offset    int64
+	size      int64
+	maxAge    int64
+	cacheTime time.Time
+}
+
+// FileBackend 文件存储，以追加日志的方式写入，内存中只保存索引，进程重启后可以恢复缓存内容。
+// Touch 刷新的访问时间只保存在内存中，不写入文件。
+type FileBackend[K comparable, V any] struct {
+	mu    sync.RWMutex
+	path  string
+	codec Codec
+	file  *os.File
+	size  int64
+	dead  int64
+	index map[K]*fileIndex
+}
+
+// NewFileBackend 打开或创建缓存文件，codec 为 nil 时使用 JSONCodec
+func NewFileBackend[K comparable, V any](path string, codec Codec) (*FileBackend[K, V], error) {
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	backend := &FileBackend[K, V]{path: path, codec: codec, file: file, index: map[K]*fileIndex{}}
+	if err = backend.load(); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return backend, nil
+}
+
+// load 重放文件建立索引，末尾不完整或损坏的记录被截断
+func (s *FileBackend[K, V]) load() error {
+	info, err := s.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(s.file)
+	header := make([]byte, fileRecordHeaderSize)
+	var offset int64
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return s.truncateTail(offset, err)
+		}
+
+		// 长度超过文件剩余大小说明记录头已损坏，不按该长度分配内存
+		payloadSize := int64(binary.BigEndian.Uint32(header))
+		if payloadSize > info.Size()-offset-fileRecordHeaderSize {
+			return s.truncateTail(offset, io.ErrUnexpectedEOF)
+		}
+		payload := make([]byte, payloadSize)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			return s.truncateTail(offset, err)
+		}
+		record := fileRecord[K, V]{}
+		if err := s.codec.Unmarshal(payload, &record); err != nil {
+			return s.truncateTail(offset, err)
+		}
+
+		recordSize := int64(fileRecordHeaderSize + len(payload))
+		s.apply(&record, offset, recordSize)
+		offset += recordSize
+	}
+
+	s.size = offset
+	return nil
+}
+
+func (s *FileBackend[K, V]) truncateTail(offset int64, cause error) error {
+	slog.Warn("cache file is damaged, truncate tail", "path", s.path, "offset", offset, "error", cause)
+	if err := s.file.Truncate(offset); err != nil {
+		return err
+	}
+	s.size = offset
+	return nil
+}
+
+func (s *FileBackend[K, V]) apply(record *fileRecord[K, V], offset, recordSize int64) {
+	if oldIndex, ok := s.index[record.Key]; ok {
+		s.dead += oldIndex.size
+	}
+	if record.Deleted {
+		delete(s.index, record.Key)
+		s.dead += recordSize
+		return
+	}
+
+	s.index[record.Key] = &fileIndex{
+		offset:    offset,
+		size:      recordSize,
+		maxAge:    record.MaxAge,
+		cacheTime: time.Unix(0, record.CacheTime),
+	}
+}
+
+// appendLocked 调用前必须持有写锁
+func (s *FileBackend[K, V]) appendLocked(record *fileRecord[K, V]) error {
+	if s.file == nil {
+		return os.ErrClosed
+	}
+
+	payload, err := s.codec.Marshal(record)
+	if err != nil {
+		return err
+	}
+	buffer := make([]byte, fileRecordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(buffer, uint32(len(payload)))
+	copy(buffer[fileRecordHeaderSize:], payload)
+	if _, err = s.file.WriteAt(buffer, s.size); err != nil {
+		return err
+	}
+
+	s.apply(record, s.size, int64(len(buffer)))
+	s.size += int64(len(buffer))
+	// 记录已经写入，压缩失败不影响本次写入的结果，下次写入时重试
+	if err = s.compactLocked(); err != nil {
+		slog.Warn("compact cache file failed", "path", s.path, "error", err)
+	}
+	return nil
+}
+
+func (s *FileBackend[K, V]) Load(key K) (Entry[V], bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	indexPtr, ok := s.index[key]
+	if !ok || s.file == nil {
+		return Entry[V]{}, false
+	}
+
+	payload := make([]byte, indexPtr.size-fileRecordHeaderSize)
+	if _, err := s.file.ReadAt(payload, indexPtr.offset+fileRecordHeaderSize); err != nil {
+		slog.Warn("read cache file failed", "path", s.path, "error", err)
+		return Entry[V]{}, false
+	}
+	record := fileRecord[K, V]{}
+	if err := s.codec.Unmarshal(payload, &record); err != nil {
+		slog.Warn("decode cache record failed", "path", s.path, "error", err)
+		return Entry[V]{}, false
+	}
+
+	return Entry[V]{Value: record.Value, MaxAge: indexPtr.maxAge, CacheTime: indexPtr.cacheTime}, true
+}
+
+func (s *FileBackend[K, V]) Store(key K, entry Entry[V]) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.appendLocked(&fileRecord[K, V]{Key: key, Value: entry.Value, MaxAge: entry.MaxAge, CacheTime: entry.CacheTime.UnixNano()})
+}
+
+func (s *FileBackend[K, V]) Delete(key K) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.index[key]; !ok {
+		return nil
+	}
+	return s.appendLocked(&fileRecord[K, V]{Key: key, Deleted: true})
+}
+
+func (s *FileBackend[K, V]) Touch(key K, cacheTime time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if indexPtr, ok := s.index[key]; ok {
+		indexPtr.cacheTime = cacheTime
+	}
+}
+
+func (s *FileBackend[K, V]) Range(fn func(key K, entry Entry[V]) bool) {
+	s.mu.RLock()
+	keys := make([]K, 0, len(s.index))
+	for k := range s.index {
+		keys = append(keys, k)
+	}
+	s.mu.RUnlock()
+
+	for _, key := range keys {
+		entry, ok := s.Load(key)
+		if !ok {
+			continue
+		}
+		if !fn(key, entry) {
+			return
+		}
+	}
+}
+
+func (s *FileBackend[K, V]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.index)
+}
+
+func (s *FileBackend[K, V]) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	if err := s.file.Truncate(0); err != nil {
+		return err
+	}
+	s.size = 0
+	s.dead = 0
+	s.index = map[K]*fileIndex{}
+	return nil
+}
+
+func (s *FileBackend[K, V]) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	syncErr := s.file.Sync()
+	closeErr := s.file.Close()
+	s.file = nil
+	return errors.Join(syncErr, closeErr)
+}
+
+// compactLocked 失效数据过多时只保留有效记录重写文件
+func (s *FileBackend[K, V]) compactLocked() error {
+	if s.dead < fileCompactMinSize || s.dead*2 < s.size {
+		return nil
+	}
+
+	tmpPath := s.path + ".compact"
+	tmpFile, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	newIndex := make(map[K]*fileIndex, len(s.index))
+	var offset int64
+	for key, indexPtr := range s.index {
+		buffer := make([]byte, indexPtr.size)
+		if _, err = s.file.ReadAt(buffer, indexPtr.offset); err == nil {
+			_, err = tmpFile.WriteAt(buffer, offset)
+		}
+		if err != nil {
+			_ = tmpFile.Close()
+			_ = os.Remove(tmpPath)
+			return fmt.Errorf("compact cache file failed: %w", err)
+		}
+
+		newIndex[key] = &fileIndex{offset: offset, size: indexPtr.size, maxAge: indexPtr.maxAge, cacheTime: indexPtr.cacheTime}
+		offset += indexPtr.size
+	}
+
+	if err = tmpFile.Sync(); err == nil {
+		err = os.Rename(tmpPath, s.path)
+	}
+	if err != nil {
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("compact cache file failed: %w", err)
+	}
+
+	_ = s.file.Close()
+	s.file = tmpFile
+	s.index = newIndex
+	s.size = offset
+	s.dead = 0
+	return nil
+}
@@ -2,6 +2,9 @@ package cache
 
 import (
 	"context"
+	"hash/maphash"
+	"io"
+	"log/slog"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -11,69 +14,45 @@ import (
 type KVCacheGeneric[K comparable, V any] interface {
 	// Put maxAge单位second
 	Put(key K, data V, maxAge int64) K
+	// PutWithOptions 投放数据并指定标签和依赖
+	PutWithOptions(key K, data V, maxAge int64, options PutOptions[K]) K
 	Fetch(key K) V
 	Search(opr func(V) bool) V
 	Remove(key K)
+	// InvalidateTag 删除带有指定标签的全部数据，返回删除数量（不含级联删除的依赖条目）
+	InvalidateTag(tag string) int
 	GetAll() []V
 	ClearAll()
+	// Snapshot 导出未过期的数据及剩余有效期
+	Snapshot(w io.Writer) error
+	// Restore 导入 Snapshot 导出的数据，按剩余有效期继续计算过期
+	Restore(r io.Reader) error
 	Release()
 }
 
-// ExpiredCleanCallBackFuncGeneric 过期清理回调函数（泛型键）
+// ExpiredCleanCallBackFuncGeneric 过期清理回调函数（泛型键）。
+// 回调在条目删除后于锁外调用，回调中读取该条目得到零值；早期版本在删除前调用，需要条目值时应在写入时自行保存
 type ExpiredCleanCallBackFuncGeneric[K any] func(K)
 
-// genericPutInKVData 存放数据
-type genericPutInKVData[K comparable, V any] struct {
-	key    K
-	data   V
-	maxAge int64
-}
-
-// genericPutInKVResult 存放结果
-type genericPutInKVResult[K comparable] struct {
-	value K
-}
-
-// genericSearchKVData 搜索数据
-type genericSearchKVData[V any] struct {
-	opr func(V) bool
-}
-
-// genericSearchKVResult 搜索结果
-type genericSearchKVResult[V any] struct {
-	value V
-	found bool
-}
-
-// genericGetAllKVResult 获取所有结果
-type genericGetAllKVResult[V any] struct {
-	value []V
-}
+// keyLockCount 写操作按键哈希分配的锁数量，必须为 2 的幂
+const keyLockCount = 64
 
-// genericRemoveKVData 删除数据
-type genericRemoveKVData[K comparable] struct {
-	key K
-}
-
-// genericCacheKVData 缓存数据
-type genericCacheKVData[K comparable, V any] struct {
-	cacheData *genericPutInKVData[K, V]
-	cacheTime time.Time
-}
-
-// GenericKVCache 泛型内存缓存
+// GenericKVCache 泛型缓存，条目保存在 Backend 中，默认使用分片的进程内存储。
+// 读操作直接访问 Backend；写操作只锁定键所在的分段，不同键的写入可以并发执行。
+// 设置了容量或成本上限时，淘汰策略内部自行串行化，被淘汰的条目在写入方释放自身的锁后再逐个加锁删除。
 type GenericKVCache[K comparable, V any] struct {
-	commandChannel       chan commandData
 	cancelFunc           context.CancelFunc
 	cacheWg              sync.WaitGroup
-	localCacheData       sync.Map
-	pool                 sync.Pool
+	backend              Backend[K, V]
 	expiredCleanCallBack ExpiredCleanCallBackFuncGeneric[K]
-	rwLock               *sync.RWMutex
+	seed                 maphash.Seed
+	keyLocks             [keyLockCount]sync.Mutex
 	releasing            atomic.Bool
 	released             atomic.Bool
-	capacity             int
-	cleanupInterval      time.Duration
+	options              CacheOptions
+	policy               evictionPolicy[K]
+	index                *entryIndex[K]
+	indexed              atomic.Bool
 	metrics              cacheMetrics
 }
 
@@ -83,23 +62,31 @@ func NewGenericKVCache[K comparable, V any](cleanCallBack ExpiredCleanCallBackFu
 }
 
 func NewGenericKVCacheWithOptions[K comparable, V any](cleanCallBack ExpiredCleanCallBackFuncGeneric[K], options *CacheOptions) KVCacheGeneric[K, V] {
+	return newGenericKVCache[K, V](cleanCallBack, NewMemoryBackend[K, V](), options)
+}
+
+// NewGenericKVCacheWithBackend 使用指定存储创建泛型Cache对象，Release 时关闭存储
+func NewGenericKVCacheWithBackend[K comparable, V any](cleanCallBack ExpiredCleanCallBackFuncGeneric[K], backend Backend[K, V], options *CacheOptions) KVCacheGeneric[K, V] {
+	if backend == nil {
+		backend = NewMemoryBackend[K, V]()
+	}
+	return newGenericKVCache[K, V](cleanCallBack, backend, options)
+}
+
+func newGenericKVCache[K comparable, V any](cleanCallBack ExpiredCleanCallBackFuncGeneric[K], backend Backend[K, V], options *CacheOptions) *GenericKVCache[K, V] {
 	cacheCtx, cacheCancel := context.WithCancel(context.Background())
 	cacheOptions := normalizeCacheOptions(options)
 
 	cache := &GenericKVCache[K, V]{
-		commandChannel:       make(chan commandData, 100),
 		cancelFunc:           cacheCancel,
+		backend:              backend,
 		expiredCleanCallBack: cleanCallBack,
-		rwLock:               new(sync.RWMutex),
-		capacity:             cacheOptions.Capacity,
-		cleanupInterval:      cacheOptions.CleanupInterval,
-	}
-
-	// 启动多个worker处理命令
-	for range ConcurrentGoroutines {
-		cache.cacheWg.Add(1)
-		go cache.run()
+		seed:                 maphash.MakeSeed(),
+		options:              cacheOptions,
+		policy:               newEvictionPolicy[K](cacheOptions),
+		index:                newEntryIndex[K](),
 	}
+	cache.loadPolicy()
 
 	cache.cacheWg.Add(1)
 	go cache.checkTimeOut(cacheCtx)
@@ -107,78 +94,229 @@ func NewGenericKVCacheWithOptions[K comparable, V any](cleanCallBack ExpiredClea
 	return cache
 }
 
+// lockKey 返回写入 key 时需要持有的锁
+func (s *GenericKVCache[K, V]) lockKey(key K) *sync.Mutex {
+	return &s.keyLocks[maphash.Comparable(s.seed, key)&(keyLockCount-1)]
+}
+
 // Put 投放数据，返回数据的唯一标示
 func (s *GenericKVCache[K, V]) Put(key K, data V, maxAge int64) K {
-	dataPtr := &genericPutInKVData[K, V]{
-		key:    key,
-		data:   data,
-		maxAge: maxAge,
+	if s.released.Load() {
+		return key
 	}
 
-	result := s.sendCommand(commandData{action: putIn, value: dataPtr}).(*genericPutInKVResult[K])
-	return result.value
+	s.store(key, Entry[V]{Value: data, MaxAge: maxAge, CacheTime: time.Now()}, nil)
+	s.metrics.puts.Add(1)
+	return key
+}
+
+// PutWithOptions 投放数据并指定标签和依赖，返回数据的唯一标示
+func (s *GenericKVCache[K, V]) PutWithOptions(key K, data V, maxAge int64, options PutOptions[K]) K {
+	if s.released.Load() {
+		return key
+	}
+
+	s.store(key, Entry[V]{Value: data, MaxAge: maxAge, CacheTime: time.Now()}, &options)
+	s.metrics.puts.Add(1)
+	return key
+}
+
+func (s *GenericKVCache[K, V]) store(key K, entry Entry[V], options *PutOptions[K]) {
+	lock := s.lockKey(key)
+	lock.Lock()
+	dependents, victims := s.storeLocked(key, entry, options)
+	lock.Unlock()
+	dependents = append(dependents, s.evict(victims)...)
+	s.cascade(dependents)
+}
+
+// storeLocked 写入条目并更新索引，返回需要级联删除的条目和被淘汰的其它条目
+func (s *GenericKVCache[K, V]) storeLocked(key K, entry Entry[V], options *PutOptions[K]) ([]K, []K) {
+	admitted, dependents, victims := s.admitLocked(key, entry.Value)
+	if !admitted {
+		return dependents, victims
+	}
+
+	if options != nil && (len(options.Tags) > 0 || len(options.DependsOn) > 0) {
+		s.indexed.Store(true)
+	}
+	existed := false
+	if s.indexed.Load() {
+		_, existed = s.backend.Load(key)
+	}
+	if err := s.backend.Store(key, entry); err != nil {
+		slog.Warn("store cache entry failed", "key", key, "error", err)
+	}
+	if s.indexed.Load() {
+		indexOptions := PutOptions[K]{}
+		if options != nil {
+			indexOptions = *options
+		}
+		// 被依赖的条目重新写入时，依赖它的条目已经过时
+		dependents = append(dependents, s.index.replace(key, indexOptions, existed)...)
+	}
+	return dependents, victims
 }
 
 // Fetch 获取数据
 func (s *GenericKVCache[K, V]) Fetch(key K) V {
-	s.rwLock.RLock()
-	v, found := s.localCacheData.Load(key)
+	var zero V
+	if s.released.Load() {
+		return zero
+	}
+
+	entry, found := s.backend.Load(key)
 	if !found {
-		s.rwLock.RUnlock()
+		if s.policy != nil {
+			s.policy.miss(key)
+		}
 		s.metrics.misses.Add(1)
-		var zero V
 		return zero
 	}
 
-	dataPtr := v.(*genericCacheKVData[K, V])
-	if s.isExpired(dataPtr) {
-		s.rwLock.RUnlock()
-		s.rwLock.Lock()
-		s.localCacheData.Delete(key)
-		s.rwLock.Unlock()
-		s.metrics.expirations.Add(1)
+	if entry.expired(time.Now()) {
+		s.expire(key)
 		s.metrics.misses.Add(1)
-		var zero V
 		return zero
 	}
 
-	s.rwLock.RUnlock()
+	if s.policy != nil {
+		s.policy.access(key)
+	}
 	s.metrics.hits.Add(1)
-	return dataPtr.cacheData.data
+	return entry.Value
 }
 
 // Search 搜索数据
 func (s *GenericKVCache[K, V]) Search(opr func(V) bool) V {
-	if opr == nil {
-		var zero V
+	var zero V
+	if opr == nil || s.released.Load() {
 		return zero
 	}
 
-	dataPtr := &genericSearchKVData[V]{opr: opr}
-	result := s.sendCommand(commandData{action: search, value: dataPtr}).(*genericSearchKVResult[V])
-	if result.found {
-		s.metrics.hits.Add(1)
-	} else {
+	var foundKey K
+	found := false
+	result := zero
+	s.backend.Range(func(k K, entry Entry[V]) bool {
+		if opr(entry.Value) {
+			foundKey = k
+			result = entry.Value
+			found = true
+			return false
+		}
+		return true
+	})
+	if !found {
 		s.metrics.misses.Add(1)
+		return zero
 	}
-	return result.value
+
+	s.backend.Touch(foundKey, time.Now())
+	if s.policy != nil {
+		s.policy.access(foundKey)
+	}
+	s.metrics.hits.Add(1)
+	return result
 }
 
 // Remove 清除数据
 func (s *GenericKVCache[K, V]) Remove(key K) {
-	dataPtr := &genericRemoveKVData[K]{key: key}
-	s.sendCommand(commandData{action: remove, value: dataPtr})
+	if s.released.Load() {
+		return
+	}
+
+	lock := s.lockKey(key)
+	lock.Lock()
+	dependents := s.deleteLocked(key)
+	lock.Unlock()
+	s.cascade(dependents)
+}
+
+// InvalidateTag 删除带有指定标签的全部数据，依赖这些数据的条目随之删除
+func (s *GenericKVCache[K, V]) InvalidateTag(tag string) int {
+	if s.released.Load() || !s.indexed.Load() {
+		return 0
+	}
+
+	keys := s.index.keysOfTag(tag)
+	for _, key := range keys {
+		lock := s.lockKey(key)
+		lock.Lock()
+		dependents := s.deleteLocked(key)
+		lock.Unlock()
+		s.metrics.invalidations.Add(1)
+		s.cascade(dependents)
+	}
+	return len(keys)
+}
+
+// RemoveIf 删除键满足条件的数据，返回删除数量
+func (s *GenericKVCache[K, V]) RemoveIf(match func(key K) bool) int {
+	if match == nil || s.released.Load() {
+		return 0
+	}
+
+	keys := []K{}
+	s.backend.Range(func(k K, _ Entry[V]) bool {
+		if match(k) {
+			keys = append(keys, k)
+		}
+		return true
+	})
+	for _, key := range keys {
+		lock := s.lockKey(key)
+		lock.Lock()
+		dependents := s.deleteLocked(key)
+		lock.Unlock()
+		s.cascade(dependents)
+	}
+	return len(keys)
 }
 
 // GetAll 获取所有的数据
 func (s *GenericKVCache[K, V]) GetAll() []V {
-	result := s.sendCommand(commandData{action: getAll}).(*genericGetAllKVResult[V])
-	return result.value
+	ret := []V{}
+	if s.released.Load() {
+		return ret
+	}
+
+	keys := []K{}
+	s.backend.Range(func(k K, entry Entry[V]) bool {
+		keys = append(keys, k)
+		ret = append(ret, entry.Value)
+		return true
+	})
+	nowTime := time.Now()
+	for _, k := range keys {
+		s.backend.Touch(k, nowTime)
+	}
+	return ret
 }
 
 // ClearAll 清除所有数据
 func (s *GenericKVCache[K, V]) ClearAll() {
-	s.sendCommand(commandData{action: clearAll})
+	if s.released.Load() {
+		return
+	}
+
+	if s.policy != nil {
+		// 锁定全部分段，避免并发写入的条目在策略重置后仍留在存储中
+		for idx := range s.keyLocks {
+			s.keyLocks[idx].Lock()
+		}
+		defer func() {
+			for idx := range s.keyLocks {
+				s.keyLocks[idx].Unlock()
+			}
+		}()
+		s.policy.reset()
+	}
+	if err := s.backend.Clear(); err != nil {
+		slog.Warn("clear cache backend failed", "error", err)
+	}
+	if s.indexed.Load() {
+		s.index.reset()
+	}
 }
 
 // Release 释放Cache
@@ -188,184 +326,176 @@ func (s *GenericKVCache[K, V]) Release() {
 	}
 
 	s.cancelFunc()
-
-	// 为每个worker发送end命令
-	for i := 0; i < ConcurrentGoroutines; i++ {
-		s.sendCommand(commandData{action: end})
-	}
-
 	s.cacheWg.Wait()
-	close(s.commandChannel)
 	s.released.Store(true)
+	if err := s.backend.Close(); err != nil {
+		slog.Warn("close cache backend failed", "error", err)
+	}
 }
 
-func (s *GenericKVCache[K, V]) sendCommand(command commandData) any {
-	if s.released.Load() {
-		return nil
+func (s *GenericKVCache[K, V]) Stats() CacheStats {
+	entries := s.backend.Len()
+	var cost int64
+	if s.policy != nil {
+		cost = s.policy.totalCost()
 	}
+	return s.metrics.snapshot(entries, s.options, cost)
+}
 
-	var reply chan any
-	if v := s.pool.Get(); v != nil {
-		reply = v.(chan any)
-	} else {
-		reply = make(chan any)
+// deleteLocked 删除条目，返回依赖该条目的其它条目，由调用方释放锁后调用 cascade 删除
+func (s *GenericKVCache[K, V]) deleteLocked(key K) []K {
+	if err := s.backend.Delete(key); err != nil {
+		slog.Warn("delete cache entry failed", "key", key, "error", err)
 	}
-	defer s.pool.Put(reply)
-
-	command.result = reply
-	s.commandChannel <- command
-	return <-reply
+	if s.policy != nil {
+		s.policy.remove(key)
+	}
+	return s.dropIndex(key)
 }
 
-func (s *GenericKVCache[K, V]) run() {
-	defer s.cacheWg.Done()
-
-	for command := range s.commandChannel {
-		switch command.action {
-		case putIn:
-			s.rwLock.Lock()
-			key := command.value.(*genericPutInKVData[K, V]).key
-			if _, exists := s.localCacheData.Load(key); !exists {
-				s.enforceCapacityLocked()
-			}
-			dataPtr := &genericCacheKVData[K, V]{
-				cacheData: command.value.(*genericPutInKVData[K, V]),
-				cacheTime: time.Now(),
-			}
-			s.localCacheData.Store(dataPtr.cacheData.key, dataPtr)
-			s.rwLock.Unlock()
-			s.metrics.puts.Add(1)
-
-			result := &genericPutInKVResult[K]{value: dataPtr.cacheData.key}
-			command.result <- result
-
-		case search:
-			opr := command.value.(*genericSearchKVData[V]).opr
-
-			result := &genericSearchKVResult[V]{}
-			s.rwLock.Lock()
-			s.localCacheData.Range(func(k, v any) bool {
-				dataPtr := v.(*genericCacheKVData[K, V])
-				if opr(dataPtr.cacheData.data) {
-					dataPtr.cacheTime = time.Now()
-					s.localCacheData.Store(k, dataPtr)
-					result.value = dataPtr.cacheData.data
-					result.found = true
-					return false
-				}
-				return true
-			})
-			s.rwLock.Unlock()
-
-			command.result <- result
-
-		case remove:
-			key := command.value.(*genericRemoveKVData[K]).key
-			s.localCacheData.Delete(key)
-			command.result <- true
-
-		case getAll:
-			result := &genericGetAllKVResult[V]{value: []V{}}
-			s.rwLock.Lock()
-			s.localCacheData.Range(func(k, v any) bool {
-				dataPtr := v.(*genericCacheKVData[K, V])
-				dataPtr.cacheTime = time.Now()
-				result.value = append(result.value, dataPtr.cacheData.data)
-				return true
-			})
-			s.rwLock.Unlock()
-			command.result <- result
-
-		case clearAll:
-			s.rwLock.Lock()
-			s.localCacheData = sync.Map{}
-			s.rwLock.Unlock()
-			command.result <- true
-
-		case checkTimeOut:
-			s.rwLock.Lock()
-			keys := s.getExpiredKeys()
-			for _, v := range keys {
-				if s.expiredCleanCallBack != nil {
-					s.expiredCleanCallBack(v)
-				}
-				s.localCacheData.Delete(v)
-				s.metrics.expirations.Add(1)
-			}
-			s.rwLock.Unlock()
+func (s *GenericKVCache[K, V]) dropIndex(key K) []K {
+	if !s.indexed.Load() {
+		return nil
+	}
+	return s.index.drop(key)
+}
 
-		case end:
-			command.result <- true
-			return
-		}
+// cascade 级联删除依赖条目，每次只持有一个键的锁；索引在删除时移除，循环依赖不会重复处理
+func (s *GenericKVCache[K, V]) cascade(keys []K) {
+	for len(keys) > 0 {
+		key := keys[len(keys)-1]
+		keys = keys[:len(keys)-1]
+
+		lock := s.lockKey(key)
+		lock.Lock()
+		keys = append(keys, s.deleteLocked(key)...)
+		lock.Unlock()
+		s.metrics.invalidations.Add(1)
 	}
 }
 
-func (s *GenericKVCache[K, V]) Stats() CacheStats {
-	s.rwLock.RLock()
-	entries := countSyncMap(&s.localCacheData)
-	s.rwLock.RUnlock()
-	return s.metrics.snapshot(entries, s.capacity)
+// expire 加锁后重新检查，避免删除并发写入的新数据，返回是否删除
+func (s *GenericKVCache[K, V]) expire(key K) bool {
+	lock := s.lockKey(key)
+	lock.Lock()
+	entry, found := s.backend.Load(key)
+	if !found || !entry.expired(time.Now()) {
+		lock.Unlock()
+		return false
+	}
+	dependents := s.deleteLocked(key)
+	lock.Unlock()
+
+	s.metrics.expirations.Add(1)
+	s.cascade(dependents)
+	return true
 }
 
 func (s *GenericKVCache[K, V]) getExpiredKeys() []K {
 	keys := []K{}
-	s.localCacheData.Range(func(k, v any) bool {
-		dataPtr := v.(*genericCacheKVData[K, V])
-		if dataPtr.cacheData.maxAge != ForeverAgeValue {
-			elapse := int64(time.Since(dataPtr.cacheTime).Seconds())
-			if elapse > dataPtr.cacheData.maxAge {
-				keys = append(keys, k.(K))
-			}
+	nowTime := time.Now()
+	s.backend.Range(func(k K, entry Entry[V]) bool {
+		if entry.expired(nowTime) {
+			keys = append(keys, k)
 		}
 		return true
 	})
 	return keys
 }
 
+// cleanExpired 删除过期数据，删除后在锁外回调，回调中可以继续访问缓存
+func (s *GenericKVCache[K, V]) cleanExpired() {
+	for _, key := range s.getExpiredKeys() {
+		if s.expire(key) && s.expiredCleanCallBack != nil {
+			s.expiredCleanCallBack(key)
+		}
+	}
+}
+
 func (s *GenericKVCache[K, V]) checkTimeOut(ctx context.Context) {
 	defer s.cacheWg.Done()
 
-	timeOutTimer := time.NewTicker(s.cleanupInterval)
+	timeOutTimer := time.NewTicker(s.options.CleanupInterval)
 	defer timeOutTimer.Stop()
 
 	for {
 		select {
 		case <-timeOutTimer.C:
-			s.commandChannel <- commandData{action: checkTimeOut}
+			s.cleanExpired()
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
-func (s *GenericKVCache[K, V]) isExpired(data *genericCacheKVData[K, V]) bool {
-	if data.cacheData.maxAge != ForeverAgeValue {
-		return int64(time.Since(data.cacheTime).Seconds()) > data.cacheData.maxAge
+func (s *GenericKVCache[K, V]) costOf(data V) int64 {
+	if s.options.Cost == nil {
+		return 1
 	}
-	return false
+	return s.options.Cost(data)
 }
 
-func (s *GenericKVCache[K, V]) enforceCapacityLocked() {
-	if s.capacity <= 0 || countSyncMap(&s.localCacheData) < s.capacity {
-		return
+// admitLocked 交由淘汰策略决定是否接纳，当前条目未被接纳时直接删除；
+// 返回需要级联删除的条目和被淘汰的其它条目，其它条目由调用方释放锁后调用 evict 删除
+func (s *GenericKVCache[K, V]) admitLocked(key K, data V) (bool, []K, []K) {
+	if s.policy == nil {
+		return true, nil, nil
 	}
 
-	var oldestKey K
-	var oldestTime time.Time
-	var found bool
-	s.localCacheData.Range(func(k, v any) bool {
-		dataPtr := v.(*genericCacheKVData[K, V])
-		if !found || dataPtr.cacheTime.Before(oldestTime) {
-			oldestKey = k.(K)
-			oldestTime = dataPtr.cacheTime
-			found = true
+	var dependents []K
+	victims, admitted := s.policy.add(key, s.costOf(data))
+	others := victims[:0]
+	for _, victim := range victims {
+		if victim != key {
+			others = append(others, victim)
+			continue
+		}
+		if err := s.backend.Delete(key); err != nil {
+			slog.Warn("delete cache entry failed", "key", key, "error", err)
+		}
+		dependents = append(dependents, s.dropIndex(key)...)
+	}
+	if !admitted {
+		s.metrics.rejections.Add(1)
+	}
+	return admitted, dependents, others
+}
+
+// evict 逐个锁定并删除被淘汰的条目，加锁前已被重新写入的条目保留，返回需要级联删除的条目
+func (s *GenericKVCache[K, V]) evict(victims []K) []K {
+	var dependents []K
+	for _, victim := range victims {
+		lock := s.lockKey(victim)
+		lock.Lock()
+		if !s.policy.contains(victim) {
+			if err := s.backend.Delete(victim); err != nil {
+				slog.Warn("delete cache entry failed", "key", victim, "error", err)
+			}
+			dependents = append(dependents, s.dropIndex(victim)...)
+			s.metrics.evictions.Add(1)
 		}
+		lock.Unlock()
+	}
+	return dependents
+}
+
+// loadPolicy 存储中已有的条目（如 FileBackend 重启后）加入淘汰策略
+func (s *GenericKVCache[K, V]) loadPolicy() {
+	if s.policy == nil {
+		return
+	}
+
+	type loadedEntry struct {
+		key  K
+		data V
+	}
+	entries := []loadedEntry{}
+	s.backend.Range(func(k K, entry Entry[V]) bool {
+		entries = append(entries, loadedEntry{key: k, data: entry.Value})
 		return true
 	})
-
-	if found {
-		s.localCacheData.Delete(oldestKey)
-		s.metrics.evictions.Add(1)
+	for _, entry := range entries {
+		_, _, victims := s.admitLocked(entry.key, entry.data)
+		s.evict(victims)
 	}
 }
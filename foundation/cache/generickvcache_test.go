@@ -250,3 +250,63 @@ func TestGenericKVCacheOptionsCapacityAndStats(t *testing.T) {
 		t.Fatalf("unexpected hit/miss stats: %+v", stats)
 	}
 }
+
+func TestGenericKVCacheConcurrentAccess(t *testing.T) {
+	for _, options := range []*CacheOptions{nil, {Capacity: 64}, {Capacity: 64, Eviction: EvictionTinyLFU}} {
+		cache := NewGenericKVCacheWithOptions[int, int](nil, options).(*GenericKVCache[int, int])
+
+		var wg sync.WaitGroup
+		for worker := 0; worker < 8; worker++ {
+			wg.Add(1)
+			go func(worker int) {
+				defer wg.Done()
+				for idx := 0; idx < 2000; idx++ {
+					key := (worker*31 + idx) % 128
+					switch idx % 7 {
+					case 0:
+						cache.Remove(key)
+					case 1, 2:
+						cache.Put(key, idx, 10)
+					default:
+						cache.Fetch(key)
+					}
+				}
+			}(worker)
+		}
+		wg.Wait()
+
+		stats := cache.Stats()
+		if options != nil && stats.Entries > options.Capacity {
+			t.Errorf("Entries = %d, exceeds capacity %d", stats.Entries, options.Capacity)
+		}
+		if stats.Puts == 0 {
+			t.Errorf("unexpected Puts = %d", stats.Puts)
+		}
+		cache.Release()
+	}
+}
+
+func TestGenericKVCacheCleanCallbackCanAccessCache(t *testing.T) {
+	var cache *GenericKVCache[string, string]
+	done := make(chan string, 1)
+	cache = NewGenericKVCacheWithOptions[string, string](func(key string) {
+		// 回调在锁外执行，可以重新写入缓存
+		cache.Put(key, "reloaded", ForeverAgeValue)
+		done <- key
+	}, &CacheOptions{CleanupInterval: 50 * time.Millisecond}).(*GenericKVCache[string, string])
+	defer cache.Release()
+
+	cache.Put("key", "value", 0)
+	time.Sleep(1100 * time.Millisecond)
+	select {
+	case key := <-done:
+		if key != "key" {
+			t.Errorf("callback key = %v, want key", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected timeout cleanup callback to run")
+	}
+	if val := cache.Fetch("key"); val != "reloaded" {
+		t.Errorf("Fetch() = %v, want reloaded", val)
+	}
+}
@@ -0,0 +1,338 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/muidea/magicCommon/event"
+	"github.com/muidea/magicCommon/foundation/util"
+)
+
+// InvalidationScope 失效范围
+type InvalidationScope string
+
+const (
+	// InvalidateKey 单个键
+	InvalidateKey InvalidationScope = "key"
+	// InvalidatePrefix 指定前缀的全部键
+	InvalidatePrefix InvalidationScope = "prefix"
+	// InvalidateAll 全部数据
+	InvalidateAll InvalidationScope = "all"
+)
+
+const (
+	// InvalidationEventPrefix 失效事件ID前缀，完整ID为前缀加缓存名称
+	InvalidationEventPrefix = "/cache/invalidate/"
+	// InvalidationDestination 失效事件的目标，匹配所有 Invalidator
+	InvalidationDestination = invalidatorIDPrefix + "#"
+
+	invalidatorIDPrefix = "/cache/invalidator/"
+)
+
+// Invalidation 失效消息，字段均为基础类型，可以经过序列化的 Hub 桥接转发
+type Invalidation struct {
+	Cache  string            `json:"cache"`
+	Scope  InvalidationScope `json:"scope"`
+	Key    string            `json:"key,omitempty"`
+	Origin string            `json:"origin"`
+	// Version 发布方的版本戳，接收方忽略不新于已记录版本的消息
+	Version int64 `json:"version,string"`
+}
+
+// InvalidationTarget 接收失效的本地缓存，*MemoryKVCache 和 *GenericKVCache[string, V] 均满足该接口
+type InvalidationTarget interface {
+	Remove(key string)
+	RemoveIf(match func(key string) bool) int
+	ClearAll()
+}
+
+// InvalidationStats 失效统计
+type InvalidationStats struct {
+	Published int64
+	Applied   int64
+	// Ignored 因版本过旧被忽略的消息数量
+	Ignored int64
+}
+
+type InvalidatorOption func(*invalidatorOptions)
+
+type invalidatorOptions struct {
+	origin           string
+	versionRetention int64
+}
+
+// WithInvalidatorOrigin 指定实例标识，默认随机生成
+func WithInvalidatorOrigin(origin string) InvalidatorOption {
+	return func(o *invalidatorOptions) {
+		if origin != "" {
+			o.origin = origin
+		}
+	}
+}
+
+// WithVersionRetention 键版本的保留时间（秒），超过该时间到达的乱序消息不再被识别，默认 TenMinutesAgeValue
+func WithVersionRetention(seconds int64) InvalidatorOption {
+	return func(o *invalidatorOptions) {
+		if seconds > 0 {
+			o.versionRetention = seconds
+		}
+	}
+}
+
+// Invalidator 将本地缓存接入失效总线。
+// 本地失效会立即生效并以事件发布，其它实例收到后删除对应数据；
+// 版本戳由纳秒时间与本地已见过的最大版本共同决定，跨实例比较依赖各实例时钟基本同步。
+type Invalidator struct {
+	name     string
+	origin   string
+	eventID  string
+	target   InvalidationTarget
+	observer event.SimpleObserver
+	hub      event.Hub
+	options  invalidatorOptions
+	clock    atomic.Int64
+	closed   atomic.Bool
+
+	versionLock    sync.Mutex
+	keyVersions    *GenericKVCache[string, int64]
+	prefixVersions map[string]int64
+	allVersion     int64
+
+	published atomic.Int64
+	applied   atomic.Int64
+	ignored   atomic.Int64
+}
+
+// NewInvalidator 创建 Invalidator 并订阅名称为 name 的缓存的失效事件
+func NewInvalidator(name string, target InvalidationTarget, hub event.Hub, opts ...InvalidatorOption) *Invalidator {
+	options := invalidatorOptions{
+		origin:           strings.ToLower(util.RandomAlphanumeric(16)),
+		versionRetention: TenMinutesAgeValue,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	invalidator := &Invalidator{
+		name:           name,
+		origin:         options.origin,
+		eventID:        InvalidationEventPrefix + name,
+		target:         target,
+		hub:            hub,
+		options:        options,
+		keyVersions:    newGenericKVCache[string, int64](nil, NewMemoryBackend[string, int64](), nil),
+		prefixVersions: map[string]int64{},
+	}
+	invalidator.observer = event.NewSimpleObserver(invalidatorIDPrefix+options.origin, hub)
+	invalidator.observer.Subscribe(invalidator.eventID, invalidator.onInvalidation)
+	return invalidator
+}
+
+// Origin 实例标识
+func (s *Invalidator) Origin() string {
+	return s.origin
+}
+
+// Invalidate 使单个键失效
+func (s *Invalidator) Invalidate(key string) {
+	s.publish(InvalidateKey, key)
+}
+
+// InvalidatePrefix 使指定前缀的全部键失效
+func (s *Invalidator) InvalidatePrefix(prefix string) {
+	s.publish(InvalidatePrefix, prefix)
+}
+
+// InvalidateAll 使全部数据失效
+func (s *Invalidator) InvalidateAll() {
+	s.publish(InvalidateAll, "")
+}
+
+// RecordWrite 记录本地写入的版本，版本更早的失效消息不会删除该数据，返回写入版本
+func (s *Invalidator) RecordWrite(key string) int64 {
+	version := s.nextVersion()
+	s.versionLock.Lock()
+	s.recordKeyLocked(key, version)
+	s.versionLock.Unlock()
+	return version
+}
+
+func (s *Invalidator) Stats() InvalidationStats {
+	return InvalidationStats{
+		Published: s.published.Load(),
+		Applied:   s.applied.Load(),
+		Ignored:   s.ignored.Load(),
+	}
+}
+
+// Close 取消订阅，不影响目标缓存
+func (s *Invalidator) Close() {
+	if !s.closed.CompareAndSwap(false, true) {
+		return
+	}
+
+	s.observer.Unsubscribe(s.eventID)
+	s.keyVersions.Release()
+}
+
+func (s *Invalidator) publish(scope InvalidationScope, key string) {
+	if s.closed.Load() {
+		return
+	}
+
+	invalidation := Invalidation{Cache: s.name, Scope: scope, Key: key, Origin: s.origin, Version: s.nextVersion()}
+	s.apply(invalidation)
+	s.hub.Post(event.NewEvent(s.eventID, s.origin, InvalidationDestination, event.NewHeader(), invalidation))
+	s.published.Add(1)
+}
+
+func (s *Invalidator) onInvalidation(ev event.Event, _ event.Result) {
+	invalidation, err := decodeInvalidation(ev.Data())
+	if err != nil {
+		slog.Warn("illegal cache invalidation", "event_id", ev.ID(), "source", ev.Source(), "error", err)
+		return
+	}
+	// 本实例发布的消息已在发布时生效，经桥接回流时忽略
+	if invalidation.Origin == s.origin || invalidation.Cache != s.name || s.closed.Load() {
+		return
+	}
+
+	s.observe(invalidation.Version)
+	s.apply(invalidation)
+}
+
+func (s *Invalidator) apply(invalidation Invalidation) {
+	s.versionLock.Lock()
+	defer s.versionLock.Unlock()
+
+	version := invalidation.Version
+	switch invalidation.Scope {
+	case InvalidateKey:
+		if version <= s.keyVersionLocked(invalidation.Key) {
+			s.ignored.Add(1)
+			return
+		}
+		s.target.Remove(invalidation.Key)
+		s.recordKeyLocked(invalidation.Key, version)
+	case InvalidatePrefix:
+		if version <= s.coveringVersionLocked(invalidation.Key) {
+			s.ignored.Add(1)
+			return
+		}
+		s.target.RemoveIf(func(key string) bool {
+			return strings.HasPrefix(key, invalidation.Key) && s.recordedKeyVersion(key) < version
+		})
+		s.prefixVersions[invalidation.Key] = version
+		s.prunePrefixesLocked(version)
+	case InvalidateAll:
+		if version <= s.allVersion {
+			s.ignored.Add(1)
+			return
+		}
+		s.target.RemoveIf(func(key string) bool {
+			return s.recordedKeyVersion(key) < version
+		})
+		s.allVersion = version
+		for prefix, prefixVersion := range s.prefixVersions {
+			if prefixVersion <= version {
+				delete(s.prefixVersions, prefix)
+			}
+		}
+	default:
+		slog.Warn("illegal cache invalidation scope", "cache", s.name, "scope", invalidation.Scope)
+		return
+	}
+	s.applied.Add(1)
+}
+
+// prunePrefixesLocked 删除超过保留时间的前缀版本，与键版本的保留时间一致
+func (s *Invalidator) prunePrefixesLocked(current int64) {
+	expired := current - s.options.versionRetention*int64(time.Second)
+	for prefix, version := range s.prefixVersions {
+		if version < expired {
+			delete(s.prefixVersions, prefix)
+		}
+	}
+}
+
+func (s *Invalidator) recordKeyLocked(key string, version int64) {
+	if version > s.recordedKeyVersion(key) {
+		s.keyVersions.Put(key, version, s.options.versionRetention)
+	}
+}
+
+func (s *Invalidator) recordedKeyVersion(key string) int64 {
+	return s.keyVersions.Fetch(key)
+}
+
+// keyVersionLocked 键当前生效的版本，包括覆盖该键的前缀和全部失效
+func (s *Invalidator) keyVersionLocked(key string) int64 {
+	return max(s.recordedKeyVersion(key), s.coveringVersionLocked(key))
+}
+
+// coveringVersionLocked 覆盖 key 的前缀失效和全部失效中的最大版本
+func (s *Invalidator) coveringVersionLocked(key string) int64 {
+	ret := s.allVersion
+	for prefix, version := range s.prefixVersions {
+		if strings.HasPrefix(key, prefix) {
+			ret = max(ret, version)
+		}
+	}
+	return ret
+}
+
+func (s *Invalidator) nextVersion() int64 {
+	for {
+		last := s.clock.Load()
+		next := max(last+1, time.Now().UnixNano())
+		if s.clock.CompareAndSwap(last, next) {
+			return next
+		}
+	}
+}
+
+// observe 收到其它实例的版本后推进本地时钟，保证之后的本地版本更新
+func (s *Invalidator) observe(version int64) {
+	for {
+		last := s.clock.Load()
+		if version <= last || s.clock.CompareAndSwap(last, version) {
+			return
+		}
+	}
+}
+
+// decodeInvalidation 兼容本地投递的结构体以及桥接反序列化后的 map、JSON
+func decodeInvalidation(data any) (Invalidation, error) {
+	switch val := data.(type) {
+	case Invalidation:
+		return val, nil
+	case *Invalidation:
+		if val == nil {
+			return Invalidation{}, fmt.Errorf("nil invalidation")
+		}
+		return *val, nil
+	case []byte:
+		ret := Invalidation{}
+		err := json.Unmarshal(val, &ret)
+		return ret, err
+	case string:
+		ret := Invalidation{}
+		err := json.Unmarshal([]byte(val), &ret)
+		return ret, err
+	case map[string]any:
+		byteVal, err := json.Marshal(val)
+		if err != nil {
+			return Invalidation{}, err
+		}
+		ret := Invalidation{}
+		err = json.Unmarshal(byteVal, &ret)
+		return ret, err
+	default:
+		return Invalidation{}, fmt.Errorf("unsupported invalidation data %T", data)
+	}
+}
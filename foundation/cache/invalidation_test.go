@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/muidea/magicCommon/event"
+)
+
+func newInvalidationReplica(name string, hub event.Hub) (*MemoryKVCache, *Invalidator) {
+	cache := NewKVCache(nil).(*MemoryKVCache)
+	return cache, NewInvalidator(name, cache, hub)
+}
+
+func TestInvalidatorLocalHub(t *testing.T) {
+	hub := event.NewHub(10)
+	defer hub.Terminate(context.Background())
+
+	cacheA, invalidatorA := newInvalidationReplica("user", hub)
+	cacheB, invalidatorB := newInvalidationReplica("user", hub)
+	defer cacheA.Release()
+	defer cacheB.Release()
+	defer invalidatorA.Close()
+	defer invalidatorB.Close()
+
+	for _, cacheVal := range []*MemoryKVCache{cacheA, cacheB} {
+		cacheVal.Put("user:1", "alice", ForeverAgeValue)
+		cacheVal.Put("user:2", "bob", ForeverAgeValue)
+		cacheVal.Put("role:1", "admin", ForeverAgeValue)
+	}
+
+	invalidatorA.Invalidate("user:1")
+	assert.Nil(t, cacheA.Fetch("user:1"))
+	assert.Eventually(t, func() bool { return cacheB.Fetch("user:1") == nil }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "bob", cacheB.Fetch("user:2"))
+
+	invalidatorB.InvalidatePrefix("user:")
+	assert.Eventually(t, func() bool { return cacheA.Fetch("user:2") == nil }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "admin", cacheA.Fetch("role:1"))
+
+	invalidatorA.InvalidateAll()
+	assert.Eventually(t, func() bool { return cacheB.Fetch("role:1") == nil }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(2), invalidatorA.Stats().Published)
+	assert.Equal(t, int64(3), invalidatorA.Stats().Applied)
+}
+
+func TestInvalidatorIgnoresOutOfOrder(t *testing.T) {
+	hub := event.NewHub(10)
+	defer hub.Terminate(context.Background())
+
+	cache, invalidator := newInvalidationReplica("order", hub)
+	defer cache.Release()
+	defer invalidator.Close()
+
+	deliver := func(scope InvalidationScope, key string, version int64) {
+		ev := event.NewEvent(InvalidationEventPrefix+"order", "remote", InvalidationDestination, event.NewHeader(),
+			Invalidation{Cache: "order", Scope: scope, Key: key, Origin: "remote", Version: version})
+		invalidator.onInvalidation(ev, nil)
+	}
+
+	cache.Put("order:1", "v1", ForeverAgeValue)
+	deliver(InvalidateKey, "order:1", 200)
+	assert.Nil(t, cache.Fetch("order:1"))
+
+	// 版本更早的失效消息迟到时忽略，前缀失效不删除版本更新的键
+	cache.Put("order:1", "v2", ForeverAgeValue)
+	deliver(InvalidateKey, "order:1", 100)
+	deliver(InvalidatePrefix, "order:", 150)
+	assert.Equal(t, "v2", cache.Fetch("order:1"))
+	assert.Equal(t, int64(1), invalidator.Stats().Ignored)
+	deliver(InvalidatePrefix, "order:", 120)
+	assert.Equal(t, int64(2), invalidator.Stats().Ignored)
+
+	// 本地写入晚于失效消息的版本时保留数据
+	cache.Put("order:2", "v1", ForeverAgeValue)
+	written := invalidator.RecordWrite("order:2")
+	deliver(InvalidateAll, "", written-1)
+	assert.Equal(t, "v1", cache.Fetch("order:2"))
+	assert.Nil(t, cache.Fetch("order:1"))
+	deliver(InvalidateKey, "order:2", written-2)
+	assert.Equal(t, "v1", cache.Fetch("order:2"))
+}
+
+// jsonBridge 将源 Hub 的失效事件序列化后转发到目标 Hub，模拟跨进程桥接；
+// ID 需要匹配 InvalidationDestination 才能收到失效事件
+type jsonBridge struct {
+	target event.Hub
+}
+
+func (s *jsonBridge) ID() string {
+	return "/cache/invalidator/bridge"
+}
+
+func (s *jsonBridge) Notify(ev event.Event, _ event.Result) {
+	byteVal, _ := json.Marshal(ev.Data())
+	data := map[string]any{}
+	_ = json.Unmarshal(byteVal, &data)
+	s.target.Post(event.NewEvent(ev.ID(), ev.Source(), ev.Destination(), event.NewHeader(), data))
+}
+
+func TestInvalidatorThroughBridge(t *testing.T) {
+	hubA := event.NewHub(10)
+	hubB := event.NewHub(10)
+	defer hubA.Terminate(context.Background())
+	defer hubB.Terminate(context.Background())
+	hubA.Subscribe(InvalidationEventPrefix+"#", &jsonBridge{target: hubB})
+
+	cacheA, invalidatorA := newInvalidationReplica("session", hubA)
+	cacheB, invalidatorB := newInvalidationReplica("session", hubB)
+	defer cacheA.Release()
+	defer cacheB.Release()
+	defer invalidatorA.Close()
+	defer invalidatorB.Close()
+
+	cacheB.Put("session:1", "token", ForeverAgeValue)
+	cacheB.Put("session:2", "token", ForeverAgeValue)
+	invalidatorA.Invalidate("session:1")
+	assert.Eventually(t, func() bool { return cacheB.Fetch("session:1") == nil }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "token", cacheB.Fetch("session:2"))
+	assert.Equal(t, int64(1), invalidatorB.Stats().Applied)
+}
@@ -0,0 +1,273 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrNotFound 数据不存在，Loader 返回该错误时按 NegativeMaxAge 缓存
+var ErrNotFound = errors.New("cache: not found")
+
+// LoaderFunc 加载单个数据
+type LoaderFunc[K comparable, V any] func(ctx context.Context, key K) (V, error)
+
+// BulkLoaderFunc 批量加载数据，返回结果中不存在的键视为 ErrNotFound
+type BulkLoaderFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+// LoadingOptions LoadingCache 配置，时间单位与 Put 的 maxAge 一致为秒
+type LoadingOptions struct {
+	// MaxAge 数据有效期，默认 TenMinutesAgeValue
+	MaxAge int64
+	// RefreshAfter 数据加载超过该时间后，再次访问时在后台提前刷新，0 表示不提前刷新
+	RefreshAfter int64
+	// NegativeMaxAge 不存在结果的缓存时间，0 表示不缓存
+	NegativeMaxAge int64
+	// LoadTimeout 单次加载超时时间，0 表示不限制
+	LoadTimeout time.Duration
+	// CacheOptions 底层缓存配置
+	CacheOptions *CacheOptions
+}
+
+// LoadingStats LoadingCache 统计
+type LoadingStats struct {
+	CacheStats
+	Loads        int64
+	LoadErrors   int64
+	Refreshes    int64
+	NegativeHits int64
+}
+
+type loadingEntry[V any] struct {
+	value    V
+	notFound bool
+	loadedAt time.Time
+}
+
+// LoadingCache 带加载函数的缓存，基于 GenericKVCache。
+// 同一个键的并发未命中只触发一次加载，加载结果由所有等待方共享。
+type LoadingCache[K comparable, V any] struct {
+	cache      *GenericKVCache[K, loadingEntry[V]]
+	loader     LoaderFunc[K, V]
+	bulkLoader BulkLoaderFunc[K, V]
+	options    LoadingOptions
+	group      singleflight.Group
+	refreshing sync.Map
+
+	loads        atomic.Int64
+	loadErrors   atomic.Int64
+	refreshes    atomic.Int64
+	negativeHits atomic.Int64
+}
+
+// NewLoadingCache 创建 LoadingCache，bulkLoader 可以为 nil，此时批量获取逐个加载
+func NewLoadingCache[K comparable, V any](loader LoaderFunc[K, V], bulkLoader BulkLoaderFunc[K, V], options *LoadingOptions) *LoadingCache[K, V] {
+	loadingOptions := LoadingOptions{}
+	if options != nil {
+		loadingOptions = *options
+	}
+	if loadingOptions.MaxAge == 0 {
+		loadingOptions.MaxAge = TenMinutesAgeValue
+	}
+
+	return &LoadingCache[K, V]{
+		cache:      newGenericKVCache[K, loadingEntry[V]](nil, NewMemoryBackend[K, loadingEntry[V]](), loadingOptions.CacheOptions),
+		loader:     loader,
+		bulkLoader: bulkLoader,
+		options:    loadingOptions,
+	}
+}
+
+func flightKey[K comparable](key K) string {
+	return fmt.Sprintf("%#v", key)
+}
+
+// Get 获取数据，未命中时加载；数据不存在返回 ErrNotFound
+func (s *LoadingCache[K, V]) Get(ctx context.Context, key K) (V, error) {
+	entry := s.cache.Fetch(key)
+	if !entry.loadedAt.IsZero() {
+		if entry.notFound {
+			s.negativeHits.Add(1)
+			var zero V
+			return zero, ErrNotFound
+		}
+		s.refreshAhead(key, entry)
+		return entry.value, nil
+	}
+
+	return s.load(ctx, key)
+}
+
+// GetAll 批量获取数据，返回结果中只包含存在的数据
+func (s *LoadingCache[K, V]) GetAll(ctx context.Context, keys []K) (map[K]V, error) {
+	ret := make(map[K]V, len(keys))
+	missKeys := []K{}
+	for _, key := range keys {
+		entry := s.cache.Fetch(key)
+		if entry.loadedAt.IsZero() {
+			missKeys = append(missKeys, key)
+			continue
+		}
+		if entry.notFound {
+			s.negativeHits.Add(1)
+			continue
+		}
+		s.refreshAhead(key, entry)
+		ret[key] = entry.value
+	}
+	if len(missKeys) == 0 {
+		return ret, nil
+	}
+
+	if s.bulkLoader == nil {
+		for _, key := range missKeys {
+			value, err := s.load(ctx, key)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			ret[key] = value
+		}
+		return ret, nil
+	}
+
+	loadCtx, cancel := s.loadContext(ctx)
+	defer cancel()
+	s.loads.Add(1)
+	values, err := s.bulkLoader(loadCtx, missKeys)
+	if err != nil {
+		s.loadErrors.Add(1)
+		return nil, err
+	}
+	for _, key := range missKeys {
+		value, ok := values[key]
+		if !ok {
+			s.storeNotFound(key)
+			continue
+		}
+		s.store(key, value)
+		ret[key] = value
+	}
+	return ret, nil
+}
+
+// Put 直接写入数据
+func (s *LoadingCache[K, V]) Put(key K, value V) {
+	s.store(key, value)
+}
+
+// Refresh 重新加载数据，加载失败时保留原有数据
+func (s *LoadingCache[K, V]) Refresh(ctx context.Context, key K) (V, error) {
+	s.refreshes.Add(1)
+	return s.load(ctx, key)
+}
+
+// Invalidate 删除数据，下次访问时重新加载
+func (s *LoadingCache[K, V]) Invalidate(key K) {
+	s.cache.Remove(key)
+}
+
+// InvalidateAll 清除全部数据
+func (s *LoadingCache[K, V]) InvalidateAll() {
+	s.cache.ClearAll()
+}
+
+func (s *LoadingCache[K, V]) Stats() LoadingStats {
+	return LoadingStats{
+		CacheStats:   s.cache.Stats(),
+		Loads:        s.loads.Load(),
+		LoadErrors:   s.loadErrors.Load(),
+		Refreshes:    s.refreshes.Load(),
+		NegativeHits: s.negativeHits.Load(),
+	}
+}
+
+func (s *LoadingCache[K, V]) Release() {
+	s.cache.Release()
+}
+
+// load 合并同一个键的并发加载，加载不受单个调用方取消的影响，调用方取消时只停止等待
+func (s *LoadingCache[K, V]) load(ctx context.Context, key K) (V, error) {
+	var zero V
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	resultCh := s.group.DoChan(flightKey(key), func() (any, error) {
+		loadCtx, cancel := s.loadContext(context.WithoutCancel(ctx))
+		defer cancel()
+
+		s.loads.Add(1)
+		value, err := s.loader(loadCtx, key)
+		if errors.Is(err, ErrNotFound) {
+			s.storeNotFound(key)
+			return zero, ErrNotFound
+		}
+		if err != nil {
+			s.loadErrors.Add(1)
+			return zero, err
+		}
+		s.store(key, value)
+		return value, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case result := <-resultCh:
+		if result.Err != nil {
+			return zero, result.Err
+		}
+		// V 为接口类型且加载结果为 nil 时 Val 为 nil，返回零值
+		val, _ := result.Val.(V)
+		return val, nil
+	}
+}
+
+// refreshAhead 数据临近过期时在后台刷新，同一个键同时只有一个刷新
+func (s *LoadingCache[K, V]) refreshAhead(key K, entry loadingEntry[V]) {
+	if s.options.RefreshAfter <= 0 || time.Since(entry.loadedAt) < time.Duration(s.options.RefreshAfter)*time.Second {
+		return
+	}
+	if _, loaded := s.refreshing.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+
+	s.refreshes.Add(1)
+	go func() {
+		defer s.refreshing.Delete(key)
+		if _, err := s.load(context.Background(), key); err != nil && !errors.Is(err, ErrNotFound) {
+			slog.Warn("refresh cache entry failed", "key", key, "error", err)
+		}
+	}()
+}
+
+func (s *LoadingCache[K, V]) loadContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if s.options.LoadTimeout > 0 {
+		return context.WithTimeout(ctx, s.options.LoadTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func (s *LoadingCache[K, V]) store(key K, value V) {
+	s.cache.Put(key, loadingEntry[V]{value: value, loadedAt: time.Now()}, s.options.MaxAge)
+}
+
+func (s *LoadingCache[K, V]) storeNotFound(key K) {
+	if s.options.NegativeMaxAge <= 0 {
+		s.cache.Remove(key)
+		return
+	}
+	s.cache.Put(key, loadingEntry[V]{notFound: true, loadedAt: time.Now()}, s.options.NegativeMaxAge)
+}
@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadingCacheCollapsesConcurrentMisses(t *testing.T) {
+	var loads atomic.Int32
+	release := make(chan struct{})
+	cache := NewLoadingCache[string, string](func(ctx context.Context, key string) (string, error) {
+		loads.Add(1)
+		<-release
+		return "value-" + key, nil
+	}, nil, nil)
+	defer cache.Release()
+
+	var wg sync.WaitGroup
+	for idx := 0; idx < 50; idx++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := cache.Get(context.Background(), "hot")
+			assert.Nil(t, err)
+			assert.Equal(t, "value-hot", value)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), loads.Load())
+	value, err := cache.Get(context.Background(), "hot")
+	assert.Nil(t, err)
+	assert.Equal(t, "value-hot", value)
+	assert.Equal(t, int64(1), cache.Stats().Loads)
+}
+
+func TestLoadingCacheNegativeCachingAndErrors(t *testing.T) {
+	var loads atomic.Int32
+	loadErr := errors.New("database unavailable")
+	cache := NewLoadingCache[int, string](func(ctx context.Context, key int) (string, error) {
+		loads.Add(1)
+		switch key {
+		case 404:
+			return "", ErrNotFound
+		case 500:
+			return "", loadErr
+		}
+		return fmt.Sprint(key), nil
+	}, nil, &LoadingOptions{NegativeMaxAge: OneMinuteAgeValue})
+	defer cache.Release()
+
+	for idx := 0; idx < 3; idx++ {
+		_, err := cache.Get(context.Background(), 404)
+		assert.ErrorIs(t, err, ErrNotFound)
+	}
+	assert.Equal(t, int32(1), loads.Load())
+	assert.Equal(t, int64(2), cache.Stats().NegativeHits)
+
+	// 加载错误不缓存
+	for idx := 0; idx < 2; idx++ {
+		_, err := cache.Get(context.Background(), 500)
+		assert.ErrorIs(t, err, loadErr)
+	}
+	assert.Equal(t, int32(3), loads.Load())
+	assert.Equal(t, int64(2), cache.Stats().LoadErrors)
+}
+
+func TestLoadingCacheNilInterfaceValue(t *testing.T) {
+	var loads atomic.Int32
+	cache := NewLoadingCache[string, any](func(ctx context.Context, key string) (any, error) {
+		loads.Add(1)
+		return nil, nil
+	}, nil, nil)
+	defer cache.Release()
+
+	for idx := 0; idx < 2; idx++ {
+		value, err := cache.Get(context.Background(), "empty")
+		assert.Nil(t, err)
+		assert.Nil(t, value)
+	}
+	assert.Equal(t, int32(1), loads.Load())
+}
+
+func TestLoadingCacheRefreshAhead(t *testing.T) {
+	var version atomic.Int32
+	cache := NewLoadingCache[string, int32](func(ctx context.Context, key string) (int32, error) {
+		return version.Add(1), nil
+	}, nil, &LoadingOptions{MaxAge: OneMinuteAgeValue, RefreshAfter: 1})
+	defer cache.Release()
+
+	value, _ := cache.Get(context.Background(), "key")
+	assert.Equal(t, int32(1), value)
+
+	time.Sleep(1100 * time.Millisecond)
+	// 触发后台刷新，当前访问仍返回旧值
+	value, _ = cache.Get(context.Background(), "key")
+	assert.Equal(t, int32(1), value)
+
+	assert.Eventually(t, func() bool {
+		value, _ = cache.Get(context.Background(), "key")
+		return value == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(1), cache.Stats().Refreshes)
+}
+
+func TestLoadingCacheBulkLoad(t *testing.T) {
+	var bulkCalls atomic.Int32
+	cache := NewLoadingCache[int, string](func(ctx context.Context, key int) (string, error) {
+		return "", errors.New("unexpected single load")
+	}, func(ctx context.Context, keys []int) (map[int]string, error) {
+		bulkCalls.Add(1)
+		ret := map[int]string{}
+		for _, key := range keys {
+			if key%2 == 0 {
+				ret[key] = fmt.Sprint(key)
+			}
+		}
+		return ret, nil
+	}, &LoadingOptions{NegativeMaxAge: OneMinuteAgeValue})
+	defer cache.Release()
+
+	values, err := cache.GetAll(context.Background(), []int{1, 2, 3, 4})
+	assert.Nil(t, err)
+	assert.Equal(t, map[int]string{2: "2", 4: "4"}, values)
+
+	values, err = cache.GetAll(context.Background(), []int{1, 2, 3, 4})
+	assert.Nil(t, err)
+	assert.Len(t, values, 2)
+	assert.Equal(t, int32(1), bulkCalls.Load())
+}
+
+func TestLoadingCacheCallerCancel(t *testing.T) {
+	release := make(chan struct{})
+	cache := NewLoadingCache[string, string](func(ctx context.Context, key string) (string, error) {
+		<-release
+		return "value", nil
+	}, nil, nil)
+	defer cache.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := cache.Get(ctx, "slow")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// 调用方取消不影响加载完成后写入缓存
+	close(release)
+	assert.Eventually(t, func() bool {
+		value, _ := cache.Get(context.Background(), "slow")
+		return value == "value"
+	}, time.Second, 10*time.Millisecond)
+}
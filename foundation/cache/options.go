@@ -1,7 +1,6 @@
 package cache
 
 import (
-	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -11,12 +10,21 @@ const defaultCleanupInterval = 5 * time.Second
 type CacheOptions struct {
 	Capacity        int
 	CleanupInterval time.Duration
+	// Eviction 超过 Capacity 或 MaxCost 时的淘汰策略，默认 EvictionLRU
+	Eviction EvictionPolicy
+	// MaxCost 大于 0 时按条目成本总和限制容量，可与 Capacity 同时使用
+	MaxCost int64
+	// Cost 计算条目成本，为 nil 时每个条目成本为 1
+	Cost func(value any) int64
+	// SnapshotCodec Snapshot 和 Restore 使用的编码，为 nil 时使用 JSONCodec
+	SnapshotCodec Codec
 }
 
 func DefaultCacheOptions() CacheOptions {
 	return CacheOptions{
 		Capacity:        0,
 		CleanupInterval: defaultCleanupInterval,
+		SnapshotCodec:   JSONCodec{},
 	}
 }
 
@@ -29,6 +37,12 @@ func normalizeCacheOptions(options *CacheOptions) CacheOptions {
 	if normalized.Capacity < 0 {
 		normalized.Capacity = 0
 	}
+	if normalized.SnapshotCodec == nil {
+		normalized.SnapshotCodec = JSONCodec{}
+	}
+	if normalized.MaxCost < 0 {
+		normalized.MaxCost = 0
+	}
 	if normalized.CleanupInterval <= 0 {
 		normalized.CleanupInterval = defaultCleanupInterval
 	}
@@ -39,38 +53,52 @@ func normalizeCacheOptions(options *CacheOptions) CacheOptions {
 type CacheStats struct {
 	Entries     int
 	Capacity    int
+	Eviction    EvictionPolicy
+	Cost        int64
+	MaxCost     int64
 	Puts        int64
 	Hits        int64
 	Misses      int64
 	Evictions   int64
 	Expirations int64
+	// Rejections 未被淘汰策略接纳的写入次数
+	Rejections int64
+	// Invalidations 按标签删除和级联删除的条目数量
+	Invalidations int64
+}
+
+// HitRatio 命中率，没有访问时返回 0
+func (s CacheStats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
 }
 
 type cacheMetrics struct {
-	puts        atomic.Int64
-	hits        atomic.Int64
-	misses      atomic.Int64
-	evictions   atomic.Int64
-	expirations atomic.Int64
+	puts          atomic.Int64
+	hits          atomic.Int64
+	misses        atomic.Int64
+	evictions     atomic.Int64
+	expirations   atomic.Int64
+	rejections    atomic.Int64
+	invalidations atomic.Int64
 }
 
-func (s *cacheMetrics) snapshot(entries int, capacity int) CacheStats {
+func (s *cacheMetrics) snapshot(entries int, options CacheOptions, cost int64) CacheStats {
 	return CacheStats{
-		Entries:     entries,
-		Capacity:    capacity,
-		Puts:        s.puts.Load(),
-		Hits:        s.hits.Load(),
-		Misses:      s.misses.Load(),
-		Evictions:   s.evictions.Load(),
-		Expirations: s.expirations.Load(),
+		Entries:       entries,
+		Capacity:      options.Capacity,
+		Eviction:      options.Eviction,
+		Cost:          cost,
+		MaxCost:       options.MaxCost,
+		Puts:          s.puts.Load(),
+		Hits:          s.hits.Load(),
+		Misses:        s.misses.Load(),
+		Evictions:     s.evictions.Load(),
+		Expirations:   s.expirations.Load(),
+		Rejections:    s.rejections.Load(),
+		Invalidations: s.invalidations.Load(),
 	}
 }
-
-func countSyncMap(data *sync.Map) int {
-	count := 0
-	data.Range(func(_, _ any) bool {
-		count++
-		return true
-	})
-	return count
-}
@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotMagic 快照文件头，末位为格式版本；版本 2 增加了标签和依赖，版本 1 的快照仍可导入
+var snapshotMagic = []byte("MCSNAP\x00\x02")
+
+// snapshotRecord 快照条目，Remaining 为剩余有效期（毫秒），永不过期的条目为 ForeverAgeValue
+type snapshotRecord[K comparable, V any] struct {
+	Key       K
+	Value     V
+	MaxAge    int64
+	Remaining int64
+	Tags      []string
+	DependsOn []K
+}
+
+// Snapshotter 支持导出和导入的缓存
+type Snapshotter interface {
+	Snapshot(w io.Writer) error
+	Restore(r io.Reader) error
+}
+
+// Snapshot 导出未过期的数据，每条记录为 4 字节长度加 SnapshotCodec 编码的内容
+func (s *GenericKVCache[K, V]) Snapshot(w io.Writer) error {
+	if s.released.Load() {
+		return errors.New("cache: snapshot released cache")
+	}
+
+	writer := bufio.NewWriter(w)
+	if _, err := writer.Write(snapshotMagic); err != nil {
+		return err
+	}
+
+	var err error
+	nowTime := time.Now()
+	header := make([]byte, 4)
+	s.backend.Range(func(k K, entry Entry[V]) bool {
+		if entry.expired(nowTime) {
+			return true
+		}
+
+		record := snapshotRecord[K, V]{Key: k, Value: entry.Value, MaxAge: entry.MaxAge, Remaining: ForeverAgeValue}
+		if s.indexed.Load() {
+			options := s.index.optionsOf(k)
+			record.Tags, record.DependsOn = options.Tags, options.DependsOn
+		}
+		if entry.MaxAge != ForeverAgeValue {
+			remaining := time.Duration(entry.MaxAge)*time.Second - nowTime.Sub(entry.CacheTime)
+			record.Remaining = max(remaining.Milliseconds(), 0)
+		}
+
+		var payload []byte
+		payload, err = s.options.SnapshotCodec.Marshal(record)
+		if err != nil {
+			err = fmt.Errorf("cache: encode snapshot entry %v: %w", k, err)
+			return false
+		}
+		binary.BigEndian.PutUint32(header, uint32(len(payload)))
+		if _, err = writer.Write(header); err != nil {
+			return false
+		}
+		_, err = writer.Write(payload)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// Restore 导入数据，已有的同名数据被覆盖；数据损坏时返回错误，之前已导入的数据保留
+func (s *GenericKVCache[K, V]) Restore(r io.Reader) error {
+	if s.released.Load() {
+		return errors.New("cache: restore released cache")
+	}
+
+	reader := bufio.NewReader(r)
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil {
+		return fmt.Errorf("cache: read snapshot header: %w", err)
+	}
+	versionIdx := len(snapshotMagic) - 1
+	if !bytes.Equal(magic[:versionIdx], snapshotMagic[:versionIdx]) || magic[versionIdx] == 0 || magic[versionIdx] > snapshotMagic[versionIdx] {
+		return errors.New("cache: illegal snapshot header")
+	}
+
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("cache: read snapshot entry: %w", err)
+		}
+		payload := make([]byte, binary.BigEndian.Uint32(header))
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			return fmt.Errorf("cache: read snapshot entry: %w", err)
+		}
+
+		record := snapshotRecord[K, V]{}
+		if err := s.options.SnapshotCodec.Unmarshal(payload, &record); err != nil {
+			return fmt.Errorf("cache: decode snapshot entry: %w", err)
+		}
+
+		entry := Entry[V]{Value: record.Value, MaxAge: record.MaxAge, CacheTime: time.Now()}
+		if record.MaxAge != ForeverAgeValue {
+			if record.Remaining <= 0 {
+				continue
+			}
+			// 按剩余有效期回推写入时间，过期判断与导出前保持一致
+			elapsed := time.Duration(record.MaxAge)*time.Second - time.Duration(record.Remaining)*time.Millisecond
+			entry.CacheTime = entry.CacheTime.Add(-elapsed)
+		}
+		s.store(record.Key, entry, &PutOptions[K]{Tags: record.Tags, DependsOn: record.DependsOn})
+	}
+}
+
+// SnapshotFile 导出到文件，先写临时文件再替换，避免中途失败损坏已有快照
+func SnapshotFile(cache Snapshotter, path string) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := file.Name()
+	defer os.Remove(tmpPath)
+
+	if err = cache.Snapshot(file); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// RestoreFile 从文件导入，文件不存在时直接返回
+func RestoreFile(cache Snapshotter, path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return cache.Restore(file)
+}
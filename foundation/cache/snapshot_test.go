@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type snapshotUser struct {
+	ID   int
+	Name string
+}
+
+func TestSnapshotRestoreKeepsRemainingTTL(t *testing.T) {
+	source := newGenericKVCache[string, snapshotUser](nil, NewMemoryBackend[string, snapshotUser](), nil)
+	defer source.Release()
+	source.Put("forever", snapshotUser{ID: 1, Name: "alice"}, ForeverAgeValue)
+	source.Put("minute", snapshotUser{ID: 2, Name: "bob"}, OneMinuteAgeValue)
+	source.Put("expired", snapshotUser{ID: 3, Name: "carol"}, 0)
+	time.Sleep(1100 * time.Millisecond)
+
+	buffer := &bytes.Buffer{}
+	assert.Nil(t, source.Snapshot(buffer))
+
+	target := newGenericKVCache[string, snapshotUser](nil, NewMemoryBackend[string, snapshotUser](), nil)
+	defer target.Release()
+	assert.Nil(t, target.Restore(buffer))
+	assert.Equal(t, snapshotUser{ID: 1, Name: "alice"}, target.Fetch("forever"))
+	assert.Equal(t, snapshotUser{ID: 2, Name: "bob"}, target.Fetch("minute"))
+	assert.Equal(t, snapshotUser{}, target.Fetch("expired"))
+	assert.Equal(t, 2, target.Stats().Entries)
+
+	// 恢复后按原写入时间继续计算过期
+	sourceEntry, _ := source.backend.Load("minute")
+	targetEntry, _ := target.backend.Load("minute")
+	assert.WithinDuration(t, sourceEntry.CacheTime, targetEntry.CacheTime, 50*time.Millisecond)
+	foreverEntry, _ := target.backend.Load("forever")
+	assert.Equal(t, int64(ForeverAgeValue), foreverEntry.MaxAge)
+}
+
+func TestSnapshotWithGobCodec(t *testing.T) {
+	options := &CacheOptions{SnapshotCodec: GobCodec{}}
+	source := newGenericKVCache[int, snapshotUser](nil, NewMemoryBackend[int, snapshotUser](), options)
+	defer source.Release()
+	for idx := 0; idx < 100; idx++ {
+		source.Put(idx, snapshotUser{ID: idx}, TenMinutesAgeValue)
+	}
+
+	buffer := &bytes.Buffer{}
+	assert.Nil(t, source.Snapshot(buffer))
+	target := newGenericKVCache[int, snapshotUser](nil, NewMemoryBackend[int, snapshotUser](), options)
+	defer target.Release()
+	assert.Nil(t, target.Restore(buffer))
+	assert.Equal(t, 100, target.Stats().Entries)
+	assert.Equal(t, snapshotUser{ID: 42}, target.Fetch(42))
+}
+
+func TestSnapshotRestoreKeepsTagsAndDependencies(t *testing.T) {
+	source := newGenericKVCache[string, int](nil, NewMemoryBackend[string, int](), nil)
+	defer source.Release()
+	source.PutWithOptions("user:1", 1, ForeverAgeValue, PutOptions[string]{Tags: []string{"tenant:7"}})
+	source.PutWithOptions("user:2", 2, ForeverAgeValue, PutOptions[string]{Tags: []string{"tenant:7"}})
+	source.PutWithOptions("profile:1", 10, ForeverAgeValue, PutOptions[string]{DependsOn: []string{"user:1"}})
+	source.Put("plain", 0, ForeverAgeValue)
+
+	buffer := &bytes.Buffer{}
+	assert.Nil(t, source.Snapshot(buffer))
+
+	target := newGenericKVCache[string, int](nil, NewMemoryBackend[string, int](), nil)
+	defer target.Release()
+	assert.Nil(t, target.Restore(buffer))
+	assert.Equal(t, 4, target.Stats().Entries)
+
+	assert.Equal(t, 2, target.InvalidateTag("tenant:7"))
+	assert.Equal(t, 1, target.Stats().Entries)
+	assert.Equal(t, 0, target.Fetch("user:1"))
+	assert.Equal(t, 0, target.Fetch("profile:1"))
+	assert.Equal(t, 0, target.Fetch("plain"))
+	_, found := target.backend.Load("plain")
+	assert.True(t, found)
+}
+
+func TestRestoreVersion1Snapshot(t *testing.T) {
+	source := newGenericKVCache[string, int](nil, NewMemoryBackend[string, int](), nil)
+	defer source.Release()
+	source.Put("k1", 1, ForeverAgeValue)
+	buffer := &bytes.Buffer{}
+	assert.Nil(t, source.Snapshot(buffer))
+
+	// 版本 1 的记录没有标签和依赖字段
+	payload := buffer.Bytes()
+	payload[len(snapshotMagic)-1] = 1
+	target := newGenericKVCache[string, int](nil, NewMemoryBackend[string, int](), nil)
+	defer target.Release()
+	assert.Nil(t, target.Restore(bytes.NewReader(payload)))
+	assert.Equal(t, 1, target.Fetch("k1"))
+
+	payload[len(snapshotMagic)-1] = snapshotMagic[len(snapshotMagic)-1] + 1
+	assert.NotNil(t, target.Restore(bytes.NewReader(payload)))
+}
+
+func TestRestoreRejectsDamagedSnapshot(t *testing.T) {
+	cache := newGenericKVCache[string, int](nil, NewMemoryBackend[string, int](), nil)
+	defer cache.Release()
+	assert.NotNil(t, cache.Restore(bytes.NewReader([]byte("not a snapshot"))))
+
+	source := newGenericKVCache[string, int](nil, NewMemoryBackend[string, int](), nil)
+	defer source.Release()
+	source.Put("k1", 1, ForeverAgeValue)
+	buffer := &bytes.Buffer{}
+	assert.Nil(t, source.Snapshot(buffer))
+	assert.NotNil(t, cache.Restore(bytes.NewReader(buffer.Bytes()[:buffer.Len()-2])))
+}
+
+func TestSnapshotFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.snapshot")
+
+	cache := NewKVCache(nil).(*MemoryKVCache)
+	defer cache.Release()
+	// 文件不存在时视为没有快照
+	assert.Nil(t, RestoreFile(cache, path))
+
+	cache.Put("k1", "v1", ForeverAgeValue)
+	assert.Nil(t, SnapshotFile(cache, path))
+
+	restored := NewKVCache(nil).(*MemoryKVCache)
+	defer restored.Release()
+	assert.Nil(t, RestoreFile(restored, path))
+	assert.Equal(t, "v1", restored.Fetch("k1"))
+}
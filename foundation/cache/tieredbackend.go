@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+)
+
+// TieredBackend 两级存储，L1 通常为进程内存储，L2 为持久化存储。
+// 写入同时写 L1 和 L2，读取先读 L1，未命中时从 L2 读取并回填 L1；
+// l1Capacity 大于 0 时 L1 按最近使用顺序淘汰，L2 保存全部条目。
+// 回填期间该 key 被写入、删除或清空时放弃回填，避免旧数据重新进入 L1。
+type TieredBackend[K comparable, V any] struct {
+	l1         Backend[K, V]
+	l2         Backend[K, V]
+	l1Capacity int
+
+	// mu 保护 L1 的写入、淘汰顺序和回填状态
+	mu      sync.Mutex
+	l1Order *list.List
+	l1Items map[K]*list.Element
+	loads   map[K]*tieredLoad
+}
+
+// tieredLoad 正在从 L2 回填的 key，gen 在写入、删除时递增
+type tieredLoad struct {
+	refs int
+	gen  uint64
+}
+
+// NewTieredBackend 创建两级存储，l1 中已有的条目不受 l1Capacity 约束
+func NewTieredBackend[K comparable, V any](l1, l2 Backend[K, V], l1Capacity int) *TieredBackend[K, V] {
+	return &TieredBackend[K, V]{
+		l1:         l1,
+		l2:         l2,
+		l1Capacity: l1Capacity,
+		l1Order:    list.New(),
+		l1Items:    map[K]*list.Element{},
+		loads:      map[K]*tieredLoad{},
+	}
+}
+
+func (s *TieredBackend[K, V]) Load(key K) (Entry[V], bool) {
+	if entry, ok := s.l1.Load(key); ok {
+		s.markL1(key)
+		return entry, true
+	}
+
+	s.mu.Lock()
+	load, ok := s.loads[key]
+	if !ok {
+		load = &tieredLoad{}
+		s.loads[key] = load
+	}
+	load.refs++
+	gen := load.gen
+	s.mu.Unlock()
+
+	entry, ok := s.l2.Load(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	load.refs--
+	if load.refs == 0 {
+		delete(s.loads, key)
+	}
+	if ok && load.gen == gen {
+		s.storeL1(key, entry)
+	}
+	return entry, ok
+}
+
+// Store 先写 L2 再写 L1，写 L1 时使正在进行的回填失效
+func (s *TieredBackend[K, V]) Store(key K, entry Entry[V]) error {
+	if err := s.l2.Store(key, entry); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.invalidateLoad(key)
+	s.storeL1(key, entry)
+	return nil
+}
+
+// Delete 先删除 L2 再删除 L1，删除 L1 时使正在进行的回填失效
+func (s *TieredBackend[K, V]) Delete(key K) error {
+	l2Err := s.l2.Delete(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.invalidateLoad(key)
+	if elem, ok := s.l1Items[key]; ok {
+		s.l1Order.Remove(elem)
+		delete(s.l1Items, key)
+	}
+	return errors.Join(s.l1.Delete(key), l2Err)
+}
+
+func (s *TieredBackend[K, V]) Touch(key K, cacheTime time.Time) {
+	s.l1.Touch(key, cacheTime)
+	s.l2.Touch(key, cacheTime)
+}
+
+func (s *TieredBackend[K, V]) Range(fn func(key K, entry Entry[V]) bool) {
+	s.l2.Range(fn)
+}
+
+func (s *TieredBackend[K, V]) Len() int {
+	return s.l2.Len()
+}
+
+func (s *TieredBackend[K, V]) Clear() error {
+	l2Err := s.l2.Clear()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, load := range s.loads {
+		load.gen++
+	}
+	s.l1Order.Init()
+	s.l1Items = map[K]*list.Element{}
+	return errors.Join(s.l1.Clear(), l2Err)
+}
+
+func (s *TieredBackend[K, V]) Close() error {
+	return errors.Join(s.l1.Close(), s.l2.Close())
+}
+
+func (s *TieredBackend[K, V]) markL1(key K) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.l1Items[key]; ok {
+		s.l1Order.MoveToFront(elem)
+	}
+}
+
+// invalidateLoad 使 key 正在进行的回填失效，调用方持有 mu
+func (s *TieredBackend[K, V]) invalidateLoad(key K) {
+	if load, ok := s.loads[key]; ok {
+		load.gen++
+	}
+}
+
+// storeL1 写入 L1 并按容量淘汰，调用方持有 mu
+func (s *TieredBackend[K, V]) storeL1(key K, entry Entry[V]) {
+	var evictKeys []K
+	if elem, ok := s.l1Items[key]; ok {
+		s.l1Order.MoveToFront(elem)
+	} else {
+		s.l1Items[key] = s.l1Order.PushFront(key)
+		for s.l1Capacity > 0 && s.l1Order.Len() > s.l1Capacity {
+			oldest := s.l1Order.Back()
+			s.l1Order.Remove(oldest)
+			delete(s.l1Items, oldest.Value.(K))
+			evictKeys = append(evictKeys, oldest.Value.(K))
+		}
+	}
+
+	// L1 写入失败不影响 L2 中的数据
+	_ = s.l1.Store(key, entry)
+	for _, val := range evictKeys {
+		_ = s.l1.Delete(val)
+	}
+}
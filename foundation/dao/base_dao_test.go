@@ -1,8 +1,11 @@
 package dao
 
 import (
+	"context"
+	"database/sql/driver"
 	"errors"
 	"testing"
+	"time"
 
 	cd "github.com/muidea/magicCommon/def"
 	"github.com/stretchr/testify/assert"
@@ -273,3 +276,91 @@ func TestBaseDao_DBName(t *testing.T) {
 		assert.Equal(t, "", dbName)
 	})
 }
+
+// TestBaseDao_Context 测试 ctx 取消和默认超时
+func TestBaseDao_Context(t *testing.T) {
+	t.Run("默认超时返回 Timeout", func(t *testing.T) {
+		connector := &fakeConnector{delay: time.Second}
+		baseDao := newFakeDao(t, connector, WithQueryTimeout(20*time.Millisecond))
+
+		startTime := time.Now()
+		_, err := baseDao.Execute("UPDATE report SET status = 1")
+		assert.NotNil(t, err)
+		assert.EqualValues(t, cd.Timeout, err.Code)
+		assert.Less(t, time.Since(startTime), 500*time.Millisecond)
+
+		err = baseDao.Query("SELECT id FROM report")
+		assert.NotNil(t, err)
+		assert.EqualValues(t, cd.Timeout, err.Code)
+	})
+
+	t.Run("ctx 截止时间早于默认超时", func(t *testing.T) {
+		connector := &fakeConnector{delay: time.Second}
+		baseDao := newFakeDao(t, connector, WithQueryTimeout(time.Minute))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := baseDao.ExecuteContext(ctx, "UPDATE report SET status = 1")
+		assert.NotNil(t, err)
+		assert.EqualValues(t, cd.Timeout, err.Code)
+	})
+
+	t.Run("ctx 取消", func(t *testing.T) {
+		connector := &fakeConnector{delay: time.Second}
+		baseDao := newFakeDao(t, connector)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+		err := baseDao.QueryContext(ctx, "SELECT id FROM report")
+		assert.NotNil(t, err)
+		assert.EqualValues(t, cd.Unexpected, err.Code)
+	})
+
+	t.Run("查询超时覆盖到 Finish", func(t *testing.T) {
+		connector := &fakeConnector{
+			query: func(string, []driver.NamedValue) ([]string, [][]driver.Value, error) {
+				return []string{"id"}, [][]driver.Value{{int64(1)}, {int64(2)}}, nil
+			},
+		}
+		baseDao := newFakeDao(t, connector, WithQueryTimeout(50*time.Millisecond))
+
+		err := baseDao.QueryContext(context.Background(), "SELECT id FROM report")
+		assert.Nil(t, err)
+		ids := []int64{}
+		for baseDao.Next() {
+			var id int64
+			assert.Nil(t, baseDao.GetField(&id))
+			ids = append(ids, id)
+		}
+		assert.Equal(t, []int64{1, 2}, ids)
+		assert.Nil(t, baseDao.Finish())
+	})
+
+	t.Run("事务内语句使用事务连接", func(t *testing.T) {
+		connector := &fakeConnector{
+			exec: func(string, []driver.NamedValue) (int64, error) {
+				return 1, nil
+			},
+		}
+		baseDao := newFakeDao(t, connector, WithQueryTimeout(time.Second))
+
+		ctx := context.Background()
+		assert.Nil(t, baseDao.BeginTransactionContext(ctx))
+		affected, err := baseDao.UpdateContext(ctx, "UPDATE report SET status = 1")
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), affected)
+		assert.Nil(t, baseDao.CommitTransaction())
+		assert.Equal(t, []string{"BEGIN", "UPDATE report SET status = 1", "COMMIT"}, connector.statements())
+	})
+
+	t.Run("已取消的 ctx 不开始事务", func(t *testing.T) {
+		baseDao := newFakeDao(t, &fakeConnector{})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := baseDao.BeginTransactionContext(ctx)
+		assert.NotNil(t, err)
+		assert.EqualValues(t, cd.Unexpected, err.Code)
+		assert.EqualValues(t, 0, baseDao.dbTxCount)
+	})
+}
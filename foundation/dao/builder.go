@@ -0,0 +1,827 @@
+package dao
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	cd "github.com/muidea/magicCommon/def"
+	"github.com/muidea/magicCommon/foundation/util"
+)
+
+// Builder 按驱动方言生成 SQL 和参数，表名、列名统一转义，值统一使用参数传递
+type Builder struct {
+	dialect Dialect
+}
+
+// NewBuilder 使用已注册驱动的方言创建 Builder，驱动需要实现 Dialect
+func NewBuilder(driverName string) (*Builder, *cd.Error) {
+	driver, ok := GetDriver(driverName)
+	if !ok {
+		return nil, cd.NewError(cd.DatabaseError, fmt.Sprintf("database driver '%s' not found", driverName))
+	}
+	dialect, ok := driver.(Dialect)
+	if !ok {
+		return nil, cd.NewError(cd.NotImplemented, fmt.Sprintf("database driver '%s' does not support query builder", driverName))
+	}
+
+	return &Builder{dialect: dialect}, nil
+}
+
+// BuilderOf 使用 Dao 的驱动方言创建 Builder
+func BuilderOf(dao Dao) (*Builder, *cd.Error) {
+	driverName, ok := DriverNameOf(dao)
+	if !ok {
+		return nil, ErrDriverNotFound
+	}
+
+	return NewBuilder(driverName)
+}
+
+// Select 查询指定列，不指定时查询全部列
+func (s *Builder) Select(columns ...string) *SelectBuilder {
+	ret := &SelectBuilder{builder: s}
+	return ret.Columns(columns...)
+}
+
+// Insert 插入数据
+func (s *Builder) Insert(table string) *InsertBuilder {
+	return &InsertBuilder{builder: s, table: table}
+}
+
+// Upsert 插入数据，conflict 列冲突时更新其它列，详见 InsertBuilder.OnConflict
+func (s *Builder) Upsert(table string, conflict ...string) *InsertBuilder {
+	return s.Insert(table).OnConflict(conflict...)
+}
+
+// Update 更新数据
+func (s *Builder) Update(table string) *UpdateBuilder {
+	return &UpdateBuilder{builder: s, table: table}
+}
+
+// Delete 删除数据
+func (s *Builder) Delete(table string) *DeleteBuilder {
+	return &DeleteBuilder{builder: s, table: table}
+}
+
+func (s *Builder) writer() *sqlWriter {
+	return &sqlWriter{dialect: s.dialect}
+}
+
+// sqlWriter 拼接 SQL，记录参数和第一个错误
+type sqlWriter struct {
+	dialect Dialect
+	sql     strings.Builder
+	args    []any
+	err     *cd.Error
+}
+
+func (s *sqlWriter) write(str string) {
+	s.sql.WriteString(str)
+}
+
+// ident 校验并写入转义后的标识符
+func (s *sqlWriter) ident(name string) {
+	if !isValidIdentifier(name) {
+		s.fail(fmt.Sprintf("illegal identifier '%s'", name))
+		return
+	}
+	s.write(s.dialect.QuoteIdentifier(name))
+}
+
+func (s *sqlWriter) idents(names []string) {
+	for idx, name := range names {
+		if idx > 0 {
+			s.write(", ")
+		}
+		s.ident(name)
+	}
+}
+
+// bind 写入参数占位符
+func (s *sqlWriter) bind(val any) {
+	s.args = append(s.args, val)
+	s.write(s.dialect.Placeholder(len(s.args)))
+}
+
+// expr 写入表达式，? 替换为参数占位符，?? 表示 ? 本身，单引号字符串中的 ? 保持不变
+func (s *sqlWriter) expr(expr string, args []any) {
+	used := 0
+	quoted := false
+	for idx := 0; idx < len(expr); idx++ {
+		ch := expr[idx]
+		switch {
+		case ch == '\'':
+			quoted = !quoted
+			s.sql.WriteByte(ch)
+		case ch == '?' && !quoted && idx+1 < len(expr) && expr[idx+1] == '?':
+			s.sql.WriteByte(ch)
+			idx++
+		case ch == '?' && !quoted:
+			if used < len(args) {
+				s.bind(args[used])
+			}
+			used++
+		default:
+			s.sql.WriteByte(ch)
+		}
+	}
+
+	if used != len(args) {
+		s.fail(fmt.Sprintf("expression '%s' has %d placeholders, got %d args", expr, used, len(args)))
+	}
+}
+
+func (s *sqlWriter) where(cond Cond) {
+	if isEmptyCond(cond) {
+		return
+	}
+	s.write(" WHERE ")
+	cond.writeTo(s)
+}
+
+func (s *sqlWriter) fail(message string) {
+	if s.err == nil {
+		s.err = cd.NewError(cd.InvalidParameter, message)
+	}
+}
+
+func (s *sqlWriter) result() (string, []any, *cd.Error) {
+	if s.err != nil {
+		return "", nil, s.err
+	}
+	return s.sql.String(), s.args, nil
+}
+
+// isValidIdentifier 非空，按 . 分段后每段非空且不含 NUL
+func isValidIdentifier(name string) bool {
+	if name == "" || strings.ContainsRune(name, 0) {
+		return false
+	}
+	return !slices.Contains(strings.Split(name, "."), "")
+}
+
+// SelectBuilder SELECT 语句
+type SelectBuilder struct {
+	builder  *Builder
+	distinct bool
+	columns  []selectColumn
+	table    string
+	where    []Cond
+	groupBy  []string
+	orderBy  []orderColumn
+	limit    int
+	offset   int
+	err      string
+}
+
+type selectColumn struct {
+	name string
+	expr bool
+}
+
+type orderColumn struct {
+	name string
+	asc  bool
+}
+
+// Columns 追加查询的列
+func (s *SelectBuilder) Columns(columns ...string) *SelectBuilder {
+	for _, column := range columns {
+		s.columns = append(s.columns, selectColumn{name: column})
+	}
+	return s
+}
+
+// ColumnExpr 追加不转义的列表达式，如 COUNT(*)，不能包含外部输入
+func (s *SelectBuilder) ColumnExpr(expr string) *SelectBuilder {
+	s.columns = append(s.columns, selectColumn{name: expr, expr: true})
+	return s
+}
+
+// Distinct 去除重复行
+func (s *SelectBuilder) Distinct() *SelectBuilder {
+	s.distinct = true
+	return s
+}
+
+// From 查询的表
+func (s *SelectBuilder) From(table string) *SelectBuilder {
+	s.table = table
+	return s
+}
+
+// Where 追加条件，多次调用的条件之间为 AND
+func (s *SelectBuilder) Where(conds ...Cond) *SelectBuilder {
+	s.where = append(s.where, conds...)
+	return s
+}
+
+// GroupBy 追加分组列
+func (s *SelectBuilder) GroupBy(columns ...string) *SelectBuilder {
+	s.groupBy = append(s.groupBy, columns...)
+	return s
+}
+
+// OrderBy 追加排序列
+func (s *SelectBuilder) OrderBy(column string, asc bool) *SelectBuilder {
+	s.orderBy = append(s.orderBy, orderColumn{name: column, asc: asc})
+	return s
+}
+
+// OrderBySort 按 SortFilter 追加排序列，忽略 nil 和没有字段名的过滤器；
+// 字段名会被转义，但仍建议调用方按允许排序的列校验
+func (s *SelectBuilder) OrderBySort(filters ...*util.SortFilter) *SelectBuilder {
+	for _, filter := range filters {
+		if filter == nil || filter.Name() == "" {
+			continue
+		}
+		s.OrderBy(filter.Name(), filter.AscSort())
+	}
+	return s
+}
+
+// Limit 限制返回的行数，0 表示不限制
+func (s *SelectBuilder) Limit(limit int) *SelectBuilder {
+	if limit < 0 {
+		s.err = fmt.Sprintf("illegal limit %d", limit)
+	}
+	s.limit = limit
+	return s
+}
+
+// Offset 跳过的行数，需要同时设置 Limit
+func (s *SelectBuilder) Offset(offset int) *SelectBuilder {
+	if offset < 0 {
+		s.err = fmt.Sprintf("illegal offset %d", offset)
+	}
+	s.offset = offset
+	return s
+}
+
+// Page 分页，pageNum 从 1 开始
+func (s *SelectBuilder) Page(pageNum, pageSize int) *SelectBuilder {
+	if pageNum < 1 || pageSize < 1 {
+		s.err = fmt.Sprintf("illegal page %d, size %d", pageNum, pageSize)
+		return s
+	}
+
+	s.limit = pageSize
+	s.offset = (pageNum - 1) * pageSize
+	return s
+}
+
+// Build 生成 SQL 和参数
+func (s *SelectBuilder) Build() (string, []any, *cd.Error) {
+	w := s.builder.writer()
+	w.write("SELECT ")
+	if s.distinct {
+		w.write("DISTINCT ")
+	}
+	if len(s.columns) == 0 {
+		w.write("*")
+	}
+	for idx, column := range s.columns {
+		if idx > 0 {
+			w.write(", ")
+		}
+		if column.expr {
+			w.write(column.name)
+			continue
+		}
+		w.ident(column.name)
+	}
+	s.writeFrom(w)
+
+	if len(s.groupBy) > 0 {
+		w.write(" GROUP BY ")
+		w.idents(s.groupBy)
+	}
+	for idx, column := range s.orderBy {
+		if idx == 0 {
+			w.write(" ORDER BY ")
+		} else {
+			w.write(", ")
+		}
+		w.ident(column.name)
+		if column.asc {
+			w.write(" ASC")
+		} else {
+			w.write(" DESC")
+		}
+	}
+
+	if s.offset > 0 && s.limit == 0 {
+		w.fail("offset requires limit")
+	}
+	if s.limit > 0 {
+		w.write(fmt.Sprintf(" LIMIT %d", s.limit))
+	}
+	if s.offset > 0 {
+		w.write(fmt.Sprintf(" OFFSET %d", s.offset))
+	}
+	return w.result()
+}
+
+// Count 生成相同条件下统计总行数的 SQL，忽略列、排序和分页，用于分页查询的总数
+func (s *SelectBuilder) Count() (string, []any, *cd.Error) {
+	if len(s.groupBy) > 0 || s.distinct {
+		// 分组或去重后的行数需要子查询统计
+		sqlStr, args, err := (&SelectBuilder{
+			builder:  s.builder,
+			distinct: s.distinct,
+			columns:  s.columns,
+			table:    s.table,
+			where:    s.where,
+			groupBy:  s.groupBy,
+			err:      s.err,
+		}).Build()
+		if err != nil {
+			return "", nil, err
+		}
+		return "SELECT COUNT(*) FROM (" + sqlStr + ") magic_count", args, nil
+	}
+
+	w := s.builder.writer()
+	w.write("SELECT COUNT(*)")
+	s.writeFrom(w)
+	return w.result()
+}
+
+func (s *SelectBuilder) writeFrom(w *sqlWriter) {
+	if s.err != "" {
+		w.fail(s.err)
+	}
+	w.write(" FROM ")
+	w.ident(s.table)
+	w.where(And(s.where...))
+}
+
+// InsertBuilder INSERT 语句，设置 OnConflict 后为 UPSERT
+type InsertBuilder struct {
+	builder  *Builder
+	table    string
+	columns  []string
+	rows     [][]any
+	upsert   bool
+	conflict []string
+	update   []string
+	updateOn bool
+}
+
+// Columns 插入的列
+func (s *InsertBuilder) Columns(columns ...string) *InsertBuilder {
+	s.columns = append(s.columns, columns...)
+	return s
+}
+
+// Values 追加一行，值的个数与列数相同，多次调用时批量插入
+func (s *InsertBuilder) Values(values ...any) *InsertBuilder {
+	s.rows = append(s.rows, values)
+	return s
+}
+
+// OnConflict 设置冲突列，插入冲突时更新除冲突列以外的其它列。
+// PostgreSQL 使用 ON CONFLICT，冲突列需要有唯一约束；MySQL 使用 ON DUPLICATE KEY UPDATE，按表上的唯一索引判断冲突
+func (s *InsertBuilder) OnConflict(conflict ...string) *InsertBuilder {
+	s.upsert = true
+	s.conflict = append(s.conflict, conflict...)
+	return s
+}
+
+// DoUpdate 设置冲突时更新的列，不指定列时保留已有的行不做修改
+func (s *InsertBuilder) DoUpdate(columns ...string) *InsertBuilder {
+	s.updateOn = true
+	s.update = append(s.update, columns...)
+	return s
+}
+
+// Build 生成 SQL 和参数
+func (s *InsertBuilder) Build() (string, []any, *cd.Error) {
+	w := s.builder.writer()
+	if len(s.columns) == 0 || len(s.rows) == 0 {
+		w.fail("insert requires columns and values")
+		return w.result()
+	}
+
+	w.write("INSERT INTO ")
+	w.ident(s.table)
+	w.write(" (")
+	w.idents(s.columns)
+	w.write(") VALUES ")
+	for idx, row := range s.rows {
+		if len(row) != len(s.columns) {
+			w.fail(fmt.Sprintf("insert row %d has %d values, expected %d", idx, len(row), len(s.columns)))
+			return w.result()
+		}
+		if idx > 0 {
+			w.write(", ")
+		}
+		w.write("(")
+		for valIdx, val := range row {
+			if valIdx > 0 {
+				w.write(", ")
+			}
+			w.bind(val)
+		}
+		w.write(")")
+	}
+
+	if s.upsert {
+		s.writeUpsert(w)
+	}
+	return w.result()
+}
+
+func (s *InsertBuilder) writeUpsert(w *sqlWriter) {
+	if len(s.conflict) == 0 {
+		w.fail("upsert requires conflict columns")
+		return
+	}
+
+	update := s.update
+	if !s.updateOn {
+		for _, column := range s.columns {
+			if !slices.Contains(s.conflict, column) {
+				update = append(update, column)
+			}
+		}
+	}
+
+	quote := func(names []string) []string {
+		ret := make([]string, len(names))
+		for idx, name := range names {
+			if !isValidIdentifier(name) {
+				w.fail(fmt.Sprintf("illegal identifier '%s'", name))
+			}
+			ret[idx] = w.dialect.QuoteIdentifier(name)
+		}
+		return ret
+	}
+	w.write(" ")
+	w.write(w.dialect.UpsertClause(quote(s.conflict), quote(update)))
+}
+
+// UpdateBuilder UPDATE 语句
+type UpdateBuilder struct {
+	builder *Builder
+	table   string
+	sets    []updateSet
+	where   []Cond
+	all     bool
+}
+
+type updateSet struct {
+	column string
+	expr   string
+	args   []any
+}
+
+// Set 设置列的值
+func (s *UpdateBuilder) Set(column string, value any) *UpdateBuilder {
+	s.sets = append(s.sets, updateSet{column: column, expr: "?", args: []any{value}})
+	return s
+}
+
+// SetMap 按列名顺序设置多个列的值
+func (s *UpdateBuilder) SetMap(values map[string]any) *UpdateBuilder {
+	columns := make([]string, 0, len(values))
+	for column := range values {
+		columns = append(columns, column)
+	}
+	slices.Sort(columns)
+	for _, column := range columns {
+		s.Set(column, values[column])
+	}
+	return s
+}
+
+// SetExpr 使用表达式设置列的值，表达式中的 ? 为参数，如 SetExpr("count", "count + ?", 1)；表达式不能包含外部输入
+func (s *UpdateBuilder) SetExpr(column, expr string, args ...any) *UpdateBuilder {
+	s.sets = append(s.sets, updateSet{column: column, expr: expr, args: args})
+	return s
+}
+
+// Where 追加条件，多次调用的条件之间为 AND
+func (s *UpdateBuilder) Where(conds ...Cond) *UpdateBuilder {
+	s.where = append(s.where, conds...)
+	return s
+}
+
+// All 明确更新全部行，未调用时没有条件的 UPDATE 返回错误
+func (s *UpdateBuilder) All() *UpdateBuilder {
+	s.all = true
+	return s
+}
+
+// Build 生成 SQL 和参数
+func (s *UpdateBuilder) Build() (string, []any, *cd.Error) {
+	w := s.builder.writer()
+	if len(s.sets) == 0 {
+		w.fail("update requires at least one column")
+		return w.result()
+	}
+	if !s.all && isEmptyCond(And(s.where...)) {
+		w.fail("update without where condition, use All() to update all rows")
+		return w.result()
+	}
+
+	w.write("UPDATE ")
+	w.ident(s.table)
+	w.write(" SET ")
+	for idx, set := range s.sets {
+		if idx > 0 {
+			w.write(", ")
+		}
+		w.ident(set.column)
+		w.write(" = ")
+		w.expr(set.expr, set.args)
+	}
+	w.where(And(s.where...))
+	return w.result()
+}
+
+// DeleteBuilder DELETE 语句
+type DeleteBuilder struct {
+	builder *Builder
+	table   string
+	where   []Cond
+	all     bool
+}
+
+// Where 追加条件，多次调用的条件之间为 AND
+func (s *DeleteBuilder) Where(conds ...Cond) *DeleteBuilder {
+	s.where = append(s.where, conds...)
+	return s
+}
+
+// All 明确删除全部行，未调用时没有条件的 DELETE 返回错误
+func (s *DeleteBuilder) All() *DeleteBuilder {
+	s.all = true
+	return s
+}
+
+// Build 生成 SQL 和参数
+func (s *DeleteBuilder) Build() (string, []any, *cd.Error) {
+	w := s.builder.writer()
+	if !s.all && isEmptyCond(And(s.where...)) {
+		w.fail("delete without where condition, use All() to delete all rows")
+		return w.result()
+	}
+	w.write("DELETE FROM ")
+	w.ident(s.table)
+	w.where(And(s.where...))
+	return w.result()
+}
+
+// Cond WHERE 条件，使用 Eq、In、And、Or、Expr 等函数创建和组合
+type Cond interface {
+	writeTo(w *sqlWriter)
+}
+
+type compareCond struct {
+	column string
+	op     string
+	value  any
+}
+
+func (s compareCond) writeTo(w *sqlWriter) {
+	w.ident(s.column)
+	w.write(" " + s.op + " ")
+	w.bind(s.value)
+}
+
+// Eq column = value，value 为 nil 时为 IS NULL
+func Eq(column string, value any) Cond {
+	if value == nil {
+		return IsNull(column)
+	}
+	return compareCond{column: column, op: "=", value: value}
+}
+
+// Ne column <> value，value 为 nil 时为 IS NOT NULL
+func Ne(column string, value any) Cond {
+	if value == nil {
+		return IsNotNull(column)
+	}
+	return compareCond{column: column, op: "<>", value: value}
+}
+
+// Gt column > value
+func Gt(column string, value any) Cond {
+	return compareCond{column: column, op: ">", value: value}
+}
+
+// Gte column >= value
+func Gte(column string, value any) Cond {
+	return compareCond{column: column, op: ">=", value: value}
+}
+
+// Lt column < value
+func Lt(column string, value any) Cond {
+	return compareCond{column: column, op: "<", value: value}
+}
+
+// Lte column <= value
+func Lte(column string, value any) Cond {
+	return compareCond{column: column, op: "<=", value: value}
+}
+
+// Like column LIKE pattern，pattern 中的 % 和 _ 由调用方处理
+func Like(column string, pattern string) Cond {
+	return compareCond{column: column, op: "LIKE", value: pattern}
+}
+
+type nullCond struct {
+	column string
+	not    bool
+}
+
+func (s nullCond) writeTo(w *sqlWriter) {
+	w.ident(s.column)
+	if s.not {
+		w.write(" IS NOT NULL")
+	} else {
+		w.write(" IS NULL")
+	}
+}
+
+// IsNull column IS NULL
+func IsNull(column string) Cond {
+	return nullCond{column: column}
+}
+
+// IsNotNull column IS NOT NULL
+func IsNotNull(column string) Cond {
+	return nullCond{column: column, not: true}
+}
+
+type inCond struct {
+	column string
+	values []any
+	not    bool
+}
+
+func (s inCond) writeTo(w *sqlWriter) {
+	// 空列表的 IN 恒为假，NOT IN 恒为真
+	if len(s.values) == 0 {
+		if s.not {
+			w.write("1 = 1")
+		} else {
+			w.write("1 = 0")
+		}
+		return
+	}
+
+	w.ident(s.column)
+	if s.not {
+		w.write(" NOT IN (")
+	} else {
+		w.write(" IN (")
+	}
+	for idx, val := range s.values {
+		if idx > 0 {
+			w.write(", ")
+		}
+		w.bind(val)
+	}
+	w.write(")")
+}
+
+// In column IN (values...)，values 为空时条件恒为假
+func In[T any](column string, values ...T) Cond {
+	return inCond{column: column, values: toAnySlice(values)}
+}
+
+// NotIn column NOT IN (values...)，values 为空时条件恒为真
+func NotIn[T any](column string, values ...T) Cond {
+	return inCond{column: column, values: toAnySlice(values), not: true}
+}
+
+func toAnySlice[T any](values []T) []any {
+	ret := make([]any, len(values))
+	for idx, val := range values {
+		ret[idx] = val
+	}
+	return ret
+}
+
+type betweenCond struct {
+	column string
+	from   any
+	to     any
+}
+
+func (s betweenCond) writeTo(w *sqlWriter) {
+	w.ident(s.column)
+	w.write(" BETWEEN ")
+	w.bind(s.from)
+	w.write(" AND ")
+	w.bind(s.to)
+}
+
+// Between column BETWEEN from AND to
+func Between(column string, from, to any) Cond {
+	return betweenCond{column: column, from: from, to: to}
+}
+
+type exprCond struct {
+	expr string
+	args []any
+}
+
+func (s exprCond) writeTo(w *sqlWriter) {
+	w.write("(")
+	w.expr(s.expr, s.args)
+	w.write(")")
+}
+
+// Expr 自定义条件，表达式中的 ? 替换为方言的参数占位符，?? 表示 ? 本身；表达式不能包含外部输入
+func Expr(expr string, args ...any) Cond {
+	return exprCond{expr: expr, args: args}
+}
+
+type notCond struct {
+	cond Cond
+}
+
+func (s notCond) writeTo(w *sqlWriter) {
+	w.write("NOT (")
+	s.cond.writeTo(w)
+	w.write(")")
+}
+
+// Not 条件取反，cond 为空时没有条件
+func Not(cond Cond) Cond {
+	if isEmptyCond(cond) {
+		return groupCond{}
+	}
+	return notCond{cond: cond}
+}
+
+type groupCond struct {
+	op    string
+	conds []Cond
+}
+
+func (s groupCond) writeTo(w *sqlWriter) {
+	conds := s.nonEmpty()
+	for idx, cond := range conds {
+		if idx > 0 {
+			w.write(" " + s.op + " ")
+		}
+		// 多个条件的组合作为子条件时加括号
+		cond = unwrapCond(cond)
+		if _, ok := cond.(groupCond); ok && len(conds) > 1 {
+			w.write("(")
+			cond.writeTo(w)
+			w.write(")")
+			continue
+		}
+		cond.writeTo(w)
+	}
+}
+
+func (s groupCond) nonEmpty() []Cond {
+	ret := make([]Cond, 0, len(s.conds))
+	for _, cond := range s.conds {
+		if !isEmptyCond(cond) {
+			ret = append(ret, cond)
+		}
+	}
+	return ret
+}
+
+// And 条件之间为 AND，忽略 nil 和空的组合条件，没有条件时为空
+func And(conds ...Cond) Cond {
+	return groupCond{op: "AND", conds: conds}
+}
+
+// Or 条件之间为 OR，忽略 nil 和空的组合条件，没有条件时为空
+func Or(conds ...Cond) Cond {
+	return groupCond{op: "OR", conds: conds}
+}
+
+// unwrapCond 展开只有一个条件的组合
+func unwrapCond(cond Cond) Cond {
+	for {
+		group, ok := cond.(groupCond)
+		if !ok {
+			return cond
+		}
+		conds := group.nonEmpty()
+		if len(conds) != 1 {
+			return cond
+		}
+		cond = conds[0]
+	}
+}
+
+// isEmptyCond nil 或不包含任何条件的组合
+func isEmptyCond(cond Cond) bool {
+	if cond == nil {
+		return true
+	}
+	group, ok := cond.(groupCond)
+	return ok && len(group.nonEmpty()) == 0
+}
@@ -0,0 +1,222 @@
+package dao
+
+import (
+	"testing"
+
+	cd "github.com/muidea/magicCommon/def"
+	"github.com/muidea/magicCommon/foundation/util"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	pgBuilder    = &Builder{dialect: postgresDialect{}}
+	mysqlBuilder = &Builder{dialect: mysqlDialect{}}
+)
+
+func TestNewBuilder(t *testing.T) {
+	builder, err := NewBuilder("postgres")
+	assert.Nil(t, err)
+	assert.Equal(t, "$2", builder.dialect.Placeholder(2))
+
+	_, err = NewBuilder("sqlite")
+	assert.EqualValues(t, cd.DatabaseError, err.Code)
+
+	_, err = NewBuilder("plain")
+	assert.EqualValues(t, cd.NotImplemented, err.Code)
+
+	dao := &impl{BaseDao: newFakeDao(t, &fakeConnector{}), driverName: "mysql"}
+	builder, err = BuilderOf(dao)
+	assert.Nil(t, err)
+	assert.Equal(t, "?", builder.dialect.Placeholder(2))
+}
+
+func TestPlaceholder(t *testing.T) {
+	assert.Equal(t, "$3", Placeholder("postgres", 3))
+	assert.Equal(t, "?", Placeholder("mysql", 3))
+	// 未实现 Dialect 或未注册的驱动使用 ?
+	assert.Equal(t, "?", Placeholder("plain", 3))
+	assert.Equal(t, "?", Placeholder("sqlite", 3))
+}
+
+func TestSelectBuilder(t *testing.T) {
+	build := func(builder *Builder) *SelectBuilder {
+		return builder.Select("id", "name").
+			From("public.user").
+			Where(Eq("status", 1), Or(Like("name", "a%"), In("role", "admin", "owner"))).
+			Where(IsNull("deleted_at")).
+			OrderBySort(util.NewSortFilter("created_at", false), nil).
+			Page(3, 20)
+	}
+
+	sqlStr, args, err := build(pgBuilder).Build()
+	assert.Nil(t, err)
+	assert.Equal(t, `SELECT "id", "name" FROM "public"."user" WHERE "status" = $1 AND ("name" LIKE $2 OR "role" IN ($3, $4)) AND "deleted_at" IS NULL ORDER BY "created_at" DESC LIMIT 20 OFFSET 40`, sqlStr)
+	assert.Equal(t, []any{1, "a%", "admin", "owner"}, args)
+
+	sqlStr, args, err = build(mysqlBuilder).Build()
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT `id`, `name` FROM `public`.`user` WHERE `status` = ? AND (`name` LIKE ? OR `role` IN (?, ?)) AND `deleted_at` IS NULL ORDER BY `created_at` DESC LIMIT 20 OFFSET 40", sqlStr)
+	assert.Equal(t, []any{1, "a%", "admin", "owner"}, args)
+
+	sqlStr, args, err = build(pgBuilder).Count()
+	assert.Nil(t, err)
+	assert.Equal(t, `SELECT COUNT(*) FROM "public"."user" WHERE "status" = $1 AND ("name" LIKE $2 OR "role" IN ($3, $4)) AND "deleted_at" IS NULL`, sqlStr)
+	assert.Len(t, args, 4)
+
+	sqlStr, _, err = pgBuilder.Select("owner").ColumnExpr("COUNT(*) AS total").From("report").GroupBy("owner").OrderBy("owner", true).Count()
+	assert.Nil(t, err)
+	assert.Equal(t, `SELECT COUNT(*) FROM (SELECT "owner", COUNT(*) AS total FROM "report" GROUP BY "owner") magic_count`, sqlStr)
+
+	sqlStr, args, err = pgBuilder.Select().Distinct().From("report").Build()
+	assert.Nil(t, err)
+	assert.Equal(t, `SELECT DISTINCT * FROM "report"`, sqlStr)
+	assert.Empty(t, args)
+}
+
+func TestSelectBuilder_Invalid(t *testing.T) {
+	_, _, err := pgBuilder.Select("id").From("").Build()
+	assert.EqualValues(t, cd.InvalidParameter, err.Code)
+
+	_, _, err = pgBuilder.Select("a..b").From("report").Build()
+	assert.EqualValues(t, cd.InvalidParameter, err.Code)
+
+	_, _, err = pgBuilder.Select().From("report").Page(0, 10).Build()
+	assert.EqualValues(t, cd.InvalidParameter, err.Code)
+
+	_, _, err = pgBuilder.Select().From("report").Offset(10).Build()
+	assert.EqualValues(t, cd.InvalidParameter, err.Code)
+
+	_, _, err = pgBuilder.Select().From("report").Where(Expr("a = ? AND b = ?", 1)).Build()
+	assert.EqualValues(t, cd.InvalidParameter, err.Code)
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	// 标识符中的引号加倍，不能闭合转义
+	sqlStr, _, err := pgBuilder.Select(`name"; DROP TABLE user; --`).From("report").OrderBySort(util.NewSortFilter("id` DESC; --", true)).Build()
+	assert.Nil(t, err)
+	assert.Equal(t, `SELECT "name""; DROP TABLE user; --" FROM "report" ORDER BY "id`+"`"+` DESC; --" ASC`, sqlStr)
+
+	sqlStr, _, err = mysqlBuilder.Select("report.*", "na`me").From("report").Build()
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT `report`.*, `na``me` FROM `report`", sqlStr)
+}
+
+func TestConditions(t *testing.T) {
+	testCases := []struct {
+		name string
+		cond Cond
+		sql  string
+		args []any
+	}{
+		{"eq nil", Eq("a", nil), `"a" IS NULL`, nil},
+		{"ne nil", Ne("a", nil), `"a" IS NOT NULL`, nil},
+		{"compare", And(Gt("a", 1), Gte("b", 2), Lt("c", 3), Lte("d", 4), Ne("e", 5)), `"a" > $1 AND "b" >= $2 AND "c" < $3 AND "d" <= $4 AND "e" <> $5`, []any{1, 2, 3, 4, 5}},
+		{"between", Between("a", 1, 9), `"a" BETWEEN $1 AND $2`, []any{1, 9}},
+		{"in slice", In("a", []int64{1, 2}...), `"a" IN ($1, $2)`, []any{int64(1), int64(2)}},
+		{"empty in", In[int]("a"), `1 = 0`, nil},
+		{"empty not in", NotIn[int]("a"), `1 = 1`, nil},
+		{"not in", NotIn("a", "x"), `"a" NOT IN ($1)`, []any{"x"}},
+		{"not", Not(Or(Eq("a", 1), Eq("b", 2))), `NOT ("a" = $1 OR "b" = $2)`, []any{1, 2}},
+		{"nested", Or(And(Eq("a", 1), Eq("b", 2)), Eq("c", 3)), `("a" = $1 AND "b" = $2) OR "c" = $3`, []any{1, 2, 3}},
+		{"single child group", And(Eq("a", 1), Or(Or(Eq("b", 2), Eq("c", 3)))), `"a" = $1 AND ("b" = $2 OR "c" = $3)`, []any{1, 2, 3}},
+		{"skip empty", And(nil, Or(), Eq("a", 1), Not(And())), `"a" = $1`, []any{1}},
+		{"expr", Expr("data ?? 'key?' AND age > ?", 18), `(data ? 'key?' AND age > $1)`, []any{18}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sqlStr, args, err := pgBuilder.Delete("t").Where(tc.cond).Build()
+			assert.Nil(t, err)
+			assert.Equal(t, `DELETE FROM "t" WHERE `+tc.sql, sqlStr)
+			if tc.args == nil {
+				assert.Empty(t, args)
+			} else {
+				assert.Equal(t, tc.args, args)
+			}
+		})
+	}
+
+	// 条件全部为空时需要显式调用 All
+	_, _, err := pgBuilder.Delete("t").Where(And(), nil).Build()
+	assert.EqualValues(t, cd.InvalidParameter, err.Code)
+	_, _, err = pgBuilder.Delete("t").Build()
+	assert.EqualValues(t, cd.InvalidParameter, err.Code)
+
+	sqlStr, args, err := pgBuilder.Delete("t").Where(And(), nil).All().Build()
+	assert.Nil(t, err)
+	assert.Equal(t, `DELETE FROM "t"`, sqlStr)
+	assert.Empty(t, args)
+}
+
+func TestInsertBuilder(t *testing.T) {
+	sqlStr, args, err := pgBuilder.Insert("user").Columns("id", "name").Values(1, "a").Values(2, "b").Build()
+	assert.Nil(t, err)
+	assert.Equal(t, `INSERT INTO "user" ("id", "name") VALUES ($1, $2), ($3, $4)`, sqlStr)
+	assert.Equal(t, []any{1, "a", 2, "b"}, args)
+
+	_, _, err = pgBuilder.Insert("user").Columns("id", "name").Values(1).Build()
+	assert.EqualValues(t, cd.InvalidParameter, err.Code)
+
+	_, _, err = pgBuilder.Insert("user").Build()
+	assert.EqualValues(t, cd.InvalidParameter, err.Code)
+}
+
+func TestUpsertBuilder(t *testing.T) {
+	upsert := func(builder *Builder) *InsertBuilder {
+		return builder.Upsert("user", "id").Columns("id", "name", "email").Values(1, "a", "a@x")
+	}
+
+	sqlStr, args, err := upsert(pgBuilder).Build()
+	assert.Nil(t, err)
+	assert.Equal(t, `INSERT INTO "user" ("id", "name", "email") VALUES ($1, $2, $3) ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name", "email" = EXCLUDED."email"`, sqlStr)
+	assert.Equal(t, []any{1, "a", "a@x"}, args)
+
+	sqlStr, _, err = upsert(mysqlBuilder).Build()
+	assert.Nil(t, err)
+	assert.Equal(t, "INSERT INTO `user` (`id`, `name`, `email`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `name` = VALUES(`name`), `email` = VALUES(`email`)", sqlStr)
+
+	sqlStr, _, err = upsert(pgBuilder).DoUpdate("email").Build()
+	assert.Nil(t, err)
+	assert.Equal(t, `INSERT INTO "user" ("id", "name", "email") VALUES ($1, $2, $3) ON CONFLICT ("id") DO UPDATE SET "email" = EXCLUDED."email"`, sqlStr)
+
+	sqlStr, _, err = upsert(pgBuilder).DoUpdate().Build()
+	assert.Nil(t, err)
+	assert.Equal(t, `INSERT INTO "user" ("id", "name", "email") VALUES ($1, $2, $3) ON CONFLICT ("id") DO NOTHING`, sqlStr)
+
+	sqlStr, _, err = upsert(mysqlBuilder).DoUpdate().Build()
+	assert.Nil(t, err)
+	assert.Equal(t, "INSERT INTO `user` (`id`, `name`, `email`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `id` = `id`", sqlStr)
+
+	_, _, err = pgBuilder.Insert("user").OnConflict().Columns("id").Values(1).Build()
+	assert.EqualValues(t, cd.InvalidParameter, err.Code)
+}
+
+func TestUpdateBuilder(t *testing.T) {
+	sqlStr, args, err := pgBuilder.Update("stock").
+		SetMap(map[string]any{"updated_by": "u1", "name": "n"}).
+		SetExpr("count", "count - ?", 2).
+		Where(Eq("id", 7), Gte("count", 2)).
+		Build()
+	assert.Nil(t, err)
+	assert.Equal(t, `UPDATE "stock" SET "name" = $1, "updated_by" = $2, "count" = count - $3 WHERE "id" = $4 AND "count" >= $5`, sqlStr)
+	assert.Equal(t, []any{"n", "u1", 2, 7, 2}, args)
+
+	sqlStr, args, err = mysqlBuilder.Update("stock").Set("name", "n").Where(Eq("id", 7)).Build()
+	assert.Nil(t, err)
+	assert.Equal(t, "UPDATE `stock` SET `name` = ? WHERE `id` = ?", sqlStr)
+	assert.Equal(t, []any{"n", 7}, args)
+
+	_, _, err = pgBuilder.Update("stock").Where(Eq("id", 7)).Build()
+	assert.EqualValues(t, cd.InvalidParameter, err.Code)
+
+	// 没有条件的 UPDATE 需要显式调用 All
+	_, _, err = pgBuilder.Update("stock").Set("name", "n").Build()
+	assert.EqualValues(t, cd.InvalidParameter, err.Code)
+	_, _, err = pgBuilder.Update("stock").Set("name", "n").Where(Or()).Build()
+	assert.EqualValues(t, cd.InvalidParameter, err.Code)
+
+	sqlStr, args, err = mysqlBuilder.Update("stock").Set("name", "n").All().Build()
+	assert.Nil(t, err)
+	assert.Equal(t, "UPDATE `stock` SET `name` = ?", sqlStr)
+	assert.Equal(t, []any{"n"}, args)
+}
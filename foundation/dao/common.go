@@ -1,10 +1,13 @@
 package dao
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	cd "github.com/muidea/magicCommon/def"
 	"github.com/muidea/magicCommon/foundation/util"
@@ -17,22 +20,31 @@ type Dao interface {
 	String() string
 	Release() *cd.Error
 	Ping() *cd.Error
+	PingContext(ctx context.Context) *cd.Error
 	BeginTransaction() *cd.Error
+	BeginTransactionContext(ctx context.Context) *cd.Error
 	CommitTransaction() *cd.Error
 	RollbackTransaction() *cd.Error
 	CreateDatabase(dbName string) *cd.Error
 	DropDatabase(dbName string) *cd.Error
 	UseDatabase(dbName string) *cd.Error
 	Query(sql string, args ...any) *cd.Error
+	QueryContext(ctx context.Context, sql string, args ...any) *cd.Error
 	Next() bool
 	Finish() *cd.Error
 	GetField(value ...interface{}) *cd.Error
+	Columns() ([]string, *cd.Error)
 	Insert(sql string, args ...any) (int64, *cd.Error)
 	Update(sql string, args ...any) (int64, *cd.Error)
 	Delete(sql string, args ...any) (int64, *cd.Error)
 	Execute(sql string, args ...any) (int64, *cd.Error)
+	InsertContext(ctx context.Context, sql string, args ...any) (int64, *cd.Error)
+	UpdateContext(ctx context.Context, sql string, args ...any) (int64, *cd.Error)
+	DeleteContext(ctx context.Context, sql string, args ...any) (int64, *cd.Error)
+	ExecuteContext(ctx context.Context, sql string, args ...any) (int64, *cd.Error)
 	CheckTableExist(tableName string) (bool, string, *cd.Error)
 	Duplicate() (Dao, *cd.Error)
+	WithTransaction(ctx context.Context, fn func(tx Dao) error, opts ...TxOption) *cd.Error
 }
 
 // Driver 数据库驱动接口
@@ -96,18 +108,25 @@ var (
 	ErrResultSetNotClosed     = cd.NewError(cd.InvalidOperation, "result set not closed")
 	ErrInvalidParameter       = cd.NewError(cd.InvalidParameter, "invalid parameter")
 	ErrDriverNotFound         = cd.NewError(cd.DatabaseError, "database driver not found")
+	ErrNoRows                 = cd.NewError(cd.NotFound, "no rows in result set")
 )
 
 // BaseDao 基础DAO结构，包含所有公共字段
 type BaseDao struct {
-	dbHandle   *sql.DB
-	dbTxCount  int32
-	dbTx       *sql.Tx
-	rowsHandle *sql.Rows
-	user       string
-	password   string
-	address    string
-	dbName     string
+	dbHandle       *sql.DB
+	dbConn         *sql.Conn // 固定使用的连接，见 pinConnection
+	dbTxCount      int32
+	dbTx           *sql.Tx
+	dbTxCtx        context.Context
+	savepointDepth int // 嵌套 WithTransaction 的层数
+	rowsHandle     *sql.Rows
+	rowsCtx        context.Context
+	rowsCancel     context.CancelFunc
+	queryTimeout   time.Duration
+	user           string
+	password       string
+	address        string
+	dbName         string
 }
 
 // DaoOption 配置选项函数类型
@@ -141,6 +160,14 @@ func WithDBName(dbName string) DaoOption {
 	}
 }
 
+// WithQueryTimeout 设置单条语句的默认超时，超时返回 cd.Timeout；0 表示不限制。
+// 调用方 ctx 的截止时间更早时以 ctx 为准，查询的超时覆盖到 Finish 为止
+func WithQueryTimeout(timeout time.Duration) DaoOption {
+	return func(d *BaseDao) {
+		d.queryTimeout = timeout
+	}
+}
+
 // NewBaseDao 创建基础DAO实例（新版本，支持 Functional Options）
 func NewBaseDao(dbHandle *sql.DB, opts ...DaoOption) *BaseDao {
 	dao := &BaseDao{
@@ -197,6 +224,96 @@ func logSQLError(operation, sqlStr string, err error) *cd.Error {
 	return WrapError(err)
 }
 
+// logStatementError 语句因 ctx 超时或取消失败时返回 cd.Timeout 或 cd.Unexpected，其它错误同 logSQLError
+func logStatementError(ctx context.Context, operation, sqlStr string, err error) *cd.Error {
+	ctxErr := ctx.Err()
+	if ctxErr == nil && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+		return logSQLError(operation, sqlStr, err)
+	}
+
+	slog.Warn("SQL operation interrupted",
+		"operation", operation,
+		"sql", sqlStr,
+		"error", err)
+	if errors.Is(ctxErr, context.DeadlineExceeded) || errors.Is(err, context.DeadlineExceeded) {
+		return util.DatabaseErrorFactory.Wrap(cd.Timeout, err, "database operation timeout")
+	}
+	return util.DatabaseErrorFactory.Wrap(cd.Unexpected, err, "database operation canceled")
+}
+
+// statementContext 为单条语句附加默认超时
+func (s *BaseDao) statementContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if s.queryTimeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, s.queryTimeout)
+}
+
+// baseDao 供包内的迁移等功能访问具体 Dao 内嵌的 BaseDao
+func (s *BaseDao) baseDao() *BaseDao {
+	return s
+}
+
+// pinConnection 在 fn 执行期间固定使用同一个连接，用于依赖会话状态的操作（例如会话级的锁）；
+// 期间开始的事务也使用该连接。已处于事务中时返回 ErrTransactionActive
+func (s *BaseDao) pinConnection(ctx context.Context, fn func() *cd.Error) *cd.Error {
+	if s.dbHandle == nil {
+		return ErrDatabaseNotInitialized
+	}
+	if s.dbTx != nil {
+		return ErrTransactionActive
+	}
+	if s.dbConn != nil {
+		return fn()
+	}
+
+	conn, err := s.dbHandle.Conn(ctx)
+	if err != nil {
+		return logStatementError(ctx, "pin connection", "", err)
+	}
+
+	_ = s.closeRows()
+	s.dbConn = conn
+	defer func() {
+		// 结果集占用连接，先关闭结果集再归还连接
+		_ = s.closeRows()
+		s.dbConn = nil
+		_ = conn.Close()
+	}()
+	return fn()
+}
+
+// closeRows 关闭结果集并释放查询的 ctx
+func (s *BaseDao) closeRows() error {
+	var err error
+	if s.rowsHandle != nil {
+		err = s.rowsHandle.Close()
+	}
+	if s.rowsCancel != nil {
+		s.rowsCancel()
+	}
+	s.rowsHandle = nil
+	s.rowsCtx = nil
+	s.rowsCancel = nil
+	return err
+}
+
+// rowsError 结果集读取失败时区分查询超时
+func (s *BaseDao) rowsError(operation string, err error) *cd.Error {
+	if err == nil {
+		return nil
+	}
+	if s.rowsCtx != nil && s.rowsCtx.Err() != nil {
+		return logStatementError(s.rowsCtx, operation, "", err)
+	}
+
+	return WrapError(err)
+}
+
 // DBName 返回数据库名称
 func (s *BaseDao) DBName() string {
 	return s.dbName
@@ -209,12 +326,9 @@ func (s *BaseDao) String() string {
 
 // Release 释放数据库连接
 func (s *BaseDao) Release() *cd.Error {
-	if s.rowsHandle != nil {
-		if err := s.rowsHandle.Close(); err != nil {
-			slog.Error("failed to close rows handle", "error", err)
-		}
+	if err := s.closeRows(); err != nil {
+		slog.Error("failed to close rows handle", "error", err)
 	}
-	s.rowsHandle = nil
 
 	if s.dbHandle != nil {
 		if err := s.dbHandle.Close(); err != nil {
@@ -228,37 +342,35 @@ func (s *BaseDao) Release() *cd.Error {
 
 // Ping 检查数据库连接
 func (s *BaseDao) Ping() *cd.Error {
-	if s.dbHandle == nil {
-		return ErrDatabaseNotInitialized
-	}
-
-	return WrapError(s.dbHandle.Ping())
+	return s.PingContext(context.Background())
 }
 
-// BeginTransaction 开始事务
-func (s *BaseDao) BeginTransaction() *cd.Error {
+// PingContext 检查数据库连接，受 ctx 和默认超时约束
+func (s *BaseDao) PingContext(ctx context.Context) *cd.Error {
 	if s.dbHandle == nil {
 		return ErrDatabaseNotInitialized
 	}
 
-	atomic.AddInt32(&s.dbTxCount, 1)
-	if s.dbTx == nil && s.dbTxCount == 1 {
-		if s.rowsHandle != nil {
-			_ = s.rowsHandle.Close()
-		}
-		s.rowsHandle = nil
-
-		tx, err := s.dbHandle.Begin()
-		if err != nil {
-			return WrapError(err)
-		}
-
-		s.dbTx = tx
+	pingCtx, cancel := s.statementContext(ctx)
+	defer cancel()
+	if err := s.dbHandle.PingContext(pingCtx); err != nil {
+		return logStatementError(pingCtx, "ping database", "", err)
 	}
 
 	return nil
 }
 
+// BeginTransaction 开始事务
+func (s *BaseDao) BeginTransaction() *cd.Error {
+	return s.BeginTransactionContext(context.Background())
+}
+
+// BeginTransactionContext 开始事务，ctx 约束整个事务，ctx 结束后事务由 database/sql 自动回滚；
+// 默认超时只作用于事务内的单条语句。嵌套调用时只有最外层的 ctx 生效
+func (s *BaseDao) BeginTransactionContext(ctx context.Context) *cd.Error {
+	return s.beginTransaction(ctx, nil)
+}
+
 // CommitTransaction 提交事务
 func (s *BaseDao) CommitTransaction() *cd.Error {
 	if s.dbHandle == nil {
@@ -268,8 +380,7 @@ func (s *BaseDao) CommitTransaction() *cd.Error {
 	atomic.AddInt32(&s.dbTxCount, -1)
 	if s.dbTx != nil && s.dbTxCount == 0 {
 		err := s.dbTx.Commit()
-		s.dbTx = nil
-		return WrapError(err)
+		return s.finishTransaction("commit transaction", err)
 	}
 
 	return nil
@@ -284,35 +395,60 @@ func (s *BaseDao) RollbackTransaction() *cd.Error {
 	atomic.AddInt32(&s.dbTxCount, -1)
 	if s.dbTx != nil && s.dbTxCount == 0 {
 		err := s.dbTx.Rollback()
-		s.dbTx = nil
-		return WrapError(err)
+		return s.finishTransaction("rollback transaction", err)
 	}
 
 	return nil
 }
 
+func (s *BaseDao) finishTransaction(operation string, err error) *cd.Error {
+	txCtx := s.dbTxCtx
+	s.dbTx = nil
+	s.dbTxCtx = nil
+	if err == nil {
+		return nil
+	}
+	// 事务的 ctx 已结束时，database/sql 已自动回滚
+	if txCtx != nil && txCtx.Err() != nil {
+		return logStatementError(txCtx, operation, "", err)
+	}
+
+	return WrapError(err)
+}
+
 // Query 执行查询
 func (s *BaseDao) Query(sqlStr string, args ...any) *cd.Error {
+	return s.QueryContext(context.Background(), sqlStr, args...)
+}
+
+// QueryContext 执行查询，ctx 与默认超时一直作用到 Finish，期间超时或取消会中断结果集的读取
+func (s *BaseDao) QueryContext(ctx context.Context, sqlStr string, args ...any) *cd.Error {
 	if s.dbHandle == nil {
 		return ErrDatabaseNotInitialized
 	}
 
-	if s.rowsHandle != nil {
-		_ = s.rowsHandle.Close()
-	}
-	s.rowsHandle = nil
+	_ = s.closeRows()
 
+	queryCtx, cancel := s.statementContext(ctx)
+	var rows *sql.Rows
 	var err error
-	if s.dbTx != nil {
-		s.rowsHandle, err = s.dbTx.Query(sqlStr, args...)
-	} else {
-		s.rowsHandle, err = s.dbHandle.Query(sqlStr, args...)
+	switch {
+	case s.dbTx != nil:
+		rows, err = s.dbTx.QueryContext(queryCtx, sqlStr, args...)
+	case s.dbConn != nil:
+		rows, err = s.dbConn.QueryContext(queryCtx, sqlStr, args...)
+	default:
+		rows, err = s.dbHandle.QueryContext(queryCtx, sqlStr, args...)
 	}
 
 	if err != nil {
-		return logSQLError("execute query", sqlStr, err)
+		cancel()
+		return logStatementError(queryCtx, "execute query", sqlStr, err)
 	}
 
+	s.rowsHandle = rows
+	s.rowsCtx = queryCtx
+	s.rowsCancel = cancel
 	return nil
 }
 
@@ -331,9 +467,14 @@ func (s *BaseDao) Finish() *cd.Error {
 		return nil
 	}
 
-	err := s.rowsHandle.Close()
-	s.rowsHandle = nil
-	return WrapError(err)
+	// 读取过程中出错（包括超时或取消）时 Next 返回 false，这里返回对应的错误
+	if rowsErr := s.rowsHandle.Err(); rowsErr != nil {
+		err := s.rowsError("fetch rows", rowsErr)
+		_ = s.closeRows()
+		return err
+	}
+
+	return WrapError(s.closeRows())
 }
 
 // GetField 获取字段值
@@ -342,40 +483,79 @@ func (s *BaseDao) GetField(value ...interface{}) *cd.Error {
 		return ErrResultSetNotClosed
 	}
 
-	return WrapError(s.rowsHandle.Scan(value...))
+	return s.rowsError("scan rows", s.rowsHandle.Scan(value...))
+}
+
+// Columns 返回当前结果集的列名
+func (s *BaseDao) Columns() ([]string, *cd.Error) {
+	if s.rowsHandle == nil {
+		return nil, ErrResultSetNotClosed
+	}
+
+	columns, err := s.rowsHandle.Columns()
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	return columns, nil
 }
 
 // Insert 执行插入
 func (s *BaseDao) Insert(sqlStr string, args ...any) (int64, *cd.Error) {
-	return s.Execute(sqlStr, args...)
+	return s.ExecuteContext(context.Background(), sqlStr, args...)
 }
 
 // Update 执行更新
 func (s *BaseDao) Update(sqlStr string, args ...any) (int64, *cd.Error) {
-	return s.Execute(sqlStr, args...)
+	return s.ExecuteContext(context.Background(), sqlStr, args...)
 }
 
 // Delete 执行删除
 func (s *BaseDao) Delete(sqlStr string, args ...any) (int64, *cd.Error) {
-	return s.Execute(sqlStr, args...)
+	return s.ExecuteContext(context.Background(), sqlStr, args...)
 }
 
 // Execute 执行SQL语句
 func (s *BaseDao) Execute(sqlStr string, args ...any) (int64, *cd.Error) {
+	return s.ExecuteContext(context.Background(), sqlStr, args...)
+}
+
+// InsertContext 执行插入
+func (s *BaseDao) InsertContext(ctx context.Context, sqlStr string, args ...any) (int64, *cd.Error) {
+	return s.ExecuteContext(ctx, sqlStr, args...)
+}
+
+// UpdateContext 执行更新
+func (s *BaseDao) UpdateContext(ctx context.Context, sqlStr string, args ...any) (int64, *cd.Error) {
+	return s.ExecuteContext(ctx, sqlStr, args...)
+}
+
+// DeleteContext 执行删除
+func (s *BaseDao) DeleteContext(ctx context.Context, sqlStr string, args ...any) (int64, *cd.Error) {
+	return s.ExecuteContext(ctx, sqlStr, args...)
+}
+
+// ExecuteContext 执行SQL语句，受 ctx 和默认超时约束
+func (s *BaseDao) ExecuteContext(ctx context.Context, sqlStr string, args ...any) (int64, *cd.Error) {
 	if s.dbHandle == nil {
 		return 0, ErrDatabaseNotInitialized
 	}
 
+	execCtx, cancel := s.statementContext(ctx)
+	defer cancel()
+
 	var result sql.Result
 	var err error
-	if s.dbTx != nil {
-		result, err = s.dbTx.Exec(sqlStr, args...)
-	} else {
-		result, err = s.dbHandle.Exec(sqlStr, args...)
+	switch {
+	case s.dbTx != nil:
+		result, err = s.dbTx.ExecContext(execCtx, sqlStr, args...)
+	case s.dbConn != nil:
+		result, err = s.dbConn.ExecContext(execCtx, sqlStr, args...)
+	default:
+		result, err = s.dbHandle.ExecContext(execCtx, sqlStr, args...)
 	}
 
 	if err != nil {
-		return 0, logSQLError("execute sql", sqlStr, err)
+		return 0, logStatementError(execCtx, "execute sql", sqlStr, err)
 	}
 
 	affectedRows, err := result.RowsAffected()
@@ -8,6 +8,23 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// openTestMySQL 打开测试用的 MySQL 连接，MySQL 不可用时跳过测试
+func openTestMySQL(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("mysql", "root:rootkit@tcp(localhost:3306)/testdb")
+	if err == nil {
+		if err = db.Ping(); err != nil {
+			_ = db.Close()
+		}
+	}
+	if err != nil {
+		t.Skipf("MySQL not available, skipping connection pool test: %v", err)
+	}
+
+	return db
+}
+
 // TestConnectionPoolDefaultSettings 测试默认连接池设置
 func TestConnectionPoolDefaultSettings(t *testing.T) {
 	t.Run("验证默认连接池设置", func(t *testing.T) {
@@ -60,11 +77,7 @@ func TestConnectionPoolDefaultSettings(t *testing.T) {
 func TestConnectionPoolConfiguration(t *testing.T) {
 	t.Run("验证连接池配置方法", func(t *testing.T) {
 		// 创建一个测试数据库连接
-		db, err := sql.Open("mysql", "root:rootkit@tcp(localhost:3306)/testdb")
-		if err != nil {
-			t.Skip("MySQL not available, skipping connection pool test")
-			return
-		}
+		db := openTestMySQL(t)
 		defer func() { _ = db.Close() }()
 
 		// 配置连接池参数
@@ -74,7 +87,7 @@ func TestConnectionPoolConfiguration(t *testing.T) {
 		db.SetConnMaxIdleTime(30 * time.Minute) // 连接最大空闲时间
 
 		// 验证配置生效
-		err = db.Ping()
+		err := db.Ping()
 		assert.Nil(t, err, "配置后数据库连接应该成功")
 
 		// 执行一个简单查询来验证连接池工作
@@ -97,11 +110,7 @@ func TestConnectionPoolConfiguration(t *testing.T) {
 func TestConnectionPoolConcurrentAccess(t *testing.T) {
 	t.Run("验证并发访问连接池", func(t *testing.T) {
 		// 创建一个测试数据库连接
-		db, err := sql.Open("mysql", "root:rootkit@tcp(localhost:3306)/testdb")
-		if err != nil {
-			t.Skip("MySQL not available, skipping concurrent test")
-			return
-		}
+		db := openTestMySQL(t)
 		defer func() { _ = db.Close() }()
 
 		// 配置适中的连接池大小
@@ -209,11 +218,7 @@ func TestConnectionPoolErrorHandling(t *testing.T) {
 		}
 
 		// 测试配置无效参数
-		db2, err := sql.Open("mysql", "root:rootkit@tcp(localhost:3306)/testdb")
-		if err != nil {
-			t.Skip("MySQL not available, skipping error handling test")
-			return
-		}
+		db2 := openTestMySQL(t)
 		defer func() { _ = db2.Close() }()
 
 		// 设置无效的连接池参数（应该被正确处理）
@@ -0,0 +1,146 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	cd "github.com/muidea/magicCommon/def"
+)
+
+const (
+	BaseTable = "BASE TABLE"
+	View      = "VIEW"
+)
+
+// schemaDriver 由内置驱动实现，提供连接池配置和库表操作的差异；
+// 其它驱动创建的 Dao 不配置连接池，库表操作返回 cd.NotImplemented
+type schemaDriver interface {
+	configurePool(db *sql.DB)
+	createDatabase(dao *impl, dbName string) *cd.Error
+	dropDatabase(dao *impl, dbName string) *cd.Error
+	useDatabase(dao *impl, dbName string) *cd.Error
+	tableExistSQL(dao *impl, tableName string) string
+}
+
+type impl struct {
+	*BaseDao
+	driverName string
+}
+
+// Fetch 获取一个数据访问对象（使用默认驱动 DefaultDriverName）
+func Fetch(user, password, address, dbName string, opts ...DaoOption) (Dao, *cd.Error) {
+	return FetchWithDriver(DefaultDriverName, user, password, address, dbName, opts...)
+}
+
+// FetchWithDriver 使用指定驱动获取数据访问对象，opts 可以设置 WithQueryTimeout 等选项
+func FetchWithDriver(driverName, user, password, address, dbName string, opts ...DaoOption) (Dao, *cd.Error) {
+	driver, ok := GetDriver(driverName)
+	if !ok {
+		return nil, cd.NewError(cd.DatabaseError, fmt.Sprintf("database driver '%s' not found", driverName))
+	}
+
+	connectStr := driver.DefaultConnectionString(user, password, address, dbName)
+	db, err := driver.Open(connectStr)
+	if err != nil {
+		return nil, logDatabaseError("open database", connectStr, err)
+	}
+
+	if schema, ok := driver.(schemaDriver); ok {
+		schema.configurePool(db)
+	}
+
+	err = db.Ping()
+	if err != nil {
+		_ = db.Close()
+		return nil, logDatabaseError("ping database", connectStr, err)
+	}
+
+	baseDao := NewBaseDaoLegacy(db, user, password, address, dbName)
+	for _, opt := range opts {
+		opt(baseDao)
+	}
+	return &impl{BaseDao: baseDao, driverName: driver.Name()}, nil
+}
+
+// DriverName 返回当前连接使用的驱动名称
+func (s *impl) DriverName() string {
+	return s.driverName
+}
+
+func (s *impl) schemaDriver() (schemaDriver, bool) {
+	driver, ok := GetDriver(s.driverName)
+	if !ok {
+		return nil, false
+	}
+
+	schema, ok := driver.(schemaDriver)
+	return schema, ok
+}
+
+// CreateDatabase 创建数据库
+func (s *impl) CreateDatabase(dbName string) *cd.Error {
+	schema, ok := s.schemaDriver()
+	if !ok {
+		return s.BaseDao.CreateDatabase(dbName)
+	}
+
+	return schema.createDatabase(s, dbName)
+}
+
+// DropDatabase 删除数据库
+func (s *impl) DropDatabase(dbName string) *cd.Error {
+	schema, ok := s.schemaDriver()
+	if !ok {
+		return s.BaseDao.DropDatabase(dbName)
+	}
+
+	return schema.dropDatabase(s, dbName)
+}
+
+// UseDatabase 使用数据库
+func (s *impl) UseDatabase(dbName string) *cd.Error {
+	schema, ok := s.schemaDriver()
+	if !ok {
+		return s.BaseDao.UseDatabase(dbName)
+	}
+
+	return schema.useDatabase(s, dbName)
+}
+
+// CheckTableExist 检查表是否存在
+func (s *impl) CheckTableExist(tableName string) (bool, string, *cd.Error) {
+	schema, ok := s.schemaDriver()
+	if !ok {
+		return s.BaseDao.CheckTableExist(tableName)
+	}
+
+	err := s.Query(schema.tableExistSQL(s, tableName))
+	if err != nil {
+		return false, "", err
+	}
+
+	defer func() { _ = s.Finish() }()
+
+	var tableNameVal, tableTypeVal sql.NullString
+	if s.Next() {
+		err = s.GetField(&tableNameVal, &tableTypeVal)
+		if err != nil {
+			return false, "", err
+		}
+
+		return true, tableTypeVal.String, nil
+	}
+
+	return false, "", nil
+}
+
+// WithTransaction 在事务中执行 fn，fn 收到的 tx 即当前 Dao
+func (s *impl) WithTransaction(ctx context.Context, fn func(tx Dao) error, opts ...TxOption) *cd.Error {
+	return runTransaction(ctx, s, s.BaseDao, fn, opts...)
+}
+
+// Duplicate 复制DAO实例
+func (s *impl) Duplicate() (Dao, *cd.Error) {
+	return FetchWithDriver(s.driverName, s.user, s.password, s.address, s.dbName, WithQueryTimeout(s.queryTimeout))
+}
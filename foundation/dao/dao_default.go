@@ -0,0 +1,7 @@
+//go:build !mysql
+// +build !mysql
+
+package dao
+
+// DefaultDriverName Fetch 使用的默认驱动，使用 -tags mysql 编译时为 mysql
+const DefaultDriverName = "postgres"
@@ -0,0 +1,7 @@
+//go:build mysql
+// +build mysql
+
+package dao
+
+// DefaultDriverName Fetch 使用的默认驱动，与之前 -tags mysql 编译的行为保持一致
+const DefaultDriverName = "mysql"
@@ -1,20 +1,20 @@
-//go:build mysql
-// +build mysql
-
 package dao
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	cd "github.com/muidea/magicCommon/def"
 
-	_ "github.com/go-sql-driver/mysql" //引入Mysql驱动
+	"github.com/go-sql-driver/mysql" //引入Mysql驱动
 )
 
-// mysqlDriver MySQL驱动实现
-type mysqlDriver struct{}
+// mysqlDriver MySQL驱动实现，同时提供 MySQL 方言
+type mysqlDriver struct {
+	mysqlDialect
+}
 
 func (d *mysqlDriver) Open(connectionString string) (*sql.DB, error) {
 	return sql.Open("mysql", connectionString)
@@ -28,39 +28,18 @@ func (d *mysqlDriver) DefaultConnectionString(user, password, address, dbName st
 	return fmt.Sprintf("%s:%s@tcp(%s)/%s", user, password, address, dbName)
 }
 
-// init 注册MySQL驱动
-func init() {
-	RegisterDriver("mysql", &mysqlDriver{})
-}
-
-const (
-	BaseTable = "BASE TABLE"
-	View      = "VIEW"
-)
-
-type impl struct {
-	*BaseDao
-}
-
-// Fetch 获取一个数据访问对象（使用默认MySQL驱动）
-func Fetch(user, password, address, dbName string) (Dao, *cd.Error) {
-	return FetchWithDriver("mysql", user, password, address, dbName)
-}
-
-// FetchWithDriver 使用指定驱动获取数据访问对象
-func FetchWithDriver(driverName, user, password, address, dbName string) (Dao, *cd.Error) {
-	driver, ok := GetDriver(driverName)
-	if !ok {
-		return nil, cd.NewError(cd.DatabaseError, fmt.Sprintf("database driver '%s' not found", driverName))
+// IsRetryable 死锁（1213）可以重试整个事务，MySQL 的序列化冲突同样以死锁报告
+func (d *mysqlDriver) IsRetryable(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1213
 	}
 
-	connectStr := driver.DefaultConnectionString(user, password, address, dbName)
-	db, err := driver.Open(connectStr)
-	if err != nil {
-		return nil, logDatabaseError("open database", connectStr, err)
-	}
+	return false
+}
 
-	// 配置连接池优化参数
+// configurePool 配置连接池优化参数
+func (d *mysqlDriver) configurePool(db *sql.DB) {
 	// 生产环境推荐配置：
 	// - SetMaxOpenConns: 根据应用负载调整，通常为 (核心数 * 2) + 有效磁盘数
 	// - SetMaxIdleConns: 设置为与SetMaxOpenConns相同或略小，避免频繁创建连接
@@ -70,72 +49,41 @@ func FetchWithDriver(driverName, user, password, address, dbName string) (Dao, *
 	db.SetMaxIdleConns(10)                  // 最大空闲连接数
 	db.SetConnMaxLifetime(30 * time.Minute) // 连接最大生命周期
 	db.SetConnMaxIdleTime(5 * time.Minute)  // 连接最大空闲时间
-
-	err = db.Ping()
-	if err != nil {
-		return nil, logDatabaseError("ping database", connectStr, err)
-	}
-
-	baseDao := NewBaseDaoLegacy(db, user, password, address, dbName)
-	return &impl{BaseDao: baseDao}, nil
 }
 
-// CreateDatabase 创建数据库
-func (s *impl) CreateDatabase(dbName string) *cd.Error {
-	_, err := s.Execute(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", dbName))
+func (d *mysqlDriver) createDatabase(dao *impl, dbName string) *cd.Error {
+	_, err := dao.Execute(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", dbName))
 	if err != nil {
 		return err
 	}
-	_, err = s.Execute("FLUSH TABLES")
+	_, err = dao.Execute("FLUSH TABLES")
 	return err
 }
 
-// DropDatabase 删除数据库
-func (s *impl) DropDatabase(dbName string) *cd.Error {
-	_, err := s.Execute(fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", dbName))
+func (d *mysqlDriver) dropDatabase(dao *impl, dbName string) *cd.Error {
+	_, err := dao.Execute(fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", dbName))
 	if err != nil {
 		return err
 	}
-	_, err = s.Execute("FLUSH TABLES")
+	_, err = dao.Execute("FLUSH TABLES")
 	return err
 }
 
-// UseDatabase 使用数据库
-func (s *impl) UseDatabase(dbName string) *cd.Error {
-	s.dbName = dbName
-	_, err := s.Execute(fmt.Sprintf("USE `%s`", dbName))
+func (d *mysqlDriver) useDatabase(dao *impl, dbName string) *cd.Error {
+	dao.dbName = dbName
+	_, err := dao.Execute(fmt.Sprintf("USE `%s`", dbName))
 	if err != nil {
 		return err
 	}
-	_, err = s.Execute("FLUSH TABLES")
+	_, err = dao.Execute("FLUSH TABLES")
 	return err
 }
 
-// CheckTableExist 检查表是否存在
-func (s *impl) CheckTableExist(tableName string) (bool, string, *cd.Error) {
-	sqlStr := fmt.Sprintf("SELECT TABLE_NAME, TABLE_TYPE FROM information_schema.TABLES WHERE TABLE_NAME ='%s' and TABLE_SCHEMA ='%s'", tableName, s.dbName)
-
-	err := s.Query(sqlStr)
-	if err != nil {
-		return false, "", err
-	}
-
-	defer s.Finish()
-
-	var tableNameVal, tableTypeVal sql.NullString
-	if s.Next() {
-		err = s.GetField(&tableNameVal, &tableTypeVal)
-		if err != nil {
-			return false, "", err
-		}
-
-		return true, tableTypeVal.String, nil
-	}
-
-	return false, "", nil
+func (d *mysqlDriver) tableExistSQL(dao *impl, tableName string) string {
+	return fmt.Sprintf("SELECT TABLE_NAME, TABLE_TYPE FROM information_schema.TABLES WHERE TABLE_NAME ='%s' and TABLE_SCHEMA ='%s'", tableName, dao.dbName)
 }
 
-// Duplicate 复制DAO实例
-func (s *impl) Duplicate() (Dao, *cd.Error) {
-	return Fetch(s.user, s.password, s.address, s.dbName)
+// init 注册MySQL驱动
+func init() {
+	RegisterDriver("mysql", &mysqlDriver{})
 }
@@ -4,8 +4,12 @@
 package dao
 
 import (
+	"errors"
 	"fmt"
 	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
 )
 
 type User struct {
@@ -174,3 +178,11 @@ func TestQuery(t *testing.T) {
 		}
 	}
 }
+
+func TestMysqlDriver_IsRetryable(t *testing.T) {
+	driver := &mysqlDriver{}
+	assert.True(t, driver.IsRetryable(&mysql.MySQLError{Number: 1213}))
+	assert.True(t, driver.IsRetryable(WrapError(&mysql.MySQLError{Number: 1213})))
+	assert.False(t, driver.IsRetryable(&mysql.MySQLError{Number: 1062}))
+	assert.False(t, driver.IsRetryable(errors.New("deadlock")))
+}
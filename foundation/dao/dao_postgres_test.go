@@ -4,8 +4,12 @@
 package dao
 
 import (
+	"errors"
 	"fmt"
 	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
 )
 
 type User struct {
@@ -161,3 +165,11 @@ func TestQuery(t *testing.T) {
 		}
 	}
 }
+
+func TestPostgresDriver_IsRetryable(t *testing.T) {
+	driver := &postgresDriver{}
+	assert.True(t, driver.IsRetryable(&pq.Error{Code: "40P01"}))
+	assert.True(t, driver.IsRetryable(WrapError(&pq.Error{Code: "40001"})))
+	assert.False(t, driver.IsRetryable(&pq.Error{Code: "23505"}))
+	assert.False(t, driver.IsRetryable(errors.New("deadlock")))
+}
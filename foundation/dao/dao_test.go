@@ -0,0 +1,97 @@
+package dao
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	cd "github.com/muidea/magicCommon/def"
+	"github.com/stretchr/testify/assert"
+)
+
+// plainDriver 未实现 schemaDriver 的第三方驱动
+type plainDriver struct{}
+
+func (d *plainDriver) Open(string) (*sql.DB, error) {
+	return sql.OpenDB(&fakeConnector{}), nil
+}
+
+func (d *plainDriver) Name() string {
+	return "plain"
+}
+
+func (d *plainDriver) DefaultConnectionString(user, password, address, dbName string) string {
+	return ""
+}
+
+func init() {
+	RegisterDriver("plain", &plainDriver{})
+}
+
+func TestDrivers_Registered(t *testing.T) {
+	drivers := AvailableDrivers()
+	assert.Contains(t, drivers, "postgres")
+	assert.Contains(t, drivers, "mysql")
+	assert.Contains(t, drivers, DefaultDriverName)
+
+	_, err := FetchWithDriver("sqlite", "root", "rootkit", "localhost", "testdb")
+	assert.EqualValues(t, cd.DatabaseError, err.Code)
+}
+
+func TestFetchWithDriver_Plain(t *testing.T) {
+	dao, err := FetchWithDriver("plain", "root", "rootkit", "localhost", "testdb")
+	assert.Nil(t, err)
+	defer dao.Release()
+
+	driverName, ok := DriverNameOf(dao)
+	assert.True(t, ok)
+	assert.Equal(t, "plain", driverName)
+
+	err = dao.CreateDatabase("testdb")
+	assert.EqualValues(t, cd.NotImplemented, err.Code)
+	_, _, err = dao.CheckTableExist("report")
+	assert.EqualValues(t, cd.NotImplemented, err.Code)
+}
+
+func TestImpl_DriverDispatch(t *testing.T) {
+	testCases := []struct {
+		driverName string
+		create     string
+		use        []string
+		tableExist string
+	}{
+		{"postgres", `CREATE DATABASE "testdb"`, nil, "FROM pg_tables"},
+		{"mysql", "CREATE DATABASE IF NOT EXISTS `testdb`", []string{"USE `testdb`", "FLUSH TABLES"}, "TABLE_SCHEMA ='testdb'"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.driverName, func(t *testing.T) {
+			connector := &fakeConnector{
+				query: func(query string, _ []driver.NamedValue) ([]string, [][]driver.Value, error) {
+					return []string{"name", "type"}, [][]driver.Value{{"report", BaseTable}}, nil
+				},
+			}
+			dao := &impl{BaseDao: newFakeDao(t, connector), driverName: tc.driverName}
+
+			assert.Nil(t, dao.CreateDatabase("testdb"))
+			assert.Equal(t, tc.create, connector.statements()[0])
+
+			executed := len(connector.statements())
+			assert.Nil(t, dao.UseDatabase("testdb"))
+			assert.Equal(t, "testdb", dao.dbName)
+			if tc.use == nil {
+				assert.Len(t, connector.statements(), executed)
+			} else {
+				assert.Equal(t, tc.use, connector.statements()[executed:])
+			}
+
+			exist, tableType, err := dao.CheckTableExist("report")
+			assert.Nil(t, err)
+			assert.True(t, exist)
+			assert.Equal(t, BaseTable, tableType)
+			statements := connector.statements()
+			assert.True(t, strings.Contains(statements[len(statements)-1], tc.tableExist))
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package dao
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dialect 由 Driver 可选实现，提供 Builder 生成 SQL 时的方言差异
+type Dialect interface {
+	// Placeholder 第 idx 个（从 1 开始）参数占位符
+	Placeholder(idx int) string
+	// QuoteIdentifier 转义表名或列名，schema.table 形式的名称逐段转义
+	QuoteIdentifier(name string) string
+	// UpsertClause 插入冲突时的更新子句，conflict 和 update 为已转义的列名
+	UpsertClause(conflict, update []string) string
+}
+
+// postgresDialect PostgreSQL 方言，$n 占位符、双引号转义
+type postgresDialect struct{}
+
+func (postgresDialect) Placeholder(idx int) string {
+	return fmt.Sprintf("$%d", idx)
+}
+
+func (postgresDialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, `"`)
+}
+
+// UpsertClause update 为空时忽略冲突的行
+func (postgresDialect) UpsertClause(conflict, update []string) string {
+	clause := "ON CONFLICT (" + strings.Join(conflict, ", ") + ") DO "
+	if len(update) == 0 {
+		return clause + "NOTHING"
+	}
+
+	sets := make([]string, len(update))
+	for idx, column := range update {
+		sets[idx] = column + " = EXCLUDED." + column
+	}
+	return clause + "UPDATE SET " + strings.Join(sets, ", ")
+}
+
+// mysqlDialect MySQL 方言，? 占位符、反引号转义
+type mysqlDialect struct{}
+
+func (mysqlDialect) Placeholder(int) string {
+	return "?"
+}
+
+func (mysqlDialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, "`")
+}
+
+// UpsertClause MySQL 按表上的唯一索引判断冲突，conflict 只在 update 为空时用于生成不修改数据的赋值
+func (mysqlDialect) UpsertClause(conflict, update []string) string {
+	if len(update) == 0 {
+		return "ON DUPLICATE KEY UPDATE " + conflict[0] + " = " + conflict[0]
+	}
+
+	sets := make([]string, len(update))
+	for idx, column := range update {
+		sets[idx] = column + " = VALUES(" + column + ")"
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+}
+
+// quoteIdentifier 按 . 分段转义，* 保持不变，名称中的引号加倍
+func quoteIdentifier(name, quote string) string {
+	parts := strings.Split(name, ".")
+	for idx, part := range parts {
+		if part == "*" {
+			continue
+		}
+		parts[idx] = quote + strings.ReplaceAll(part, quote, quote+quote) + quote
+	}
+	return strings.Join(parts, ".")
+}
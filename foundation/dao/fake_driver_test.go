@@ -0,0 +1,184 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConnector 测试用的 database/sql 驱动，按脚本返回结果并记录执行过的语句
+type fakeConnector struct {
+	mu      sync.Mutex
+	delay   time.Duration
+	query   func(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error)
+	exec    func(query string, args []driver.NamedValue) (int64, error)
+	history []string
+}
+
+// newFakeDao 基于 fakeConnector 创建 BaseDao，测试结束时释放
+func newFakeDao(t *testing.T, connector *fakeConnector, opts ...DaoOption) *BaseDao {
+	t.Helper()
+
+	baseDao := NewBaseDao(sql.OpenDB(connector), opts...)
+	t.Cleanup(func() { _ = baseDao.Release() })
+	return baseDao
+}
+
+func (c *fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{connector: c}, nil
+}
+
+func (c *fakeConnector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+func (c *fakeConnector) record(statement string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.history = append(c.history, statement)
+}
+
+func (c *fakeConnector) statements() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.history...)
+}
+
+func (c *fakeConnector) wait(ctx context.Context) error {
+	if c.delay <= 0 {
+		return ctx.Err()
+	}
+
+	select {
+	case <-time.After(c.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fakeSQLError 带 SQLSTATE 的驱动错误
+type fakeSQLError struct {
+	state string
+}
+
+func (e *fakeSQLError) Error() string {
+	return "fake sql error " + e.state
+}
+
+func (e *fakeSQLError) SQLState() string {
+	return e.state
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("fake driver only supports connector")
+}
+
+type fakeConn struct {
+	connector *fakeConnector
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fake driver does not support prepare")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	statement := "BEGIN"
+	if level := sql.IsolationLevel(opts.Isolation); level != sql.LevelDefault {
+		statement += " " + level.String()
+	}
+	if opts.ReadOnly {
+		statement += " READ ONLY"
+	}
+	c.connector.record(statement)
+	return &fakeTx{connector: c.connector}, nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.connector.record(query)
+	if err := c.connector.wait(ctx); err != nil {
+		return nil, err
+	}
+	if c.connector.query == nil {
+		return &fakeRows{}, nil
+	}
+
+	columns, values, err := c.connector.query(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{columns: columns, values: values}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.connector.record(query)
+	if err := c.connector.wait(ctx); err != nil {
+		return nil, err
+	}
+	if c.connector.exec == nil {
+		return driver.RowsAffected(0), nil
+	}
+
+	affected, err := c.connector.exec(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(affected), nil
+}
+
+type fakeTx struct {
+	connector *fakeConnector
+}
+
+func (t *fakeTx) Commit() error {
+	t.connector.record("COMMIT")
+	return nil
+}
+
+func (t *fakeTx) Rollback() error {
+	t.connector.record("ROLLBACK")
+	return nil
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+	offset  int
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.offset >= len(r.values) {
+		return io.EOF
+	}
+
+	copy(dest, r.values[r.offset])
+	r.offset++
+	return nil
+}
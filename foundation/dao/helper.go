@@ -0,0 +1,39 @@
+package dao
+
+import "regexp"
+
+var tableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+
+// driverNamer 由具体 Dao 实现，用于区分 SQL 方言
+type driverNamer interface {
+	DriverName() string
+}
+
+// DriverNameOf 返回 Dao 使用的驱动名称，Dao 未提供驱动信息时返回 false
+func DriverNameOf(dao Dao) (string, bool) {
+	namer, ok := dao.(driverNamer)
+	if !ok {
+		return "", false
+	}
+
+	return namer.DriverName(), true
+}
+
+// Placeholder 返回指定驱动第 idx 个（从 1 开始）参数占位符，驱动未注册或未实现 Dialect 时使用 ?
+func Placeholder(driverName string, idx int) string {
+	driver, ok := GetDriver(driverName)
+	if !ok {
+		return "?"
+	}
+	dialect, ok := driver.(Dialect)
+	if !ok {
+		return "?"
+	}
+
+	return dialect.Placeholder(idx)
+}
+
+// IsValidTableName 检查表名是否只包含字母、数字和下划线，用于拼接 SQL 前的校验
+func IsValidTableName(tableName string) bool {
+	return tableNamePattern.MatchString(tableName)
+}
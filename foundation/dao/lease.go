@@ -0,0 +1,150 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	cd "github.com/muidea/magicCommon/def"
+)
+
+// DefaultLeaseTable 默认租约表名
+const DefaultLeaseTable = "magic_lease"
+
+// LeaseLocker 基于数据库表的租约实现，满足 task.Locker 接口。
+// 同一 key 在租约未过期前只能被一个 holder 持有，持有者再次获取时会续期。
+// Dao 本身不是并发安全的，LeaseLocker 内部串行化访问，建议使用独立的 Dao（例如 Duplicate() 的结果）。
+type LeaseLocker struct {
+	mu         sync.Mutex
+	dao        Dao
+	tableName  string
+	driverName string
+}
+
+// NewLeaseLocker 创建基于数据库表的租约，tableName 为空时使用 DefaultLeaseTable，表不存在时自动创建
+func NewLeaseLocker(dao Dao, tableName string) (*LeaseLocker, *cd.Error) {
+	if dao == nil {
+		return nil, ErrInvalidParameter
+	}
+	if tableName == "" {
+		tableName = DefaultLeaseTable
+	}
+	if !IsValidTableName(tableName) {
+		return nil, cd.NewError(cd.InvalidParameter, fmt.Sprintf("illegal lease table name: %s", tableName))
+	}
+
+	driverName, ok := DriverNameOf(dao)
+	if !ok {
+		return nil, cd.NewError(cd.NotImplemented, "dao does not report driver name")
+	}
+
+	locker := &LeaseLocker{
+		dao:        dao,
+		tableName:  tableName,
+		driverName: driverName,
+	}
+	if err := locker.createTable(); err != nil {
+		return nil, err
+	}
+
+	return locker, nil
+}
+
+func (s *LeaseLocker) createTable() *cd.Error {
+	sqlStr := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (lease_key VARCHAR(128) NOT NULL PRIMARY KEY, holder VARCHAR(128) NOT NULL, expire_at BIGINT NOT NULL)", s.tableName)
+	_, err := s.dao.Execute(sqlStr)
+	return err
+}
+
+func (s *LeaseLocker) bindVar(idx int) string {
+	return Placeholder(s.driverName, idx)
+}
+
+// TryAcquire 尝试以 holder 身份获取 key 的租约，获取成功或续期成功时返回 true
+func (s *LeaseLocker) TryAcquire(ctx context.Context, key, holder string, ttl time.Duration) (bool, error) {
+	if key == "" || holder == "" || ttl <= 0 {
+		return false, ErrInvalidParameter
+	}
+	if ctx != nil && ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	expireAt := now.Add(ttl).UnixMilli()
+	updateSQL := fmt.Sprintf("UPDATE %s SET holder = %s, expire_at = %s WHERE lease_key = %s AND (holder = %s OR expire_at < %s)",
+		s.tableName, s.bindVar(1), s.bindVar(2), s.bindVar(3), s.bindVar(4), s.bindVar(5))
+	affected, err := s.dao.ExecuteContext(ctx, updateSQL, holder, expireAt, key, holder, now.UnixMilli())
+	if err != nil {
+		return false, err
+	}
+	if affected > 0 {
+		return true, nil
+	}
+
+	// MySQL 默认按实际修改的行数返回，续期写入相同的 expire_at 时也返回 0，需要查询当前持有者
+	curHolder, exist, err := s.holderOf(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if exist {
+		return curHolder == holder, nil
+	}
+
+	insertSQL := fmt.Sprintf("INSERT INTO %s (lease_key, holder, expire_at) VALUES (%s, %s, %s)",
+		s.tableName, s.bindVar(1), s.bindVar(2), s.bindVar(3))
+	_, err = s.dao.ExecuteContext(ctx, insertSQL, key, holder, expireAt)
+	if err == nil {
+		return true, nil
+	}
+
+	// 插入失败时，如果记录已存在说明租约同时被其他 holder 获取
+	curHolder, exist, holderErr := s.holderOf(ctx, key)
+	if holderErr != nil {
+		return false, holderErr
+	}
+	if exist {
+		return curHolder == holder, nil
+	}
+
+	return false, err
+}
+
+// Release 释放 holder 持有的 key 租约，未持有时不做任何处理
+func (s *LeaseLocker) Release(ctx context.Context, key, holder string) error {
+	if key == "" || holder == "" {
+		return ErrInvalidParameter
+	}
+	if ctx != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleteSQL := fmt.Sprintf("DELETE FROM %s WHERE lease_key = %s AND holder = %s", s.tableName, s.bindVar(1), s.bindVar(2))
+	_, err := s.dao.ExecuteContext(ctx, deleteSQL, key, holder)
+	return cd.ToStdError(err)
+}
+
+// holderOf 查询 key 当前的持有者，记录不存在时返回 false
+func (s *LeaseLocker) holderOf(ctx context.Context, key string) (string, bool, *cd.Error) {
+	querySQL := fmt.Sprintf("SELECT holder FROM %s WHERE lease_key = %s", s.tableName, s.bindVar(1))
+	if err := s.dao.QueryContext(ctx, querySQL, key); err != nil {
+		return "", false, err
+	}
+	defer func() { _ = s.dao.Finish() }()
+
+	if !s.dao.Next() {
+		return "", false, nil
+	}
+
+	var holder string
+	if err := s.dao.GetField(&holder); err != nil {
+		return "", false, err
+	}
+	return holder, true, nil
+}
@@ -0,0 +1,94 @@
+package dao
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewLeaseLocker_InvalidParameter(t *testing.T) {
+	_, err := NewLeaseLocker(nil, "")
+	assert.NotNil(t, err)
+
+	dao := &impl{BaseDao: &BaseDao{}, driverName: "postgres"}
+	_, err = NewLeaseLocker(dao, "lease; DROP TABLE users")
+	assert.NotNil(t, err)
+}
+
+func TestLeaseLocker_BindVar(t *testing.T) {
+	postgres := &LeaseLocker{driverName: "postgres"}
+	assert.Equal(t, "$2", postgres.bindVar(2))
+
+	mysql := &LeaseLocker{driverName: "mysql"}
+	assert.Equal(t, "?", mysql.bindVar(2))
+}
+
+func TestLeaseLocker_RenewWithoutAffectedRows(t *testing.T) {
+	holder := "replica-a"
+	connector := &fakeConnector{
+		// MySQL 默认 clientFoundRows=false，写入相同的值时不计入影响行数
+		exec: func(string, []driver.NamedValue) (int64, error) {
+			return 0, nil
+		},
+		query: func(string, []driver.NamedValue) ([]string, [][]driver.Value, error) {
+			if holder == "" {
+				return []string{"holder"}, nil, nil
+			}
+			return []string{"holder"}, [][]driver.Value{{holder}}, nil
+		},
+	}
+	dao := &impl{BaseDao: newFakeDao(t, connector), driverName: "mysql"}
+	locker, err := NewLeaseLocker(dao, "")
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	ctx := context.Background()
+	ok, acquireErr := locker.TryAcquire(ctx, "cleanup", "replica-a", time.Second)
+	assert.Nil(t, acquireErr)
+	assert.True(t, ok)
+	ok, acquireErr = locker.TryAcquire(ctx, "cleanup", "replica-b", time.Second)
+	assert.Nil(t, acquireErr)
+	assert.False(t, ok)
+	for _, statement := range connector.statements() {
+		assert.False(t, strings.HasPrefix(statement, "INSERT"), statement)
+	}
+
+	// 记录不存在时插入
+	holder = ""
+	ok, acquireErr = locker.TryAcquire(ctx, "cleanup", "replica-b", time.Second)
+	assert.Nil(t, acquireErr)
+	assert.True(t, ok)
+	statements := connector.statements()
+	assert.True(t, strings.HasPrefix(statements[len(statements)-1], "INSERT"))
+}
+
+func TestLeaseLocker_AcquireAndRelease(t *testing.T) {
+	dao := fetchOrSkip(t)
+	defer func() { _ = dao.Release() }()
+
+	locker, err := NewLeaseLocker(dao, "magic_lease_test")
+	if err != nil {
+		t.Fatalf("create lease locker failed: %v", err)
+	}
+	defer func() { _, _ = dao.Execute("DROP TABLE magic_lease_test") }()
+
+	ctx := context.Background()
+	ok, acquireErr := locker.TryAcquire(ctx, "cleanup", "replica-a", time.Second)
+	assert.Nil(t, acquireErr)
+	assert.True(t, ok)
+
+	ok, acquireErr = locker.TryAcquire(ctx, "cleanup", "replica-b", time.Second)
+	assert.Nil(t, acquireErr)
+	assert.False(t, ok)
+
+	assert.Nil(t, locker.Release(ctx, "cleanup", "replica-a"))
+
+	ok, acquireErr = locker.TryAcquire(ctx, "cleanup", "replica-b", time.Second)
+	assert.Nil(t, acquireErr)
+	assert.True(t, ok)
+}
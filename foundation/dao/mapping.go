@@ -0,0 +1,250 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"iter"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	cd "github.com/muidea/magicCommon/def"
+)
+
+// QueryOne 执行查询并将首行映射为 T，没有数据时返回 ErrNoRows
+func QueryOne[T any](ctx context.Context, dao Dao, sqlStr string, args ...any) (T, *cd.Error) {
+	var ret T
+	for val, err := range QueryIter[T](ctx, dao, sqlStr, args...) {
+		if err != nil {
+			return ret, err
+		}
+		return val, nil
+	}
+
+	return ret, ErrNoRows
+}
+
+// QueryAll 执行查询并将全部数据映射为 []T，没有数据时返回空切片
+func QueryAll[T any](ctx context.Context, dao Dao, sqlStr string, args ...any) ([]T, *cd.Error) {
+	ret := []T{}
+	for val, err := range QueryIter[T](ctx, dao, sqlStr, args...) {
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, val)
+	}
+
+	return ret, nil
+}
+
+// QueryIter 执行查询并逐行映射为 T，出错时产出一次错误后结束；
+// 循环结束或提前退出时自动 Finish，迭代期间不能使用同一个 Dao 执行其它语句。
+//
+// T 为结构体（或结构体指针）时按 db 标签映射列，没有标签的导出字段使用蛇形命名，db:"-" 表示忽略，
+// 列名比较不区分大小写；匿名嵌入的结构体字段展开映射，外层字段优先；结果中没有对应字段的列被忽略。
+// 可为 NULL 的列使用指针、sql.Null* 或实现 sql.Scanner 的类型。
+// T 为基础类型、time.Time 或实现 sql.Scanner 的类型时，结果只能包含一列
+func QueryIter[T any](ctx context.Context, dao Dao, sqlStr string, args ...any) iter.Seq2[T, *cd.Error] {
+	return func(yield func(T, *cd.Error) bool) {
+		var zero T
+		if dao == nil {
+			yield(zero, ErrInvalidParameter)
+			return
+		}
+
+		mapper := rowMapperOf(reflect.TypeFor[T]())
+		if err := dao.QueryContext(ctx, sqlStr, args...); err != nil {
+			yield(zero, err)
+			return
+		}
+		defer func() { _ = dao.Finish() }()
+
+		columns, err := dao.Columns()
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		plan, err := mapper.plan(columns)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+
+		for dao.Next() {
+			var val T
+			if err := dao.GetField(plan.targets(reflect.ValueOf(&val).Elem())...); err != nil {
+				yield(zero, err)
+				return
+			}
+			if !yield(val, nil) {
+				return
+			}
+		}
+
+		if err := dao.Finish(); err != nil {
+			yield(zero, err)
+		}
+	}
+}
+
+var (
+	scannerType = reflect.TypeFor[sql.Scanner]()
+	timeType    = reflect.TypeFor[time.Time]()
+
+	// rowMappers 按类型缓存映射信息
+	rowMappers sync.Map
+)
+
+// rowMapper 类型 T 的映射信息
+type rowMapper struct {
+	typ reflect.Type
+	// pointer T 为结构体指针
+	pointer bool
+	// scalar T 直接接收单列
+	scalar bool
+	// fields 小写列名到字段索引路径
+	fields map[string][]int
+}
+
+func rowMapperOf(typ reflect.Type) *rowMapper {
+	if val, ok := rowMappers.Load(typ); ok {
+		return val.(*rowMapper)
+	}
+
+	mapper := &rowMapper{typ: typ}
+	structType := typ
+	if typ.Kind() == reflect.Pointer && isStructType(typ.Elem()) {
+		mapper.pointer = true
+		structType = typ.Elem()
+	}
+	if isStructType(structType) {
+		mapper.fields = map[string][]int{}
+		depths := map[string]int{}
+		collectFields(structType, nil, mapper.fields, depths)
+	} else {
+		mapper.scalar = true
+	}
+
+	val, _ := rowMappers.LoadOrStore(typ, mapper)
+	return val.(*rowMapper)
+}
+
+// isStructType 是否按字段映射，time.Time 和实现 sql.Scanner 的结构体作为整体接收单列
+func isStructType(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Struct && typ != timeType && !reflect.PointerTo(typ).Implements(scannerType)
+}
+
+// collectFields 收集字段，同名列保留嵌套层级最浅的字段
+func collectFields(typ reflect.Type, prefix []int, fields map[string][]int, depths map[string]int) {
+	for idx := 0; idx < typ.NumField(); idx++ {
+		field := typ.Field(idx)
+		tag, hasTag := field.Tag.Lookup("db")
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+
+		index := append(append([]int(nil), prefix...), idx)
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && !hasTag && isStructType(fieldType) {
+			// 未导出类型的嵌入指针无法分配，与 encoding/json 一致忽略
+			if !field.IsExported() && field.Type.Kind() == reflect.Pointer {
+				continue
+			}
+			collectFields(fieldType, index, fields, depths)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = snakeCase(field.Name)
+		}
+		name = strings.ToLower(name)
+		if depth, ok := depths[name]; ok && depth <= len(index) {
+			continue
+		}
+		fields[name] = index
+		depths[name] = len(index)
+	}
+}
+
+// snakeCase 将 UserID 转换为 user_id
+func snakeCase(name string) string {
+	runes := []rune(name)
+	builder := strings.Builder{}
+	for idx, r := range runes {
+		if unicode.IsUpper(r) && idx > 0 {
+			prevLower := !unicode.IsUpper(runes[idx-1])
+			nextLower := idx+1 < len(runes) && unicode.IsLower(runes[idx+1])
+			if prevLower || nextLower {
+				builder.WriteByte('_')
+			}
+		}
+		builder.WriteRune(unicode.ToLower(r))
+	}
+	return builder.String()
+}
+
+// rowPlan 一次查询中每列对应的字段
+type rowPlan struct {
+	mapper  *rowMapper
+	indexes [][]int
+}
+
+func (s *rowMapper) plan(columns []string) (*rowPlan, *cd.Error) {
+	if s.scalar {
+		if len(columns) != 1 {
+			return nil, cd.NewError(cd.InvalidParameter, fmt.Sprintf("%s requires exactly one column, got %d", s.typ, len(columns)))
+		}
+		return &rowPlan{mapper: s}, nil
+	}
+
+	indexes := make([][]int, len(columns))
+	for idx, column := range columns {
+		indexes[idx] = s.fields[strings.ToLower(column)]
+	}
+	return &rowPlan{mapper: s, indexes: indexes}, nil
+}
+
+// targets 返回 Scan 的目标，val 为 T 的可寻址值
+func (s *rowPlan) targets(val reflect.Value) []any {
+	if s.mapper.scalar {
+		return []any{val.Addr().Interface()}
+	}
+
+	if s.mapper.pointer {
+		val.Set(reflect.New(val.Type().Elem()))
+		val = val.Elem()
+	}
+	ret := make([]any, len(s.indexes))
+	for idx, index := range s.indexes {
+		if index == nil {
+			ret[idx] = new(any)
+			continue
+		}
+		ret[idx] = fieldByIndex(val, index).Addr().Interface()
+	}
+	return ret
+}
+
+// fieldByIndex 按索引路径取字段，途经的嵌入结构体指针为 nil 时分配
+func fieldByIndex(val reflect.Value, index []int) reflect.Value {
+	for idx, fieldIdx := range index {
+		if idx > 0 && val.Kind() == reflect.Pointer {
+			if val.IsNil() {
+				val.Set(reflect.New(val.Type().Elem()))
+			}
+			val = val.Elem()
+		}
+		val = val.Field(fieldIdx)
+	}
+	return val
+}
@@ -0,0 +1,186 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	cd "github.com/muidea/magicCommon/def"
+	"github.com/stretchr/testify/assert"
+)
+
+type mappingAudit struct {
+	CreatedAt time.Time  `db:"created_at"`
+	UpdatedAt *time.Time `db:"updated_at"`
+}
+
+type mappingOwner struct {
+	OwnerID int64 `db:"owner_id"`
+	Name    string
+}
+
+// mappingStatus 自定义 Scanner
+type mappingStatus struct {
+	Code string
+}
+
+func (s *mappingStatus) Scan(src any) error {
+	switch val := src.(type) {
+	case string:
+		s.Code = strings.ToUpper(val)
+	case []byte:
+		s.Code = strings.ToUpper(string(val))
+	default:
+		return fmt.Errorf("illegal status %T", src)
+	}
+	return nil
+}
+
+type mappingReport struct {
+	mappingAudit
+	*mappingOwner `db:"-"`
+	Owner         *mappingOwnerRef
+	ID            int64  `db:"id"`
+	Name          string `db:"title"`
+	Remark        sql.NullString
+	Score         *float64
+	Status        mappingStatus
+	Ignored       string `db:"-"`
+	hidden        string
+}
+
+type mappingOwnerRef struct {
+	Name string `db:"owner_name"`
+}
+
+// MappingTenant 导出类型，以指针嵌入时按需分配
+type MappingTenant struct {
+	Tenant string `db:"tenant"`
+}
+
+type mappingEmbedded struct {
+	*mappingOwnerRef
+	*MappingTenant
+	mappingAudit
+	ID   int64
+	Name string `db:"owner_name"`
+}
+
+func newMappingDao(t *testing.T, columns []string, values [][]driver.Value) *impl {
+	connector := &fakeConnector{
+		query: func(string, []driver.NamedValue) ([]string, [][]driver.Value, error) {
+			return columns, values, nil
+		},
+	}
+	return &impl{BaseDao: newFakeDao(t, connector), driverName: "postgres"}
+}
+
+func TestQueryAll_StructMapping(t *testing.T) {
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	columns := []string{"id", "TITLE", "remark", "score", "status", "created_at", "updated_at", "unknown"}
+	dao := newMappingDao(t, columns, [][]driver.Value{
+		{int64(1), "daily", "ok", 9.5, "done", createdAt, createdAt, "x"},
+		{int64(2), "weekly", nil, nil, []byte("open"), createdAt, nil, nil},
+	})
+
+	reports, err := QueryAll[mappingReport](context.Background(), dao, "SELECT * FROM report")
+	assert.Nil(t, err)
+	assert.Len(t, reports, 2)
+
+	assert.Equal(t, int64(1), reports[0].ID)
+	assert.Equal(t, "daily", reports[0].Name)
+	assert.Equal(t, sql.NullString{String: "ok", Valid: true}, reports[0].Remark)
+	assert.Equal(t, 9.5, *reports[0].Score)
+	assert.Equal(t, "DONE", reports[0].Status.Code)
+	assert.Equal(t, createdAt, reports[0].CreatedAt)
+	assert.Equal(t, createdAt, *reports[0].UpdatedAt)
+
+	assert.False(t, reports[1].Remark.Valid)
+	assert.Nil(t, reports[1].Score)
+	assert.Nil(t, reports[1].UpdatedAt)
+	assert.Equal(t, "OPEN", reports[1].Status.Code)
+	assert.Nil(t, reports[1].mappingOwner)
+}
+
+func TestQueryAll_EmbeddedPointer(t *testing.T) {
+	dao := newMappingDao(t, []string{"id", "owner_name", "created_at", "tenant"}, [][]driver.Value{
+		{int64(1), "alice", time.Unix(0, 0).UTC(), "t1"},
+	})
+
+	values, err := QueryAll[*mappingEmbedded](context.Background(), dao, "SELECT * FROM report")
+	assert.Nil(t, err)
+	assert.Len(t, values, 1)
+	// 外层字段优先，未导出类型的嵌入指针被忽略
+	assert.Equal(t, "alice", values[0].Name)
+	assert.Nil(t, values[0].mappingOwnerRef)
+	assert.Equal(t, time.Unix(0, 0).UTC(), values[0].CreatedAt)
+	assert.Equal(t, "t1", values[0].Tenant)
+}
+
+func TestQueryOne(t *testing.T) {
+	dao := newMappingDao(t, []string{"owner_id", "name"}, [][]driver.Value{
+		{int64(7), "alice"},
+		{int64(8), "bob"},
+	})
+
+	owner, err := QueryOne[mappingOwner](context.Background(), dao, "SELECT owner_id, name FROM owner")
+	assert.Nil(t, err)
+	assert.Equal(t, mappingOwner{OwnerID: 7, Name: "alice"}, owner)
+	// 提前退出后结果集已关闭
+	assert.Nil(t, dao.rowsHandle)
+
+	empty := newMappingDao(t, []string{"owner_id"}, nil)
+	_, err = QueryOne[mappingOwner](context.Background(), empty, "SELECT owner_id FROM owner")
+	assert.Equal(t, ErrNoRows, err)
+}
+
+func TestQueryIter_Scalar(t *testing.T) {
+	dao := newMappingDao(t, []string{"name"}, [][]driver.Value{{"alice"}, {nil}, {"carol"}})
+
+	names := []string{}
+	for name, err := range QueryIter[*string](context.Background(), dao, "SELECT name FROM owner") {
+		assert.Nil(t, err)
+		if name == nil {
+			names = append(names, "<nil>")
+			continue
+		}
+		names = append(names, *name)
+	}
+	assert.Equal(t, []string{"alice", "<nil>", "carol"}, names)
+
+	multi := newMappingDao(t, []string{"id", "name"}, [][]driver.Value{{int64(1), "alice"}})
+	_, err := QueryAll[int64](context.Background(), multi, "SELECT id, name FROM owner")
+	assert.NotNil(t, err)
+	assert.EqualValues(t, cd.InvalidParameter, err.Code)
+}
+
+func TestQueryIter_ScanError(t *testing.T) {
+	dao := newMappingDao(t, []string{"owner_id"}, [][]driver.Value{{"not a number"}})
+
+	_, err := QueryAll[mappingOwner](context.Background(), dao, "SELECT owner_id FROM owner")
+	assert.NotNil(t, err)
+	assert.EqualValues(t, cd.DatabaseError, err.Code)
+	assert.Nil(t, dao.rowsHandle)
+}
+
+func TestRowMapperCache(t *testing.T) {
+	mapper := rowMapperOf(reflect.TypeFor[mappingReport]())
+	assert.Same(t, mapper, rowMapperOf(reflect.TypeFor[mappingReport]()))
+	assert.Equal(t, []int{5}, mapper.fields["remark"])
+	assert.Equal(t, []int{0, 0}, mapper.fields["created_at"])
+	assert.NotContains(t, mapper.fields, "ignored")
+	assert.NotContains(t, mapper.fields, "hidden")
+	assert.NotContains(t, mapper.fields, "owner_id")
+}
+
+func TestSnakeCase(t *testing.T) {
+	assert.Equal(t, "user_id", snakeCase("UserID"))
+	assert.Equal(t, "http_server", snakeCase("HTTPServer"))
+	assert.Equal(t, "name", snakeCase("Name"))
+	assert.Equal(t, "created_at2", snakeCase("CreatedAt2"))
+}
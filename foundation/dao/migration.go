@@ -0,0 +1,612 @@
+package dao
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	cd "github.com/muidea/magicCommon/def"
+)
+
+const (
+	// DefaultMigrationTable 默认的迁移记录表名
+	DefaultMigrationTable = "magic_schema_migrations"
+	// DefaultMigrationLockTimeout 等待迁移锁的默认时间
+	DefaultMigrationLockTimeout = time.Minute
+
+	migrationLockInterval = 100 * time.Millisecond
+)
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// MigrationFunc Go 函数实现的迁移，在事务中执行
+type MigrationFunc func(ctx context.Context, tx Dao) error
+
+// Migration 一个版本的迁移，UpSQL 与 Up 二选一，DownSQL 与 Down 二选一；
+// 没有 DownSQL 和 Down 的迁移不能回滚
+type Migration struct {
+	Version int64
+	Name    string
+	UpSQL   string
+	DownSQL string
+	Up      MigrationFunc
+	Down    MigrationFunc
+}
+
+// Checksum SQL 迁移为 UpSQL 的 SHA-256，Go 函数迁移为空，不做校验
+func (s Migration) Checksum() string {
+	if s.Up != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(s.UpSQL))
+	return hex.EncodeToString(sum[:])
+}
+
+// MigrationStatus 迁移状态
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Checksum  string
+	Applied   bool
+	AppliedAt time.Time
+	// Modified 已执行的迁移被修改，校验和与执行时的记录不一致
+	Modified bool
+	// Missing 已执行但当前迁移列表中不存在
+	Missing bool
+}
+
+// migrationRecord 迁移记录表中的一行
+type migrationRecord struct {
+	Version   int64  `db:"version"`
+	Name      string `db:"name"`
+	Checksum  string `db:"checksum"`
+	AppliedAt int64  `db:"applied_at"`
+}
+
+type MigratorOption func(*migratorOptions)
+
+type migratorOptions struct {
+	tableName   string
+	dryRun      bool
+	lockTimeout time.Duration
+}
+
+// WithMigrationTable 指定迁移记录表，默认 DefaultMigrationTable
+func WithMigrationTable(tableName string) MigratorOption {
+	return func(o *migratorOptions) {
+		if tableName != "" {
+			o.tableName = tableName
+		}
+	}
+}
+
+// WithDryRun 只计算并记录将要执行的迁移，不修改数据库
+func WithDryRun() MigratorOption {
+	return func(o *migratorOptions) {
+		o.dryRun = true
+	}
+}
+
+// WithMigrationLockTimeout 设置等待迁移锁的时间，默认 DefaultMigrationLockTimeout
+func WithMigrationLockTimeout(timeout time.Duration) MigratorOption {
+	return func(o *migratorOptions) {
+		if timeout > 0 {
+			o.lockTimeout = timeout
+		}
+	}
+}
+
+// Migrator 按版本执行迁移。执行期间固定使用一个连接并持有会话级的咨询锁
+// （PostgreSQL pg_advisory_lock，MySQL GET_LOCK），多个实例同时启动时只有一个执行迁移；
+// 每个迁移及其记录在独立的事务中提交。Migrator 与 Dao 一样不是并发安全的
+type Migrator struct {
+	dao        Dao
+	base       *BaseDao
+	driverName string
+	migrations []Migration
+	options    migratorOptions
+}
+
+// NewMigrator 创建 Migrator，migrations 按版本排序，版本必须大于 0 且不重复
+func NewMigrator(dao Dao, migrations []Migration, opts ...MigratorOption) (*Migrator, *cd.Error) {
+	if dao == nil {
+		return nil, ErrInvalidParameter
+	}
+	provider, ok := dao.(interface{ baseDao() *BaseDao })
+	if !ok {
+		return nil, cd.NewError(cd.NotImplemented, "dao does not support migration")
+	}
+	driverName, ok := DriverNameOf(dao)
+	if !ok || (driverName != "postgres" && driverName != "mysql") {
+		return nil, cd.NewError(cd.NotImplemented, fmt.Sprintf("migration not supported by driver %s", driverName))
+	}
+
+	options := migratorOptions{
+		tableName:   DefaultMigrationTable,
+		lockTimeout: DefaultMigrationLockTimeout,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if !IsValidTableName(options.tableName) {
+		return nil, cd.NewError(cd.InvalidParameter, fmt.Sprintf("illegal migration table name: %s", options.tableName))
+	}
+
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+	for idx, migration := range sorted {
+		if err := validateMigration(migration); err != nil {
+			return nil, err
+		}
+		if idx > 0 && sorted[idx-1].Version == migration.Version {
+			return nil, cd.NewError(cd.Duplicated, fmt.Sprintf("duplicated migration version %d", migration.Version))
+		}
+	}
+
+	return &Migrator{
+		dao:        dao,
+		base:       provider.baseDao(),
+		driverName: driverName,
+		migrations: sorted,
+		options:    options,
+	}, nil
+}
+
+func validateMigration(migration Migration) *cd.Error {
+	if migration.Version <= 0 {
+		return cd.NewError(cd.InvalidParameter, fmt.Sprintf("illegal migration version %d", migration.Version))
+	}
+	if (migration.UpSQL == "") == (migration.Up == nil) {
+		return cd.NewError(cd.InvalidParameter, fmt.Sprintf("migration %d requires exactly one of UpSQL and Up", migration.Version))
+	}
+	if migration.DownSQL != "" && migration.Down != nil {
+		return cd.NewError(cd.InvalidParameter, fmt.Sprintf("migration %d has both DownSQL and Down", migration.Version))
+	}
+	return nil
+}
+
+// Status 返回全部迁移的状态，按版本排序，包括已执行但不在迁移列表中的版本
+func (s *Migrator) Status(ctx context.Context) ([]MigrationStatus, *cd.Error) {
+	records, err := s.loadRecords(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.merge(records), nil
+}
+
+// Apply 按版本顺序执行全部未执行的迁移，返回本次执行（dry-run 时为将要执行）的迁移；
+// 已执行的迁移被修改时不执行任何迁移并返回 cd.DataCorrupted。
+// 某个迁移失败时，之前的迁移保持已执行，返回该迁移的错误
+func (s *Migrator) Apply(ctx context.Context) ([]MigrationStatus, *cd.Error) {
+	ret := []MigrationStatus{}
+	err := s.withLock(ctx, func() *cd.Error {
+		records, err := s.loadRecords(ctx, !s.options.dryRun)
+		if err != nil {
+			return err
+		}
+		statuses := s.merge(records)
+		if err := checkModified(statuses); err != nil {
+			return err
+		}
+
+		for _, status := range statuses {
+			if status.Applied || status.Missing {
+				continue
+			}
+
+			migration := s.migration(status.Version)
+			if s.options.dryRun {
+				slog.Info("dry-run apply migration", "version", migration.Version, "name", migration.Name, "sql", migration.UpSQL)
+				ret = append(ret, status)
+				continue
+			}
+
+			appliedAt := time.Now()
+			if err := s.run(ctx, migration.UpSQL, migration.Up, func(tx Dao) *cd.Error {
+				insertSQL := fmt.Sprintf("INSERT INTO %s (version, name, checksum, applied_at) VALUES (%s, %s, %s, %s)",
+					s.options.tableName, s.bindVar(1), s.bindVar(2), s.bindVar(3), s.bindVar(4))
+				_, err := tx.ExecuteContext(ctx, insertSQL, migration.Version, migration.Name, status.Checksum, appliedAt.UnixMilli())
+				return err
+			}); err != nil {
+				slog.Error("apply migration failed", "version", migration.Version, "name", migration.Name, "error", err)
+				return err
+			}
+
+			slog.Info("apply migration", "version", migration.Version, "name", migration.Name)
+			status.Applied = true
+			status.AppliedAt = time.UnixMilli(appliedAt.UnixMilli())
+			ret = append(ret, status)
+		}
+		return nil
+	})
+	if err != nil {
+		return ret, err
+	}
+
+	return ret, nil
+}
+
+// Rollback 按版本倒序回滚版本大于 version 的已执行迁移，返回本次回滚（dry-run 时为将要回滚）的迁移；
+// 执行前检查这些迁移都存在且可以回滚
+func (s *Migrator) Rollback(ctx context.Context, version int64) ([]MigrationStatus, *cd.Error) {
+	ret := []MigrationStatus{}
+	err := s.withLock(ctx, func() *cd.Error {
+		records, err := s.loadRecords(ctx, false)
+		if err != nil {
+			return err
+		}
+		statuses := s.merge(records)
+		if err := checkModified(statuses); err != nil {
+			return err
+		}
+
+		targets := []MigrationStatus{}
+		for idx := len(statuses) - 1; idx >= 0; idx-- {
+			status := statuses[idx]
+			if !status.Applied || status.Version <= version {
+				continue
+			}
+			if status.Missing {
+				return cd.NewError(cd.NotFound, fmt.Sprintf("migration %d not found", status.Version))
+			}
+			migration := s.migration(status.Version)
+			if migration.DownSQL == "" && migration.Down == nil {
+				return cd.NewError(cd.InvalidOperation, fmt.Sprintf("migration %d can not be rolled back", status.Version))
+			}
+			targets = append(targets, status)
+		}
+
+		for _, status := range targets {
+			migration := s.migration(status.Version)
+			if s.options.dryRun {
+				slog.Info("dry-run rollback migration", "version", migration.Version, "name", migration.Name, "sql", migration.DownSQL)
+				ret = append(ret, status)
+				continue
+			}
+
+			if err := s.run(ctx, migration.DownSQL, migration.Down, func(tx Dao) *cd.Error {
+				deleteSQL := fmt.Sprintf("DELETE FROM %s WHERE version = %s", s.options.tableName, s.bindVar(1))
+				_, err := tx.ExecuteContext(ctx, deleteSQL, migration.Version)
+				return err
+			}); err != nil {
+				slog.Error("rollback migration failed", "version", migration.Version, "name", migration.Name, "error", err)
+				return err
+			}
+
+			slog.Info("rollback migration", "version", migration.Version, "name", migration.Name)
+			status.Applied = false
+			status.AppliedAt = time.Time{}
+			ret = append(ret, status)
+		}
+		return nil
+	})
+	if err != nil {
+		return ret, err
+	}
+
+	return ret, nil
+}
+
+// run 在事务中执行迁移并更新记录，迁移通常包含 DDL，冲突时不重试。
+// MySQL 的 DDL 会隐式提交事务，失败时已执行的 DDL 不会回滚
+func (s *Migrator) run(ctx context.Context, sqlText string, fn MigrationFunc, record func(tx Dao) *cd.Error) *cd.Error {
+	return s.dao.WithTransaction(ctx, func(tx Dao) error {
+		if fn != nil {
+			if err := toCDError(fn(ctx, tx), "migration failed"); err != nil {
+				return err
+			}
+		} else {
+			for _, statement := range SplitStatements(sqlText) {
+				if _, err := tx.ExecuteContext(ctx, statement); err != nil {
+					return err
+				}
+			}
+		}
+
+		return record(tx)
+	}, WithMaxRetries(0))
+}
+
+func (s *Migrator) migration(version int64) Migration {
+	idx := sort.Search(len(s.migrations), func(i int) bool {
+		return s.migrations[i].Version >= version
+	})
+	return s.migrations[idx]
+}
+
+func (s *Migrator) bindVar(idx int) string {
+	return Placeholder(s.driverName, idx)
+}
+
+// withLock 固定连接并持有迁移锁执行 fn，dry-run 时不加锁
+func (s *Migrator) withLock(ctx context.Context, fn func() *cd.Error) *cd.Error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if s.options.dryRun {
+		return fn()
+	}
+
+	return s.base.pinConnection(ctx, func() *cd.Error {
+		lockSQL, unlockSQL, lockKey := s.lockStatements()
+		deadline := time.Now().Add(s.options.lockTimeout)
+		for {
+			locked, err := QueryOne[bool](ctx, s.dao, lockSQL, lockKey)
+			if err != nil {
+				return err
+			}
+			if locked {
+				break
+			}
+			if time.Now().After(deadline) {
+				return cd.NewError(cd.Timeout, "acquire migration lock timeout")
+			}
+
+			select {
+			case <-ctx.Done():
+				return logStatementError(ctx, "acquire migration lock", lockSQL, ctx.Err())
+			case <-time.After(migrationLockInterval):
+			}
+		}
+		defer func() {
+			// 使用独立的 ctx，调用方取消时仍然释放锁；释放失败时锁随连接关闭释放
+			if _, err := s.dao.ExecuteContext(context.WithoutCancel(ctx), unlockSQL, lockKey); err != nil {
+				slog.Warn("release migration lock failed", "table", s.options.tableName, "error", err)
+			}
+		}()
+
+		return fn()
+	})
+}
+
+// lockStatements 按驱动返回加锁、解锁语句和锁的标识，锁按迁移记录表区分
+func (s *Migrator) lockStatements() (string, string, any) {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte("magic_migration:" + s.options.tableName))
+	if s.driverName == "postgres" {
+		return "SELECT pg_try_advisory_lock($1)", "SELECT pg_advisory_unlock($1)", int64(hash.Sum64())
+	}
+
+	// MySQL 的锁名最长 64 个字符
+	return "SELECT GET_LOCK(?, 0)", "DO RELEASE_LOCK(?)", fmt.Sprintf("magic_migration_%x", hash.Sum64())
+}
+
+// loadRecords 读取迁移记录，create 为 false 且记录表不存在时返回空
+func (s *Migrator) loadRecords(ctx context.Context, create bool) (map[int64]migrationRecord, *cd.Error) {
+	if create {
+		createSQL := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version BIGINT NOT NULL PRIMARY KEY, name VARCHAR(255) NOT NULL, checksum VARCHAR(64) NOT NULL, applied_at BIGINT NOT NULL)", s.options.tableName)
+		if _, err := s.dao.ExecuteContext(ctx, createSQL); err != nil {
+			return nil, err
+		}
+	} else {
+		exist, _, err := s.dao.CheckTableExist(s.options.tableName)
+		if err != nil {
+			return nil, err
+		}
+		if !exist {
+			return map[int64]migrationRecord{}, nil
+		}
+	}
+
+	querySQL := fmt.Sprintf("SELECT version, name, checksum, applied_at FROM %s ORDER BY version", s.options.tableName)
+	records, err := QueryAll[migrationRecord](ctx, s.dao, querySQL)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[int64]migrationRecord, len(records))
+	for _, record := range records {
+		ret[record.Version] = record
+	}
+	return ret, nil
+}
+
+// merge 合并迁移列表和迁移记录
+func (s *Migrator) merge(records map[int64]migrationRecord) []MigrationStatus {
+	ret := make([]MigrationStatus, 0, len(s.migrations)+len(records))
+	known := make(map[int64]bool, len(s.migrations))
+	for _, migration := range s.migrations {
+		known[migration.Version] = true
+		status := MigrationStatus{
+			Version:  migration.Version,
+			Name:     migration.Name,
+			Checksum: migration.Checksum(),
+		}
+		if record, ok := records[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = time.UnixMilli(record.AppliedAt)
+			status.Modified = record.Checksum != "" && status.Checksum != "" && record.Checksum != status.Checksum
+		}
+		ret = append(ret, status)
+	}
+
+	for _, record := range records {
+		if known[record.Version] {
+			continue
+		}
+		ret = append(ret, MigrationStatus{
+			Version:   record.Version,
+			Name:      record.Name,
+			Checksum:  record.Checksum,
+			Applied:   true,
+			AppliedAt: time.UnixMilli(record.AppliedAt),
+			Missing:   true,
+		})
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Version < ret[j].Version
+	})
+	return ret
+}
+
+func checkModified(statuses []MigrationStatus) *cd.Error {
+	modified := []string{}
+	for _, status := range statuses {
+		if status.Modified {
+			modified = append(modified, strconv.FormatInt(status.Version, 10))
+		}
+	}
+	if len(modified) == 0 {
+		return nil
+	}
+
+	return cd.NewError(cd.DataCorrupted, fmt.Sprintf("applied migrations modified: %s", strings.Join(modified, ", ")))
+}
+
+// LoadMigrations 从 fsys 的 dir 目录加载 SQL 迁移，文件名格式为 <版本>_<名称>.up.sql 和 <版本>_<名称>.down.sql，
+// 其它文件被忽略；可以配合 embed.FS 将迁移编译进程序
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, *cd.Error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, cd.WrapError(cd.NotFound, err, "read migration directory failed")
+	}
+
+	migrations := map[int64]*Migration{}
+	for _, entry := range entries {
+		matches := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || matches == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, cd.WrapError(cd.InvalidParameter, err, fmt.Sprintf("illegal migration file %s", entry.Name()))
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, cd.WrapError(cd.Unexpected, err, fmt.Sprintf("read migration file %s failed", entry.Name()))
+		}
+
+		migration, ok := migrations[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: matches[2]}
+			migrations[version] = migration
+		}
+		if migration.Name != matches[2] {
+			return nil, cd.NewError(cd.Duplicated, fmt.Sprintf("duplicated migration version %d", version))
+		}
+		if matches[3] == "up" {
+			migration.UpSQL = string(content)
+		} else {
+			migration.DownSQL = string(content)
+		}
+	}
+
+	ret := make([]Migration, 0, len(migrations))
+	for _, migration := range migrations {
+		if strings.TrimSpace(migration.UpSQL) == "" {
+			return nil, cd.NewError(cd.InvalidParameter, fmt.Sprintf("migration %d has no up migration", migration.Version))
+		}
+		ret = append(ret, *migration)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Version < ret[j].Version
+	})
+	return ret, nil
+}
+
+// SplitStatements 按分号拆分 SQL 脚本，忽略引号、反引号、PostgreSQL 美元引用和注释中的分号，
+// 不支持 MySQL 客户端的 DELIMITER 指令
+func SplitStatements(script string) []string {
+	ret := []string{}
+	builder := strings.Builder{}
+	flush := func() {
+		if statement := strings.TrimSpace(builder.String()); statement != "" {
+			ret = append(ret, statement)
+		}
+		builder.Reset()
+	}
+
+	for idx := 0; idx < len(script); {
+		ch := script[idx]
+		switch {
+		case ch == '\'' || ch == '"' || ch == '`':
+			end := idx + 1
+			for end < len(script) {
+				if script[end] == ch {
+					// 连续两个引号表示转义
+					if end+1 < len(script) && script[end+1] == ch {
+						end += 2
+						continue
+					}
+					break
+				}
+				if script[end] == '\\' && ch != '`' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(script))
+			builder.WriteString(script[idx:end])
+			idx = end
+		case ch == '-' && strings.HasPrefix(script[idx:], "--"):
+			end := strings.IndexByte(script[idx:], '\n')
+			if end < 0 {
+				end = len(script) - idx
+			}
+			builder.WriteString(script[idx : idx+end])
+			idx += end
+		case ch == '/' && strings.HasPrefix(script[idx:], "/*"):
+			end := strings.Index(script[idx+2:], "*/")
+			if end < 0 {
+				end = len(script)
+			} else {
+				end = idx + 2 + end + 2
+			}
+			builder.WriteString(script[idx:end])
+			idx = end
+		case ch == '$':
+			tag := dollarQuoteTag(script[idx:])
+			if tag == "" {
+				builder.WriteByte(ch)
+				idx++
+				continue
+			}
+			end := strings.Index(script[idx+len(tag):], tag)
+			if end < 0 {
+				end = len(script)
+			} else {
+				end = idx + len(tag) + end + len(tag)
+			}
+			builder.WriteString(script[idx:end])
+			idx = end
+		case ch == ';':
+			flush()
+			idx++
+		default:
+			builder.WriteByte(ch)
+			idx++
+		}
+	}
+	flush()
+	return ret
+}
+
+// dollarQuoteTag 返回 $tag$ 形式的美元引用标记，不是美元引用时返回空
+func dollarQuoteTag(script string) string {
+	for idx := 1; idx < len(script); idx++ {
+		ch := script[idx]
+		if ch == '$' {
+			return script[:idx+1]
+		}
+		if !(ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || idx > 1 && ch >= '0' && ch <= '9') {
+			return ""
+		}
+	}
+	return ""
+}
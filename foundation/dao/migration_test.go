@@ -0,0 +1,325 @@
+package dao
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	cd "github.com/muidea/magicCommon/def"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeMigrationDB 模拟迁移记录表和咨询锁
+type fakeMigrationDB struct {
+	mu      sync.Mutex
+	created bool
+	locked  bool
+	records map[int64][]driver.Value
+	failOn  string
+}
+
+func newMigrationDao(t *testing.T, db *fakeMigrationDB) (*impl, *fakeConnector) {
+	db.records = map[int64][]driver.Value{}
+	connector := &fakeConnector{
+		query: db.query,
+		exec:  db.exec,
+	}
+	return &impl{BaseDao: newFakeDao(t, connector), driverName: "postgres"}, connector
+}
+
+func (s *fakeMigrationDB) query(query string, _ []driver.NamedValue) ([]string, [][]driver.Value, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case strings.Contains(query, "pg_try_advisory_lock"):
+		if s.locked {
+			return []string{"pg_try_advisory_lock"}, [][]driver.Value{{false}}, nil
+		}
+		s.locked = true
+		return []string{"pg_try_advisory_lock"}, [][]driver.Value{{true}}, nil
+	case strings.Contains(query, "pg_tables") || strings.Contains(query, "information_schema"):
+		if !s.created {
+			return []string{"name", "type"}, nil, nil
+		}
+		return []string{"name", "type"}, [][]driver.Value{{DefaultMigrationTable, BaseTable}}, nil
+	case strings.HasPrefix(query, "SELECT version"):
+		versions := []int64{}
+		for version := range s.records {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+		rows := [][]driver.Value{}
+		for _, version := range versions {
+			rows = append(rows, s.records[version])
+		}
+		return []string{"version", "name", "checksum", "applied_at"}, rows, nil
+	}
+	return nil, nil, errors.New("unexpected query " + query)
+}
+
+func (s *fakeMigrationDB) exec(query string, args []driver.NamedValue) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failOn != "" && strings.Contains(query, s.failOn) {
+		return 0, errors.New("syntax error")
+	}
+	switch {
+	case strings.HasPrefix(query, "CREATE TABLE IF NOT EXISTS "+DefaultMigrationTable):
+		s.created = true
+	case strings.HasPrefix(query, "INSERT INTO "+DefaultMigrationTable):
+		values := make([]driver.Value, len(args))
+		for idx, arg := range args {
+			values[idx] = arg.Value
+		}
+		s.records[args[0].Value.(int64)] = values
+	case strings.HasPrefix(query, "DELETE FROM "+DefaultMigrationTable):
+		delete(s.records, args[0].Value.(int64))
+	case strings.Contains(query, "pg_advisory_unlock"):
+		s.locked = false
+	}
+	return 1, nil
+}
+
+func testMigrations() []Migration {
+	return []Migration{
+		{
+			Version: 2,
+			Name:    "seed_users",
+			Up: func(ctx context.Context, tx Dao) error {
+				_, err := tx.ExecuteContext(ctx, "INSERT INTO users VALUES (1)")
+				return err
+			},
+			Down: func(ctx context.Context, tx Dao) error {
+				_, err := tx.ExecuteContext(ctx, "DELETE FROM users")
+				return err
+			},
+		},
+		{
+			Version: 1,
+			Name:    "create_users",
+			UpSQL:   "CREATE TABLE users (id BIGINT);\nCREATE INDEX users_id ON users (id);",
+			DownSQL: "DROP TABLE users;",
+		},
+	}
+}
+
+// migrationStatements 过滤出迁移本身执行的语句
+func migrationStatements(connector *fakeConnector) []string {
+	ret := []string{}
+	for _, statement := range connector.statements() {
+		if strings.Contains(statement, "users") || statement == "BEGIN" || statement == "COMMIT" || statement == "ROLLBACK" {
+			ret = append(ret, statement)
+		}
+	}
+	return ret
+}
+
+func TestMigrator_Apply(t *testing.T) {
+	db := &fakeMigrationDB{}
+	dao, connector := newMigrationDao(t, db)
+
+	migrator, err := NewMigrator(dao, testMigrations())
+	assert.Nil(t, err)
+
+	applied, err := migrator.Apply(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, applied, 2)
+	assert.Equal(t, int64(1), applied[0].Version)
+	assert.Equal(t, "create_users", applied[0].Name)
+	assert.True(t, applied[0].Applied)
+	assert.Equal(t, "", applied[1].Checksum)
+	assert.Equal(t, []string{
+		"BEGIN", "CREATE TABLE users (id BIGINT)", "CREATE INDEX users_id ON users (id)", "COMMIT",
+		"BEGIN", "INSERT INTO users VALUES (1)", "COMMIT",
+	}, migrationStatements(connector))
+	assert.False(t, db.locked)
+
+	applied, err = migrator.Apply(context.Background())
+	assert.Nil(t, err)
+	assert.Empty(t, applied)
+
+	statuses, err := migrator.Status(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, statuses, 2)
+	for _, status := range statuses {
+		assert.True(t, status.Applied)
+		assert.False(t, status.Modified)
+		assert.WithinDuration(t, time.Now(), status.AppliedAt, time.Minute)
+	}
+}
+
+func TestMigrator_ModifiedAndMissing(t *testing.T) {
+	db := &fakeMigrationDB{}
+	dao, connector := newMigrationDao(t, db)
+
+	migrator, _ := NewMigrator(dao, testMigrations())
+	_, err := migrator.Apply(context.Background())
+	assert.Nil(t, err)
+
+	migrations := testMigrations()
+	migrations[1].UpSQL = "CREATE TABLE users (id BIGINT, name TEXT);"
+	migrations = append(migrations, Migration{Version: 3, Name: "add_orders", UpSQL: "CREATE TABLE orders (id BIGINT);"})
+	migrator, _ = NewMigrator(dao, migrations[1:])
+	executed := len(connector.statements())
+
+	_, err = migrator.Apply(context.Background())
+	assert.NotNil(t, err)
+	assert.EqualValues(t, cd.DataCorrupted, err.Code)
+	for _, statement := range connector.statements()[executed:] {
+		assert.NotContains(t, statement, "orders")
+	}
+
+	statuses, err := migrator.Status(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, statuses, 3)
+	assert.True(t, statuses[0].Modified)
+	assert.True(t, statuses[1].Missing)
+	assert.Equal(t, "seed_users", statuses[1].Name)
+	assert.False(t, statuses[2].Applied)
+}
+
+func TestMigrator_DryRun(t *testing.T) {
+	db := &fakeMigrationDB{}
+	dao, connector := newMigrationDao(t, db)
+
+	migrator, err := NewMigrator(dao, testMigrations(), WithDryRun())
+	assert.Nil(t, err)
+
+	pending, err := migrator.Apply(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, pending, 2)
+	assert.False(t, pending[0].Applied)
+	assert.False(t, db.created)
+	for _, statement := range connector.statements() {
+		assert.True(t, strings.HasPrefix(statement, "SELECT"), statement)
+	}
+}
+
+func TestMigrator_ApplyFailure(t *testing.T) {
+	db := &fakeMigrationDB{failOn: "INSERT INTO users"}
+	dao, connector := newMigrationDao(t, db)
+
+	migrator, _ := NewMigrator(dao, testMigrations())
+	applied, err := migrator.Apply(context.Background())
+	assert.NotNil(t, err)
+	assert.Len(t, applied, 1)
+	assert.Equal(t, "ROLLBACK", connector.statements()[len(connector.statements())-2])
+	assert.Contains(t, db.records, int64(1))
+	assert.NotContains(t, db.records, int64(2))
+	assert.False(t, db.locked)
+}
+
+func TestMigrator_LockTimeout(t *testing.T) {
+	db := &fakeMigrationDB{locked: true}
+	dao, _ := newMigrationDao(t, db)
+	db.locked = true
+
+	migrator, _ := NewMigrator(dao, testMigrations(), WithMigrationLockTimeout(150*time.Millisecond))
+	_, err := migrator.Apply(context.Background())
+	assert.NotNil(t, err)
+	assert.EqualValues(t, cd.Timeout, err.Code)
+	assert.False(t, db.created)
+}
+
+func TestMigrator_Rollback(t *testing.T) {
+	db := &fakeMigrationDB{}
+	dao, connector := newMigrationDao(t, db)
+
+	migrations := append(testMigrations(), Migration{Version: 3, Name: "add_orders", UpSQL: "CREATE TABLE orders (id BIGINT);"})
+	migrator, _ := NewMigrator(dao, migrations)
+	_, err := migrator.Apply(context.Background())
+	assert.Nil(t, err)
+
+	// 版本 3 没有回滚，执行前检查失败
+	_, err = migrator.Rollback(context.Background(), 0)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, cd.InvalidOperation, err.Code)
+	assert.Len(t, db.records, 3)
+
+	migrations[2].DownSQL = "DROP TABLE orders;"
+	migrator, _ = NewMigrator(dao, migrations)
+	executed := len(connector.statements())
+	rolledBack, err := migrator.Rollback(context.Background(), 1)
+	assert.Nil(t, err)
+	assert.Len(t, rolledBack, 2)
+	assert.Equal(t, int64(3), rolledBack[0].Version)
+	assert.Equal(t, int64(2), rolledBack[1].Version)
+	assert.Len(t, db.records, 1)
+
+	statements := []string{}
+	for _, statement := range connector.statements()[executed:] {
+		if strings.HasPrefix(statement, "DROP") || strings.HasPrefix(statement, "DELETE FROM users") {
+			statements = append(statements, statement)
+		}
+	}
+	assert.Equal(t, []string{"DROP TABLE orders", "DELETE FROM users"}, statements)
+}
+
+func TestNewMigrator_Validate(t *testing.T) {
+	dao, _ := newMigrationDao(t, &fakeMigrationDB{})
+
+	_, err := NewMigrator(dao, append(testMigrations(), Migration{Version: 1, Name: "again", UpSQL: "SELECT 1"}))
+	assert.EqualValues(t, cd.Duplicated, err.Code)
+
+	_, err = NewMigrator(dao, []Migration{{Version: 1, Name: "empty"}})
+	assert.EqualValues(t, cd.InvalidParameter, err.Code)
+
+	_, err = NewMigrator(dao, testMigrations(), WithMigrationTable("migrations; DROP TABLE users"))
+	assert.EqualValues(t, cd.InvalidParameter, err.Code)
+
+	_, err = NewMigrator(&impl{BaseDao: dao.BaseDao, driverName: "sqlite"}, testMigrations())
+	assert.EqualValues(t, cd.NotImplemented, err.Code)
+}
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id BIGINT);")},
+		"migrations/0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		"migrations/0002_add_orders.up.sql":     {Data: []byte("CREATE TABLE orders (id BIGINT);")},
+		"migrations/README.md":                  {Data: []byte("ignored")},
+	}
+
+	migrations, err := LoadMigrations(fsys, "migrations")
+	assert.Nil(t, err)
+	assert.Len(t, migrations, 2)
+	assert.Equal(t, Migration{Version: 1, Name: "create_users", UpSQL: "CREATE TABLE users (id BIGINT);", DownSQL: "DROP TABLE users;"}, migrations[0])
+	assert.Equal(t, "add_orders", migrations[1].Name)
+	assert.Equal(t, "", migrations[1].DownSQL)
+
+	fsys["migrations/0003_drop_orders.down.sql"] = &fstest.MapFile{Data: []byte("DROP TABLE orders;")}
+	_, err = LoadMigrations(fsys, "migrations")
+	assert.EqualValues(t, cd.InvalidParameter, err.Code)
+
+	_, err = LoadMigrations(fsys, "missing")
+	assert.EqualValues(t, cd.NotFound, err.Code)
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `
+-- 创建表; 注释中的分号
+CREATE TABLE note (body TEXT DEFAULT 'a;b', title TEXT DEFAULT 'it''s;');
+/* 块注释; */
+INSERT INTO note (body) VALUES ("x;y"), (` + "`z;`" + `);
+CREATE FUNCTION touch() RETURNS trigger AS $body$
+BEGIN
+    NEW.updated_at = now();
+    RETURN NEW;
+END;
+$body$ LANGUAGE plpgsql;
+SELECT $1;;
+`
+	statements := SplitStatements(script)
+	assert.Len(t, statements, 4)
+	assert.Equal(t, "-- 创建表; 注释中的分号\nCREATE TABLE note (body TEXT DEFAULT 'a;b', title TEXT DEFAULT 'it''s;')", statements[0])
+	assert.True(t, strings.HasPrefix(statements[1], "/* 块注释; */\nINSERT INTO note"))
+	assert.True(t, strings.HasSuffix(statements[2], "$body$ LANGUAGE plpgsql"))
+	assert.Equal(t, "SELECT $1", statements[3])
+}
@@ -0,0 +1,260 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	cd "github.com/muidea/magicCommon/def"
+)
+
+const (
+	// DefaultTxMaxRetries 事务冲突时默认的重试次数
+	DefaultTxMaxRetries = 3
+	// DefaultTxRetryBackoff 默认的重试间隔，第 n 次重试等待 n 倍间隔
+	DefaultTxRetryBackoff = 20 * time.Millisecond
+)
+
+// RetryClassifier 由 Driver 可选实现，识别死锁、序列化失败等可以重试整个事务的错误
+type RetryClassifier interface {
+	IsRetryable(err error) bool
+}
+
+// sqlStater 返回 SQLSTATE 的驱动错误，Driver 未实现 RetryClassifier 时使用
+type sqlStater interface {
+	SQLState() string
+}
+
+type TxOption func(*txOptions)
+
+type txOptions struct {
+	isolation    sql.IsolationLevel
+	readOnly     bool
+	maxRetries   int
+	retryBackoff time.Duration
+}
+
+// WithIsolation 设置事务隔离级别，默认使用数据库的默认级别
+func WithIsolation(level sql.IsolationLevel) TxOption {
+	return func(o *txOptions) {
+		o.isolation = level
+	}
+}
+
+// WithReadOnly 设置只读事务
+func WithReadOnly() TxOption {
+	return func(o *txOptions) {
+		o.readOnly = true
+	}
+}
+
+// WithMaxRetries 设置事务冲突时的重试次数，0 表示不重试，默认 DefaultTxMaxRetries
+func WithMaxRetries(retries int) TxOption {
+	return func(o *txOptions) {
+		if retries >= 0 {
+			o.maxRetries = retries
+		}
+	}
+}
+
+// WithRetryBackoff 设置重试间隔，默认 DefaultTxRetryBackoff
+func WithRetryBackoff(backoff time.Duration) TxOption {
+	return func(o *txOptions) {
+		if backoff >= 0 {
+			o.retryBackoff = backoff
+		}
+	}
+}
+
+// WithTransaction 在事务中执行 fn，详见 runTransaction
+func (s *BaseDao) WithTransaction(ctx context.Context, fn func(tx Dao) error, opts ...TxOption) *cd.Error {
+	return runTransaction(ctx, s, s, fn, opts...)
+}
+
+// runTransaction 在事务中执行 fn，fn 返回 nil 时提交，返回错误或 panic 时回滚，panic 回滚后继续抛出。
+// dao 已处于事务中时（包括嵌套的 WithTransaction 和 BeginTransaction 开始的事务）改为使用 SAVEPOINT，
+// fn 失败只回滚到该 SAVEPOINT，隔离级别和重试选项不生效。
+// 最外层事务遇到死锁或序列化失败时整体重试，重试用尽后返回 cd.VersionConflict，fn 需要能够重复执行
+func runTransaction(ctx context.Context, dao Dao, base *BaseDao, fn func(tx Dao) error, opts ...TxOption) *cd.Error {
+	if fn == nil {
+		return ErrInvalidParameter
+	}
+	if base.dbHandle == nil {
+		return ErrDatabaseNotInitialized
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if base.dbTx != nil {
+		return runSavepoint(ctx, dao, base, fn)
+	}
+
+	options := txOptions{
+		maxRetries:   DefaultTxMaxRetries,
+		retryBackoff: DefaultTxRetryBackoff,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := runTransactionOnce(ctx, dao, base, fn, &sql.TxOptions{Isolation: options.isolation, ReadOnly: options.readOnly})
+		if err == nil {
+			return nil
+		}
+		if !isRetryable(dao, err) {
+			return err
+		}
+		if attempt >= options.maxRetries {
+			return conflictError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return logStatementError(ctx, "retry transaction", "", ctx.Err())
+		case <-time.After(time.Duration(attempt+1) * options.retryBackoff):
+		}
+	}
+}
+
+func runTransactionOnce(ctx context.Context, dao Dao, base *BaseDao, fn func(tx Dao) error, txOptions *sql.TxOptions) *cd.Error {
+	if err := base.beginTransaction(ctx, txOptions); err != nil {
+		return err
+	}
+
+	// fn 返回错误或 panic 时回滚
+	committed := false
+	defer func() {
+		if !committed {
+			_ = base.endTransaction(false)
+		}
+	}()
+
+	if err := callTransaction(fn, dao); err != nil {
+		return err
+	}
+
+	committed = true
+	return base.endTransaction(true)
+}
+
+func runSavepoint(ctx context.Context, dao Dao, base *BaseDao, fn func(tx Dao) error) *cd.Error {
+	base.savepointDepth++
+	defer func() { base.savepointDepth-- }()
+
+	name := fmt.Sprintf("magic_sp_%d", base.savepointDepth)
+	if _, err := base.ExecuteContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+
+	released := false
+	defer func() {
+		if !released {
+			_, _ = base.ExecuteContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+		}
+	}()
+
+	if err := callTransaction(fn, dao); err != nil {
+		return err
+	}
+
+	released = true
+	_, err := base.ExecuteContext(ctx, "RELEASE SAVEPOINT "+name)
+	return err
+}
+
+// beginTransaction 开始事务，与 BeginTransaction 共用嵌套计数
+func (s *BaseDao) beginTransaction(ctx context.Context, txOptions *sql.TxOptions) *cd.Error {
+	if s.dbHandle == nil {
+		return ErrDatabaseNotInitialized
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	atomic.AddInt32(&s.dbTxCount, 1)
+	if s.dbTx == nil && s.dbTxCount == 1 {
+		_ = s.closeRows()
+
+		var tx *sql.Tx
+		var err error
+		if s.dbConn != nil {
+			tx, err = s.dbConn.BeginTx(ctx, txOptions)
+		} else {
+			tx, err = s.dbHandle.BeginTx(ctx, txOptions)
+		}
+		if err != nil {
+			atomic.AddInt32(&s.dbTxCount, -1)
+			return logStatementError(ctx, "begin transaction", "", err)
+		}
+
+		s.dbTx = tx
+		s.dbTxCtx = ctx
+	}
+
+	return nil
+}
+
+// endTransaction 结束事务，忽略 fn 中未配对的 BeginTransaction
+func (s *BaseDao) endTransaction(commit bool) *cd.Error {
+	atomic.StoreInt32(&s.dbTxCount, 0)
+	if s.dbTx == nil {
+		return nil
+	}
+
+	_ = s.closeRows()
+	if commit {
+		return s.finishTransaction("commit transaction", s.dbTx.Commit())
+	}
+	return s.finishTransaction("rollback transaction", s.dbTx.Rollback())
+}
+
+// callTransaction 执行 fn 并将错误转换为 *cd.Error
+func callTransaction(fn func(tx Dao) error, dao Dao) *cd.Error {
+	return toCDError(fn(dao), "transaction aborted")
+}
+
+// toCDError 将回调返回的错误转换为 *cd.Error，保留原始错误；值为 nil 的 *cd.Error 视为成功
+func toCDError(err error, message string) *cd.Error {
+	if err == nil {
+		return nil
+	}
+	if cdErr, ok := err.(*cd.Error); ok {
+		return cdErr
+	}
+
+	return cd.WrapError(cd.Unexpected, err, message)
+}
+
+// conflictError 重试用尽的冲突错误
+func conflictError(err *cd.Error) *cd.Error {
+	ret := cd.NewError(cd.VersionConflict, fmt.Sprintf("transaction conflict: %s", err.Message))
+	ret.Cause = err
+	return ret
+}
+
+// isRetryable 判断错误链中是否有死锁或序列化失败
+func isRetryable(dao Dao, err error) bool {
+	if driverName, ok := DriverNameOf(dao); ok {
+		if driver, ok := GetDriver(driverName); ok {
+			if classifier, ok := driver.(RetryClassifier); ok {
+				return classifier.IsRetryable(err)
+			}
+		}
+	}
+
+	var stater sqlStater
+	if errors.As(err, &stater) {
+		return isRetryableSQLState(stater.SQLState())
+	}
+	return false
+}
+
+// isRetryableSQLState 40001 序列化失败，40P01 死锁
+func isRetryableSQLState(state string) bool {
+	return state == "40001" || state == "40P01"
+}
@@ -0,0 +1,177 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cd "github.com/muidea/magicCommon/def"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTransactionDao(t *testing.T, connector *fakeConnector) *impl {
+	return &impl{BaseDao: newFakeDao(t, connector), driverName: "fake"}
+}
+
+func TestWithTransaction_CommitAndRollback(t *testing.T) {
+	connector := &fakeConnector{}
+	dao := newTransactionDao(t, connector)
+
+	err := dao.WithTransaction(context.Background(), func(tx Dao) error {
+		assert.Same(t, dao, tx)
+		_, err := tx.Execute("UPDATE account SET balance = 1")
+		// 值为 nil 的 *cd.Error 视为成功
+		return err
+	})
+	assert.Nil(t, err)
+
+	businessErr := errors.New("insufficient balance")
+	err = dao.WithTransaction(context.Background(), func(tx Dao) error {
+		_, _ = tx.Execute("UPDATE account SET balance = 2")
+		return businessErr
+	})
+	assert.NotNil(t, err)
+	assert.EqualValues(t, cd.Unexpected, err.Code)
+	assert.ErrorIs(t, err, businessErr)
+
+	assert.Equal(t, []string{
+		"BEGIN", "UPDATE account SET balance = 1", "COMMIT",
+		"BEGIN", "UPDATE account SET balance = 2", "ROLLBACK",
+	}, connector.statements())
+	assert.Nil(t, dao.dbTx)
+	assert.EqualValues(t, 0, dao.dbTxCount)
+}
+
+func TestWithTransaction_Savepoint(t *testing.T) {
+	connector := &fakeConnector{}
+	dao := newTransactionDao(t, connector)
+
+	err := dao.WithTransaction(context.Background(), func(tx Dao) error {
+		_, _ = tx.Execute("INSERT INTO orders VALUES (1)")
+		innerErr := tx.WithTransaction(context.Background(), func(tx Dao) error {
+			_, _ = tx.Execute("INSERT INTO audit VALUES (1)")
+			return cd.NewError(cd.InvalidParameter, "illegal audit")
+		})
+		assert.EqualValues(t, cd.InvalidParameter, innerErr.Code)
+
+		return tx.WithTransaction(context.Background(), func(tx Dao) error {
+			return tx.WithTransaction(context.Background(), func(tx Dao) error {
+				_, err := tx.Execute("INSERT INTO audit VALUES (2)")
+				return err
+			})
+		})
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"BEGIN",
+		"INSERT INTO orders VALUES (1)",
+		"SAVEPOINT magic_sp_1",
+		"INSERT INTO audit VALUES (1)",
+		"ROLLBACK TO SAVEPOINT magic_sp_1",
+		"SAVEPOINT magic_sp_1",
+		"SAVEPOINT magic_sp_2",
+		"INSERT INTO audit VALUES (2)",
+		"RELEASE SAVEPOINT magic_sp_2",
+		"RELEASE SAVEPOINT magic_sp_1",
+		"COMMIT",
+	}, connector.statements())
+}
+
+func TestWithTransaction_Panic(t *testing.T) {
+	connector := &fakeConnector{}
+	dao := newTransactionDao(t, connector)
+
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = dao.WithTransaction(context.Background(), func(tx Dao) error {
+			_ = tx.WithTransaction(context.Background(), func(tx Dao) error {
+				panic("boom")
+			})
+			return nil
+		})
+	})
+	assert.Equal(t, []string{"BEGIN", "SAVEPOINT magic_sp_1", "ROLLBACK TO SAVEPOINT magic_sp_1", "ROLLBACK"}, connector.statements())
+	assert.Nil(t, dao.dbTx)
+	assert.Equal(t, 0, dao.savepointDepth)
+
+	// panic 之后可以继续使用
+	assert.Nil(t, dao.WithTransaction(context.Background(), func(Dao) error { return nil }))
+}
+
+func TestWithTransaction_Retry(t *testing.T) {
+	var attempts atomic.Int32
+	connector := &fakeConnector{
+		exec: func(query string, _ []driver.NamedValue) (int64, error) {
+			if strings.HasPrefix(query, "UPDATE") && attempts.Add(1) < 3 {
+				return 0, &fakeSQLError{state: "40P01"}
+			}
+			return 1, nil
+		},
+	}
+	dao := newTransactionDao(t, connector)
+
+	calls := 0
+	err := dao.WithTransaction(context.Background(), func(tx Dao) error {
+		calls++
+		_, err := tx.Execute("UPDATE stock SET count = count - 1")
+		return err
+	}, WithIsolation(sql.LevelSerializable), WithRetryBackoff(time.Millisecond))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []string{
+		"BEGIN Serializable", "UPDATE stock SET count = count - 1", "ROLLBACK",
+		"BEGIN Serializable", "UPDATE stock SET count = count - 1", "ROLLBACK",
+		"BEGIN Serializable", "UPDATE stock SET count = count - 1", "COMMIT",
+	}, connector.statements())
+}
+
+func TestWithTransaction_RetryExhausted(t *testing.T) {
+	connector := &fakeConnector{
+		exec: func(string, []driver.NamedValue) (int64, error) {
+			return 0, &fakeSQLError{state: "40001"}
+		},
+	}
+	dao := newTransactionDao(t, connector)
+
+	calls := 0
+	err := dao.WithTransaction(context.Background(), func(tx Dao) error {
+		calls++
+		// 嵌套事务中的冲突由最外层重试
+		return tx.WithTransaction(context.Background(), func(tx Dao) error {
+			_, err := tx.Execute("UPDATE stock SET count = count - 1")
+			return err
+		})
+	}, WithMaxRetries(1), WithRetryBackoff(time.Millisecond), WithReadOnly())
+	assert.NotNil(t, err)
+	assert.EqualValues(t, cd.VersionConflict, err.Code)
+	var sqlErr *fakeSQLError
+	assert.ErrorAs(t, err, &sqlErr)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, "BEGIN READ ONLY", connector.statements()[0])
+
+	// 非冲突错误不重试
+	connector = &fakeConnector{
+		exec: func(string, []driver.NamedValue) (int64, error) {
+			return 0, &fakeSQLError{state: "23505"}
+		},
+	}
+	dao = newTransactionDao(t, connector)
+	calls = 0
+	err = dao.WithTransaction(context.Background(), func(tx Dao) error {
+		calls++
+		_, err := tx.Execute("INSERT INTO stock VALUES (1)")
+		return err
+	})
+	assert.EqualValues(t, cd.DatabaseError, err.Code)
+	assert.Equal(t, 1, calls)
+}
+
+func TestWithTransaction_InvalidParameter(t *testing.T) {
+	baseDao := &BaseDao{}
+	assert.Equal(t, ErrInvalidParameter, baseDao.WithTransaction(context.Background(), nil))
+	assert.Equal(t, ErrDatabaseNotInitialized, baseDao.WithTransaction(context.Background(), func(Dao) error { return nil }))
+}
@@ -6,7 +6,7 @@ import (
 	"strconv"
 	"sync"
 
-	_ "log/slog"
+	"log/slog"
 
 	cd "github.com/muidea/magicCommon/def"
 	"github.com/muidea/magicCommon/event"
@@ -61,6 +61,9 @@ type RuntimeOwnership struct {
 	BackgroundRoutine bool
 }
 
+// WarmUpFunc 服务就绪前执行的预热操作，例如从磁盘恢复缓存
+type WarmUpFunc func(ctx context.Context) error
+
 type Options struct {
 	ConfigDir           string
 	ServiceName         string
@@ -69,6 +72,8 @@ type Options struct {
 	EventHub            event.Hub
 	BackgroundRoutine   task.BackgroundRoutine
 	Ownership           RuntimeOwnership
+	// WarmUps 在服务 Startup 成功后、进入 running 状态前依次执行，失败只记录日志不影响启动
+	WarmUps []WarmUpFunc
 }
 
 var application Application
@@ -185,6 +190,8 @@ func (s *appImpl) startupWithOptions(ctx context.Context, svc service.Service, o
 		return startupErr
 	}
 
+	runWarmUps(ctx, nameVal, opts.WarmUps)
+
 	s.mu.Lock()
 	s.state = StateRunning
 	s.mu.Unlock()
@@ -295,6 +302,17 @@ func (s *appImpl) resetRuntimeLocked() {
 	}
 }
 
+func runWarmUps(ctx context.Context, serviceName string, warmUps []WarmUpFunc) {
+	for idx, warmUp := range warmUps {
+		if warmUp == nil {
+			continue
+		}
+		if err := warmUp(ctx); err != nil {
+			slog.Warn("application warm up failed", "service", serviceName, "index", idx, "error", err)
+		}
+	}
+}
+
 func resolveServiceName(explicitName string) string {
 	if explicitName != "" {
 		return explicitName
@@ -308,7 +326,10 @@ func resolveServiceName(explicitName string) string {
 
 func shutdownRuntime(ctx context.Context, hub event.Hub, backgroundRoutine task.BackgroundRoutine, ownership RuntimeOwnership) {
 	if ownership.BackgroundRoutine && backgroundRoutine != nil {
-		backgroundRoutine.Shutdown(ctx)
+		result := backgroundRoutine.Drain(ctx)
+		for _, val := range result.Abandoned {
+			slog.Warn("background task abandoned on shutdown", "task", val.Name, "started", val.Started)
+		}
 	}
 	if ownership.EventHub && hub != nil {
 		hub.Terminate(ctx)
@@ -9,6 +9,7 @@ import (
 
 	cd "github.com/muidea/magicCommon/def"
 	"github.com/muidea/magicCommon/event"
+	"github.com/muidea/magicCommon/foundation/cache"
 	"github.com/muidea/magicCommon/framework/service"
 	"github.com/muidea/magicCommon/task"
 	"github.com/stretchr/testify/assert"
@@ -336,3 +337,35 @@ func TestLifecycleAdapterConvertsErrors(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Equal(t, cd.Code(cd.Unexpected), err.Code)
 }
+
+func TestApplicationWarmUpsRunBeforeRunning(t *testing.T) {
+	resetApp(t)
+	snapshotPath := filepath.Join(t.TempDir(), "users.snapshot")
+	source := cache.NewGenericKVCache[string, string](nil)
+	source.Put("user:1", "alice", cache.ForeverAgeValue)
+	assert.NoError(t, cache.SnapshotFile(source, snapshotPath))
+	source.Release()
+
+	users := cache.NewGenericKVCache[string, string](nil)
+	defer users.Release()
+	mockService := &MockService{}
+	var startupSeen bool
+	opts := Options{
+		WarmUps: []WarmUpFunc{
+			func(ctx context.Context) error {
+				startupSeen = mockService.startupCalled
+				return cache.RestoreFile(users, snapshotPath)
+			},
+			func(ctx context.Context) error {
+				return errors.New("optional warm up failed")
+			},
+		},
+	}
+
+	err := StartupWithOptions(context.Background(), mockService, opts)
+	assert.Nil(t, err)
+	assert.True(t, startupSeen)
+	assert.False(t, mockService.runCalled)
+	assert.Equal(t, "alice", users.Fetch("user:1"))
+	assert.Nil(t, Run(context.Background()))
+}
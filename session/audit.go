@@ -0,0 +1,235 @@
+package session
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AuditAction 会话审计动作
+type AuditAction string
+
+const (
+	AuditCreated       AuditAction = "created"
+	AuditRefreshed     AuditAction = "refreshed"
+	AuditClaimsChanged AuditAction = "claims_changed"
+	AuditTerminated    AuditAction = "terminated"
+	AuditExpired       AuditAction = "expired"
+)
+
+const (
+	auditReasonRevoked      = "revoked"
+	auditReasonEvicted      = "evicted by session limit"
+	auditReasonLimitReached = "session limit reached"
+	auditReasonTokenReused  = "refresh token reused"
+)
+
+// AuditRecord 会话审计记录
+type AuditRecord struct {
+	Action     AuditAction `json:"action"`
+	SessionID  string      `json:"sessionID"`
+	UserID     string      `json:"userID,omitempty"`
+	RemoteAddr string      `json:"remoteAddr,omitempty"`
+	UserAgent  string      `json:"userAgent,omitempty"`
+	Reason     string      `json:"reason,omitempty"`
+	Time       int64       `json:"time"`
+}
+
+// AuditSink 审计记录输出，Registry 在会话状态变化时同步调用
+type AuditSink interface {
+	Write(record *AuditRecord) error
+}
+
+// AuditSinkFunc 函数形式的 AuditSink
+type AuditSinkFunc func(record *AuditRecord) error
+
+func (s AuditSinkFunc) Write(record *AuditRecord) error {
+	return s(record)
+}
+
+type logAuditSink struct {
+	logger *slog.Logger
+}
+
+// NewLogAuditSink 将审计记录输出到 slog，logger 为 nil 时使用默认 logger
+func NewLogAuditSink(logger *slog.Logger) AuditSink {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &logAuditSink{logger: logger}
+}
+
+func (s *logAuditSink) Write(record *AuditRecord) error {
+	s.logger.Info("session audit",
+		"action", record.Action,
+		"session", record.SessionID,
+		"user", record.UserID,
+		"remoteAddr", record.RemoteAddr,
+		"userAgent", record.UserAgent,
+		"reason", record.Reason,
+		"time", record.Time)
+	return nil
+}
+
+type jsonAuditSink struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewJSONAuditSink 以 JSON Lines 格式写入审计记录，例如写入审计文件
+func NewJSONAuditSink(writer io.Writer) AuditSink {
+	return &jsonAuditSink{encoder: json.NewEncoder(writer)}
+}
+
+func (s *jsonAuditSink) Write(record *AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.encoder.Encode(record)
+}
+
+// LimitPolicy 用户会话数超过上限时的处理策略
+type LimitPolicy int
+
+const (
+	// LimitEvictOldest 结束最早开始的会话
+	LimitEvictOldest LimitPolicy = iota
+	// LimitReject 拒绝新的会话
+	LimitReject
+)
+
+// SessionInfo 会话摘要信息
+type SessionInfo struct {
+	ID         string `json:"id"`
+	UserID     string `json:"userID"`
+	RemoteAddr string `json:"remoteAddr"`
+	UserAgent  string `json:"userAgent"`
+	StartTime  int64  `json:"startTime"`
+	ExpireAt   int64  `json:"expireAt"`
+}
+
+// FindUserSessions 查询用户当前的会话，按开始时间排序，需要通过 WithUserClaim 指定用户声明
+func FindUserSessions(reg Registry, userID string) ([]SessionInfo, error) {
+	impl, err := registryImplOf(reg)
+	if err != nil {
+		return nil, err
+	}
+	if impl.userClaim == "" {
+		return nil, ErrUserClaimNotConfigured
+	}
+
+	return impl.findUserSessions(userID, "")
+}
+
+func contextString(context map[string]any, key string) string {
+	val, _ := context[key].(string)
+	return val
+}
+
+func contextInt(context map[string]any, key string) int64 {
+	switch val := context[key].(type) {
+	case int64:
+		return val
+	case float64:
+		return int64(val)
+	}
+	return 0
+}
+
+// findUserSessions 遍历会话存储查询用户会话，excludeID 指定的会话不计入
+func (s *sessionRegistryImpl) findUserSessions(userID, excludeID string) ([]SessionInfo, error) {
+	ret := []SessionInfo{}
+	if userID == "" {
+		return ret, nil
+	}
+
+	err := s.sessionStore.Range(func(record *SessionRecord) bool {
+		if record.ID == excludeID || userIDOf(record.Context, s.userClaim) != userID {
+			return true
+		}
+
+		ret = append(ret, SessionInfo{
+			ID:         record.ID,
+			UserID:     userID,
+			RemoteAddr: contextString(record.Context, InnerRemoteAccessAddr),
+			UserAgent:  contextString(record.Context, InnerUseAgent),
+			StartTime:  contextInt(record.Context, InnerStartTime),
+			ExpireAt:   record.ExpireAt,
+		})
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].StartTime < ret[j].StartTime
+	})
+	return ret, nil
+}
+
+// audit 写入审计记录，sessionPtr 为 nil 时只记录会话ID
+func (s *sessionRegistryImpl) audit(action AuditAction, sessionID string, sessionPtr *sessionImpl, reason string) {
+	if s == nil || s.auditSink == nil {
+		return
+	}
+
+	record := &AuditRecord{Action: action, SessionID: sessionID, Reason: reason, Time: time.Now().UTC().UnixMilli()}
+	if sessionPtr != nil {
+		sessionPtr.mu.RLock()
+		record.UserID = userIDOf(sessionPtr.context, s.userClaim)
+		record.RemoteAddr = contextString(sessionPtr.context, InnerRemoteAccessAddr)
+		record.UserAgent = contextString(sessionPtr.context, InnerUseAgent)
+		sessionPtr.mu.RUnlock()
+	}
+
+	if err := s.auditSink.Write(record); err != nil {
+		slog.Warn("write session audit failed", "session", sessionID, "action", action, "error", err)
+	}
+}
+
+// admitUser 会话首次关联用户时检查并发会话上限，返回 false 表示会话被拒绝并已结束
+func (s *sessionRegistryImpl) admitUser(sessionPtr *sessionImpl) bool {
+	if s.userClaim == "" {
+		return true
+	}
+
+	sessionPtr.mu.Lock()
+	userID := userIDOf(sessionPtr.context, s.userClaim)
+	if userID == "" || userID == sessionPtr.admittedUser {
+		sessionPtr.mu.Unlock()
+		return true
+	}
+	sessionPtr.admittedUser = userID
+	sessionPtr.mu.Unlock()
+
+	if s.sessionLimit <= 0 {
+		return true
+	}
+
+	others, err := s.findUserSessions(userID, sessionPtr.id)
+	if err != nil {
+		slog.Warn("query user sessions failed", "user", userID, "error", err)
+		return true
+	}
+	if len(others) < s.sessionLimit {
+		return true
+	}
+
+	if s.limitPolicy == LimitReject {
+		s.removeSession(sessionPtr.id)
+		s.audit(AuditTerminated, sessionPtr.id, sessionPtr, auditReasonLimitReached)
+		sessionPtr.terminate()
+		return false
+	}
+
+	for _, val := range others[:len(others)-s.sessionLimit+1] {
+		if err = s.revokeSessionWithReason(val.ID, auditReasonEvicted); err != nil {
+			slog.Warn("evict session failed", "session", val.ID, "error", err)
+		}
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package session
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type auditRecorder struct {
+	mu      sync.Mutex
+	records []AuditRecord
+}
+
+func (s *auditRecorder) sink() AuditSink {
+	return AuditSinkFunc(func(record *AuditRecord) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.records = append(s.records, *record)
+		return nil
+	})
+}
+
+func (s *auditRecorder) actions(sessionID string) []AuditAction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ret := []AuditAction{}
+	for _, val := range s.records {
+		if val.SessionID == sessionID {
+			ret = append(ret, val.Action)
+		}
+	}
+	return ret
+}
+
+func TestSessionAuditTrail(t *testing.T) {
+	recorder := &auditRecorder{}
+	registry := NewRegistryWithOptions(nil, WithAuditSink(recorder.sink()), WithUserClaim("X-Mp-Auth-Entity"))
+	defer registry.Release()
+
+	sessionPtr := newTokenTestSession(registry, "audit-session", 1)
+	sessionPtr.SetOption("role", "admin")
+	sessionPtr.SubmitOptions()
+	assert.Nil(t, RevokeSession(registry, "audit-session"))
+
+	assert.Equal(t, []AuditAction{AuditCreated, AuditClaimsChanged, AuditTerminated}, recorder.actions("audit-session"))
+	recorder.mu.Lock()
+	lastRecord := recorder.records[len(recorder.records)-1]
+	recorder.mu.Unlock()
+	assert.Equal(t, "1", lastRecord.UserID)
+	assert.Equal(t, auditReasonRevoked, lastRecord.Reason)
+}
+
+func TestSessionLimitEvictOldest(t *testing.T) {
+	registry := NewRegistryWithOptions(nil, WithUserClaim("X-Mp-Auth-Entity"), WithSessionLimit(1, LimitEvictOldest))
+	defer registry.Release()
+
+	firstSession := newTokenTestSession(registry, "first-session", 1)
+	firstToken, _ := firstSession.Signature()
+	time.Sleep(2 * time.Millisecond)
+	newTokenTestSession(registry, "second-session", 1)
+
+	sessions, err := FindUserSessions(registry, "1")
+	assert.Nil(t, err)
+	if assert.Len(t, sessions, 1) {
+		assert.Equal(t, "second-session", sessions[0].ID)
+	}
+	assert.Nil(t, lookupByToken(registry, firstToken))
+}
+
+func TestSessionLimitReject(t *testing.T) {
+	registry := NewRegistryWithOptions(nil, WithUserClaim("X-Mp-Auth-Entity"), WithSessionLimit(1, LimitReject))
+	defer registry.Release()
+
+	firstSession := newTokenTestSession(registry, "first-session", 1)
+	secondSession := newTokenTestSession(registry, "second-session", 1)
+	secondToken, _ := secondSession.Signature()
+	assert.Nil(t, lookupByToken(registry, secondToken))
+
+	firstToken, _ := firstSession.Signature()
+	assert.NotNil(t, lookupByToken(registry, firstToken))
+
+	sessions, _ := FindUserSessions(registry, "1")
+	assert.Len(t, sessions, 1)
+}
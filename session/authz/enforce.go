@@ -0,0 +1,93 @@
+package authz
+
+import (
+	"net/http"
+
+	cd "github.com/muidea/magicCommon/def"
+	"github.com/muidea/magicCommon/event"
+	fn "github.com/muidea/magicCommon/foundation/net"
+	"github.com/muidea/magicCommon/session"
+)
+
+// MiddlewareOption HTTP 授权中间件配置项
+type MiddlewareOption func(*middlewareOptions)
+
+type middlewareOptions struct {
+	sessionFunc  func(req *http.Request) session.Session
+	resourceFunc func(req *http.Request) (resource, action string)
+}
+
+// WithSessionFunc 指定获取会话的方式，默认读取 session.Middleware 写入请求 context 的会话
+func WithSessionFunc(sessionFunc func(req *http.Request) session.Session) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		if sessionFunc != nil {
+			o.sessionFunc = sessionFunc
+		}
+	}
+}
+
+// WithResourceFunc 指定请求对应的资源和动作，默认为请求路径和 HTTP 方法
+func WithResourceFunc(resourceFunc func(req *http.Request) (resource, action string)) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		if resourceFunc != nil {
+			o.resourceFunc = resourceFunc
+		}
+	}
+}
+
+// Middleware HTTP 授权中间件，校验失败时返回 403 和 cd.Error
+func (s *Policy) Middleware(opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	middlewareOpts := &middlewareOptions{
+		sessionFunc: func(req *http.Request) session.Session {
+			curSession, _ := session.FromRequest(req)
+			return curSession
+		},
+		resourceFunc: func(req *http.Request) (string, string) {
+			return req.URL.Path, req.Method
+		},
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(middlewareOpts)
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			resource, action := middlewareOpts.resourceFunc(req)
+			if err := s.Authorize(middlewareOpts.sessionFunc(req), resource, action); err != nil {
+				fn.PackageHTTPResponseWithStatusCode(res, http.StatusForbidden, err)
+				return
+			}
+
+			next.ServeHTTP(res, req)
+		})
+	}
+}
+
+// EventInterceptor 事件授权拦截器，会话从事件 context 中读取（session.NewContext），
+// 资源为事件 ID，动作为 header 中的 event.Action，缺省为 event.Notify。
+// patterns 非空时只校验 ID 匹配其中任一模式的事件。
+func (s *Policy) EventInterceptor(patterns ...string) event.Interceptor {
+	return func(ev event.Event) *cd.Error {
+		if len(patterns) > 0 && !matchAny(patterns, ev.ID()) {
+			return nil
+		}
+
+		action := ev.Header().GetString(event.Action)
+		if action == "" {
+			action = event.Notify
+		}
+		curSession, _ := session.FromContext(ev.Context())
+		return s.Authorize(curSession, ev.ID(), action)
+	}
+}
+
+func matchAny(patterns []string, val string) bool {
+	for _, pattern := range patterns {
+		if event.MatchValue(pattern, val) {
+			return true
+		}
+	}
+	return false
+}
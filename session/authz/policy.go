@@ -0,0 +1,293 @@
+package authz
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	cd "github.com/muidea/magicCommon/def"
+	"github.com/muidea/magicCommon/event"
+	"github.com/muidea/magicCommon/framework/configuration"
+	"github.com/muidea/magicCommon/session"
+)
+
+const (
+	// DefaultRoleClaim 默认保存角色的会话声明
+	DefaultRoleClaim = "roles"
+	// ActionAll 匹配全部动作
+	ActionAll = "*"
+)
+
+// Permission 权限，Resource 为资源路径模式，规则与 event.MatchValue 一致，支持 +、:id 和 #
+type Permission struct {
+	Resource string   `json:"resource"`
+	Actions  []string `json:"actions"`
+}
+
+// Role 角色，Inherits 中的角色权限会被继承
+type Role struct {
+	Name        string       `json:"name"`
+	Inherits    []string     `json:"inherits,omitempty"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// Config 策略配置，可在代码中声明或通过 configuration 加载
+type Config struct {
+	RoleClaim string `json:"roleClaim"`
+	Roles     []Role `json:"roles"`
+}
+
+// Policy 基于角色的授权策略
+type Policy struct {
+	mu        sync.RWMutex
+	roleClaim string
+	roles     map[string]Role
+	// resolved 角色展开继承后的全部权限
+	resolved map[string][]Permission
+}
+
+// NewPolicy 创建空策略，roleClaim 为空时使用 DefaultRoleClaim
+func NewPolicy(roleClaim string) *Policy {
+	if roleClaim == "" {
+		roleClaim = DefaultRoleClaim
+	}
+
+	return &Policy{roleClaim: roleClaim, roles: map[string]Role{}, resolved: map[string][]Permission{}}
+}
+
+// NewPolicyFromConfig 根据配置创建策略
+func NewPolicyFromConfig(config *Config) (*Policy, *cd.Error) {
+	if config == nil {
+		return nil, cd.NewError(cd.IllegalParam, "policy config is nil")
+	}
+
+	policy := NewPolicy(config.RoleClaim)
+	if err := policy.Reload(config); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// LoadPolicy 从配置 section 加载策略，configManager 为 nil 时使用默认配置管理器
+func LoadPolicy(configManager configuration.ConfigManager, sectionPath string) (*Policy, *cd.Error) {
+	config, err := loadConfig(configManager, sectionPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPolicyFromConfig(config)
+}
+
+func loadConfig(configManager configuration.ConfigManager, sectionPath string) (*Config, *cd.Error) {
+	if configManager == nil {
+		configManager = configuration.GetConfigManager()
+	}
+	if configManager == nil {
+		return nil, cd.NewError(cd.InvalidOperation, "config manager not initialized")
+	}
+
+	config := &Config{}
+	if err := configManager.GetSection(sectionPath, config); err != nil {
+		return nil, cd.NewError(cd.IllegalParam, fmt.Sprintf("load policy config failed, %s", err.Error()))
+	}
+	return config, nil
+}
+
+// Watch 监听配置 section 变更并重新加载策略，新配置非法时保留原策略
+func (s *Policy) Watch(configManager configuration.ConfigManager, sectionPath string) *cd.Error {
+	if configManager == nil {
+		configManager = configuration.GetConfigManager()
+	}
+	if configManager == nil {
+		return cd.NewError(cd.InvalidOperation, "config manager not initialized")
+	}
+
+	err := configManager.WatchSection(sectionPath, func(ev configuration.ConfigChangeEvent) {
+		config := &Config{}
+		byteVal, byteErr := json.Marshal(ev.NewValue)
+		if byteErr == nil {
+			byteErr = json.Unmarshal(byteVal, config)
+		}
+		if byteErr != nil {
+			slog.Warn("decode policy config failed", "section", sectionPath, "error", byteErr)
+			return
+		}
+		if reloadErr := s.Reload(config); reloadErr != nil {
+			slog.Warn("reload policy failed", "section", sectionPath, "error", reloadErr)
+		}
+	})
+	if err != nil {
+		return cd.NewError(cd.Unexpected, err.Error())
+	}
+	return nil
+}
+
+// Reload 使用新配置整体替换角色定义
+func (s *Policy) Reload(config *Config) *cd.Error {
+	if config == nil {
+		return cd.NewError(cd.IllegalParam, "policy config is nil")
+	}
+
+	roles := map[string]Role{}
+	for _, val := range config.Roles {
+		if val.Name == "" {
+			return cd.NewError(cd.IllegalParam, "role name is empty")
+		}
+		roles[val.Name] = val
+	}
+	resolved, err := resolveRoles(roles)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if config.RoleClaim != "" {
+		s.roleClaim = config.RoleClaim
+	}
+	s.roles = roles
+	s.resolved = resolved
+	return nil
+}
+
+// AddRole 添加或替换角色
+func (s *Policy) AddRole(role Role) *cd.Error {
+	if role.Name == "" {
+		return cd.NewError(cd.IllegalParam, "role name is empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	roles := make(map[string]Role, len(s.roles)+1)
+	for k, v := range s.roles {
+		roles[k] = v
+	}
+	roles[role.Name] = role
+	resolved, err := resolveRoles(roles)
+	if err != nil {
+		return err
+	}
+
+	s.roles = roles
+	s.resolved = resolved
+	return nil
+}
+
+// resolveRoles 展开角色继承，检测未定义角色和循环继承
+func resolveRoles(roles map[string]Role) (map[string][]Permission, *cd.Error) {
+	resolved := map[string][]Permission{}
+	visiting := map[string]bool{}
+
+	var resolve func(name string) ([]Permission, *cd.Error)
+	resolve = func(name string) ([]Permission, *cd.Error) {
+		if val, ok := resolved[name]; ok {
+			return val, nil
+		}
+		role, ok := roles[name]
+		if !ok {
+			return nil, cd.NewError(cd.IllegalParam, fmt.Sprintf("role %s is not defined", name))
+		}
+		if visiting[name] {
+			return nil, cd.NewError(cd.IllegalParam, fmt.Sprintf("role %s inherits itself", name))
+		}
+
+		visiting[name] = true
+		permissions := append([]Permission{}, role.Permissions...)
+		for _, parent := range role.Inherits {
+			parentPermissions, err := resolve(parent)
+			if err != nil {
+				return nil, err
+			}
+			permissions = append(permissions, parentPermissions...)
+		}
+		visiting[name] = false
+
+		resolved[name] = permissions
+		return permissions, nil
+	}
+
+	for name := range roles {
+		if _, err := resolve(name); err != nil {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
+
+// Roles 读取会话中的角色，支持字符串数组和逗号分隔的字符串
+func (s *Policy) Roles(curSession session.Session) []string {
+	if curSession == nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	roleClaim := s.roleClaim
+	s.mu.RUnlock()
+
+	rawVal, ok := curSession.GetOption(roleClaim)
+	if !ok {
+		return nil
+	}
+
+	ret := []string{}
+	switch val := rawVal.(type) {
+	case string:
+		for _, item := range strings.Split(val, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				ret = append(ret, item)
+			}
+		}
+	case []string:
+		ret = append(ret, val...)
+	case []any:
+		for _, item := range val {
+			if strVal, strOK := item.(string); strOK {
+				ret = append(ret, strVal)
+			}
+		}
+	}
+	return ret
+}
+
+// Allowed 判断角色集合是否拥有资源的指定动作权限
+func (s *Policy) Allowed(roles []string, resource, action string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, role := range roles {
+		for _, permission := range s.resolved[role] {
+			if matchAction(permission.Actions, action) && event.MatchValue(permission.Resource, resource) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchAction(actions []string, action string) bool {
+	for _, val := range actions {
+		if val == ActionAll || strings.EqualFold(val, action) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize 校验会话权限，没有会话或角色时返回 cd.Forbidden，角色权限不足时返回 cd.PermissionDenied
+func (s *Policy) Authorize(curSession session.Session, resource, action string) *cd.Error {
+	if curSession == nil {
+		return cd.NewError(cd.Forbidden, "no session")
+	}
+
+	roles := s.Roles(curSession)
+	if len(roles) == 0 {
+		return cd.NewError(cd.Forbidden, "no role granted")
+	}
+	if !s.Allowed(roles, resource, action) {
+		return cd.NewError(cd.PermissionDenied, fmt.Sprintf("permission denied, resource:%s, action:%s", resource, action))
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package authz
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	cd "github.com/muidea/magicCommon/def"
+	"github.com/muidea/magicCommon/event"
+	"github.com/muidea/magicCommon/framework/configuration"
+	"github.com/muidea/magicCommon/session"
+)
+
+const policyConfig = `
+[authz]
+roleClaim = "roles"
+
+[[authz.roles]]
+name = "viewer"
+  [[authz.roles.permissions]]
+  resource = "/api/v1/orders/#"
+  actions = ["GET"]
+
+[[authz.roles]]
+name = "operator"
+inherits = ["viewer"]
+  [[authz.roles.permissions]]
+  resource = "/api/v1/orders/:id"
+  actions = ["PUT", "DELETE"]
+  [[authz.roles.permissions]]
+  resource = "/orders/+/cancel"
+  actions = ["*"]
+`
+
+func newRoleSession(roles any) session.Session {
+	curSession := session.NewAnonymousSession("127.0.0.1", "test")
+	if roles != nil {
+		curSession.SetOption(DefaultRoleClaim, roles)
+	}
+	return curSession
+}
+
+func loadTestPolicy(t *testing.T) *Policy {
+	t.Helper()
+
+	configDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(configDir, "application.toml"), []byte(policyConfig), 0644); err != nil {
+		t.Fatalf("write config failed: %v", err)
+	}
+	manager, err := configuration.NewConfigManager(&configuration.ConfigOptions{ConfigDir: configDir, WatchInterval: time.Second})
+	if err != nil {
+		t.Fatalf("create config manager failed: %v", err)
+	}
+	t.Cleanup(func() { _ = manager.Close() })
+
+	policy, policyErr := LoadPolicy(manager, "authz")
+	if policyErr != nil {
+		t.Fatalf("load policy failed: %v", policyErr)
+	}
+	return policy
+}
+
+func TestPolicyAuthorize(t *testing.T) {
+	policy := loadTestPolicy(t)
+
+	viewer := newRoleSession([]any{"viewer"})
+	operator := newRoleSession("operator, guest")
+
+	assert.Nil(t, policy.Authorize(viewer, "/api/v1/orders/12", "GET"))
+	assert.Nil(t, policy.Authorize(viewer, "/api/v1/orders/12/items", "get"))
+	assert.EqualValues(t, cd.PermissionDenied, policy.Authorize(viewer, "/api/v1/orders/12", "DELETE").Code)
+
+	assert.Nil(t, policy.Authorize(operator, "/api/v1/orders/12", "DELETE"))
+	assert.Nil(t, policy.Authorize(operator, "/api/v1/orders/12", "GET"))
+	assert.Nil(t, policy.Authorize(operator, "/orders/7/cancel", "notify"))
+	assert.EqualValues(t, cd.PermissionDenied, policy.Authorize(operator, "/api/v1/users", "GET").Code)
+
+	assert.EqualValues(t, cd.Forbidden, policy.Authorize(newRoleSession(nil), "/api/v1/orders/12", "GET").Code)
+	assert.EqualValues(t, cd.Forbidden, policy.Authorize(nil, "/api/v1/orders/12", "GET").Code)
+}
+
+func TestPolicyRejectsIllegalRoles(t *testing.T) {
+	policy := NewPolicy("")
+	assert.NotNil(t, policy.AddRole(Role{Name: "child", Inherits: []string{"missing"}}))
+
+	_, err := NewPolicyFromConfig(&Config{Roles: []Role{
+		{Name: "a", Inherits: []string{"b"}},
+		{Name: "b", Inherits: []string{"a"}},
+	}})
+	assert.NotNil(t, err)
+
+	assert.Nil(t, policy.AddRole(Role{Name: "admin", Permissions: []Permission{{Resource: "/#", Actions: []string{ActionAll}}}}))
+	assert.Nil(t, policy.Authorize(newRoleSession([]string{"admin"}), "/any/path", "POST"))
+}
+
+func TestPolicyMiddleware(t *testing.T) {
+	policy := loadTestPolicy(t)
+	handler := policy.Middleware()(http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+		res.WriteHeader(http.StatusNoContent)
+	}))
+
+	serve := func(method string, curSession session.Session) int {
+		req := httptest.NewRequest(method, "http://example.com/api/v1/orders/3", nil)
+		if curSession != nil {
+			req = req.WithContext(session.NewContext(req.Context(), curSession))
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodGet, newRoleSession([]any{"viewer"})))
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodDelete, newRoleSession([]any{"viewer"})))
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, nil))
+}
+
+type orderObserver struct {
+	handled bool
+}
+
+func (s *orderObserver) ID() string { return "/orders/handler" }
+
+func (s *orderObserver) Notify(ev event.Event, re event.Result) {
+	s.handled = true
+	if re != nil {
+		re.Set(true, nil)
+	}
+}
+
+func TestPolicyEventInterceptor(t *testing.T) {
+	policy := loadTestPolicy(t)
+	hub := event.NewHubWithOptions(8, event.WithInterceptor(policy.EventInterceptor("/orders/#")))
+	defer hub.Terminate(context.Background())
+
+	observer := &orderObserver{}
+	hub.Subscribe("/orders/+/cancel", observer)
+
+	viewerCtx := session.NewContext(context.Background(), newRoleSession([]any{"viewer"}))
+	result := hub.Send(event.NewEventWithContext("/orders/5/cancel", "/", observer.ID(), nil, viewerCtx, nil))
+	_, err := result.Get()
+	if assert.NotNil(t, err) {
+		assert.EqualValues(t, cd.PermissionDenied, err.Code)
+	}
+	assert.False(t, observer.handled)
+
+	operatorCtx := session.NewContext(context.Background(), newRoleSession([]any{"operator"}))
+	result = hub.Send(event.NewEventWithContext("/orders/5/cancel", "/", observer.ID(), nil, operatorCtx, nil))
+	_, err = result.Get()
+	assert.Nil(t, err)
+	assert.True(t, observer.handled)
+}
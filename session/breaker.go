@@ -0,0 +1,160 @@
+package session
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultRetryMaxAttempts = 3
+	DefaultRetryBaseDelay   = 100 * time.Millisecond
+	DefaultRetryMaxDelay    = 2 * time.Second
+
+	DefaultBreakerFailureThreshold = 5
+	DefaultBreakerOpenTimeout      = 30 * time.Second
+)
+
+// RetryPolicy 幂等请求的重试策略，退避时间按 BaseDelay 指数增长并加入随机抖动
+type RetryPolicy struct {
+	// MaxAttempts 最大尝试次数，包含首次请求，小于等于 1 表示不重试
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy 默认重试策略
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: DefaultRetryMaxAttempts, BaseDelay: DefaultRetryBaseDelay, MaxDelay: DefaultRetryMaxDelay}
+}
+
+// backoff 第 attempt 次重试前的等待时间，服务端返回 Retry-After 时优先使用
+func (s RetryPolicy) backoff(attempt int, res *http.Response) time.Duration {
+	if res != nil {
+		if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return min(time.Duration(seconds)*time.Second, s.MaxDelay)
+		}
+	}
+
+	delay := s.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > s.MaxDelay {
+		delay = s.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// CircuitBreakerConfig 熔断配置，连续失败 FailureThreshold 次后熔断，
+// OpenTimeout 后放行一个探测请求，探测成功则恢复
+type CircuitBreakerConfig struct {
+	FailureThreshold int
+	OpenTimeout      time.Duration
+}
+
+// DefaultCircuitBreakerConfig 默认熔断配置
+func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{FailureThreshold: DefaultBreakerFailureThreshold, OpenTimeout: DefaultBreakerOpenTimeout}
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+type circuitBreaker struct {
+	mu       sync.Mutex
+	config   CircuitBreakerConfig
+	state    breakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// allow 判断请求是否可以发出，半开状态只放行一个探测请求
+func (s *circuitBreaker) allow() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch s.state {
+	case breakerOpen:
+		if time.Since(s.openedAt) < s.config.OpenTimeout {
+			return false
+		}
+		s.state = breakerHalfOpen
+		s.probing = true
+		return true
+	case breakerHalfOpen:
+		if s.probing {
+			return false
+		}
+		s.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (s *circuitBreaker) record(success bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.probing = false
+	if success {
+		s.state = breakerClosed
+		s.failures = 0
+		return
+	}
+
+	s.failures++
+	if s.state == breakerHalfOpen || s.failures >= s.config.FailureThreshold {
+		s.state = breakerOpen
+		s.openedAt = time.Now()
+	}
+}
+
+// release 请求被调用方取消，不计入结果
+func (s *circuitBreaker) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.probing = false
+}
+
+// breakerGroup 按 host 维护熔断器
+type breakerGroup struct {
+	mu       sync.Mutex
+	config   CircuitBreakerConfig
+	breakers map[string]*circuitBreaker
+}
+
+func newBreakerGroup(config CircuitBreakerConfig) *breakerGroup {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = DefaultBreakerFailureThreshold
+	}
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = DefaultBreakerOpenTimeout
+	}
+	return &breakerGroup{config: config, breakers: map[string]*circuitBreaker{}}
+}
+
+func (s *breakerGroup) get(host string) *circuitBreaker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	breaker, ok := s.breakers[host]
+	if !ok {
+		breaker = &circuitBreaker{config: s.config}
+		s.breakers[host] = breaker
+	}
+	return breaker
+}
+
+// defaultBreakers 未单独配置熔断的 BaseClient 共享同一组熔断器
+var defaultBreakers = newBreakerGroup(DefaultCircuitBreakerConfig())
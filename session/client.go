@@ -13,6 +13,8 @@ import (
 type AuthSecret struct {
 	Endpoint  string `json:"endpoint"`
 	AuthToken string `json:"authToken"`
+	// SignSecret 请求签名密钥，设置后 BaseClient 自动对请求签名，参见 IssueEndpointSecret
+	SignSecret string `json:"signSecret,omitempty"`
 }
 
 type Client interface {
@@ -118,6 +120,9 @@ type BaseClient struct {
 	sessionAuthorization string
 	sessionAuthSecret    *AuthSecret
 	headerContext        Context
+
+	retryPolicy *RetryPolicy
+	breakers    *breakerGroup
 }
 
 func cloneContext(ctx Context) Context {
@@ -140,8 +145,15 @@ func (s *BaseClient) GetServerURL() string {
 	return s.serverURL
 }
 
+// GetHTTPClient 返回 http.Client，绑定的 AuthSecret 带 SignSecret 时返回自动签名的 client
 func (s *BaseClient) GetHTTPClient() *http.Client {
-	return s.httpClient
+	if s.httpClient == nil || s.sessionAuthSecret == nil || s.sessionAuthSecret.SignSecret == "" {
+		return s.httpClient
+	}
+
+	signClient := *s.httpClient
+	signClient.Transport = &signingTransport{base: s.httpClient.Transport, authSecret: *s.sessionAuthSecret}
+	return &signClient
 }
 
 func (s *BaseClient) Clone() BaseClient {
@@ -179,6 +191,20 @@ func (s *BaseClient) WithAuthSecret(authSecret *AuthSecret) BaseClient {
 	return clone
 }
 
+// WithRetryPolicy 指定幂等请求的重试策略，参见 NewRequest
+func (s *BaseClient) WithRetryPolicy(policy RetryPolicy) BaseClient {
+	clone := s.Clone()
+	clone.retryPolicy = &policy
+	return clone
+}
+
+// WithCircuitBreaker 使用独立的按 host 熔断器，默认所有 BaseClient 共享一组熔断器
+func (s *BaseClient) WithCircuitBreaker(config CircuitBreakerConfig) BaseClient {
+	clone := s.Clone()
+	clone.breakers = newBreakerGroup(config)
+	return clone
+}
+
 func (s *BaseClient) AttachContext(ctx Context) {
 	s.headerContext = ctx
 }
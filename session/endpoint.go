@@ -19,8 +19,13 @@ func EncryptEndpoint(endpoint *Endpoint) (string, error) {
 		return "", valErr
 	}
 
+	secretVal, secretErr := getSecret()
+	if secretErr != nil {
+		return "", secretErr
+	}
+
 	valStr := fmt.Sprintf("%s/%s", endpoint.Endpoint, string(valData))
-	valStr, valErr = util.EncryptByAes(valStr, getSecret())
+	valStr, valErr = util.EncryptByAes(valStr, secretVal)
 	if valErr != nil {
 		return "", valErr
 	}
@@ -29,7 +34,11 @@ func EncryptEndpoint(endpoint *Endpoint) (string, error) {
 }
 
 func decodeEndpointTokenValue(val string) (ret *Endpoint, err error) {
-	secretVal := getSecret()
+	secretVal, secretErr := getSecret()
+	if secretErr != nil {
+		err = secretErr
+		return
+	}
 	strVal, strErr := util.DecryptByAes(val, secretVal)
 	if strErr != nil {
 		err = strErr
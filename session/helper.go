@@ -1,5 +1,10 @@
 package session
 
+import (
+	"context"
+	"net/http"
+)
+
 func GetSessionValue[T any](session Session, key string) (ret T, ok bool) {
 	rawVal, rawOK := session.GetOption(key)
 	if !rawOK {
@@ -16,3 +21,38 @@ func GetSessionValue[T any](session Session, key string) (ret T, ok bool) {
 	ret = realVal
 	return
 }
+
+type sessionContextKey struct{}
+
+// NewContext 将会话写入 context
+func NewContext(ctx context.Context, curSession Session) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, curSession)
+}
+
+// FromContext 从 context 读取会话
+func FromContext(ctx context.Context) (Session, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+
+	curSession, ok := ctx.Value(sessionContextKey{}).(Session)
+	return curSession, ok && curSession != nil
+}
+
+// FromRequest 读取 Middleware 写入请求 context 的会话
+func FromRequest(req *http.Request) (Session, bool) {
+	if req == nil {
+		return nil, false
+	}
+	return FromContext(req.Context())
+}
+
+// ContextValue 读取 context 中会话的指定值
+func ContextValue[T any](ctx context.Context, key string) (ret T, ok bool) {
+	curSession, sessionOK := FromContext(ctx)
+	if !sessionOK {
+		return
+	}
+
+	return GetSessionValue[T](curSession, key)
+}
@@ -8,9 +8,25 @@ import (
 	"log/slog"
 )
 
+// SignatureJWT 使用当前 KeyManager 的签名密钥签发 token，密钥带 kid 时写入 token header
 func SignatureJWT(mc jwt.MapClaims) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, mc)
-	valStr, valErr := token.SignedString([]byte(getSecret()))
+	signingKey, keyErr := getKeyManager().SigningKey()
+	if keyErr != nil {
+		slog.Error("Signature failed, fetch signing key failed", "error", keyErr.Error())
+		return "", keyErr
+	}
+
+	signKey, keyErr := signingKey.signKey()
+	if keyErr != nil {
+		slog.Error("Signature failed, illegal signing key", "error", keyErr.Error())
+		return "", keyErr
+	}
+
+	token := jwt.NewWithClaims(signingKey.signingMethod(), mc)
+	if signingKey.KID != "" {
+		token.Header["kid"] = signingKey.KID
+	}
+	valStr, valErr := token.SignedString(signKey)
 	if valErr != nil {
 		slog.Error("Signature failed, err:valErr.Error(", "field", valErr.Error())
 		return "", valErr
@@ -20,15 +36,21 @@ func SignatureJWT(mc jwt.MapClaims) (string, error) {
 }
 
 func decodeJWT(sigVal string) *sessionImpl {
-	secretVal := getSecret()
+	keyManager := getKeyManager()
 	token, err := jwt.Parse(sigVal, func(token *jwt.Token) (any, error) {
+		// 未携带 kid 的历史 token 使用当前签名密钥验签
+		kid, _ := token.Header["kid"].(string)
+		verificationKey, keyErr := keyManager.VerificationKey(kid)
+		if keyErr != nil {
+			return nil, keyErr
+		}
+
 		// Don't forget to validate the alg is what you expect:
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		if token.Method.Alg() != verificationKey.Algorithm {
 			return nil, fmt.Errorf("unexpected signing method: %v ", token.Header["alg"])
 		}
 
-		// hmacSecret is a []byte containing your secret, e.g. []byte("my_secret_key")
-		return []byte(secretVal), nil
+		return verificationKey.verifyKey(), nil
 	})
 	if err != nil {
 		//slog.Info(fmt.Sprintf("illegal jwt value:%s, secret:%s, err:%s"sigVal[1], secretVal, err.Error()))
@@ -43,6 +65,10 @@ func decodeJWT(sigVal string) *sessionImpl {
 				sessionPtr.id = v.(string)
 				continue
 			}
+			if k == jwtIDClaim {
+				sessionPtr.context[InnerTokenID] = v
+				continue
+			}
 			sessionPtr.context[k] = v
 		}
 
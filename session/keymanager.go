@@ -0,0 +1,499 @@
+package session
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/muidea/magicCommon/foundation/util"
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+const (
+	RUN_MODE_KEY      = "RUN_MODE"
+	RunModeProduction = "production"
+)
+
+const rsaKeyBits = 2048
+
+var (
+	// ErrSecretNotConfigured 生产模式下未配置 HMAC_SECRET 且未设置 KeyManager
+	ErrSecretNotConfigured = errors.New("session secret is not configured in production mode")
+	// ErrSigningKeyNotFound 根据 kid 找不到可用的验签密钥
+	ErrSigningKeyNotFound = errors.New("signing key not found")
+
+	defaultSecretWarnOnce sync.Once
+)
+
+func isProductionMode() bool {
+	return strings.EqualFold(os.Getenv(RUN_MODE_KEY), RunModeProduction)
+}
+
+// lookupSecret 读取 HMAC_SECRET，生产模式下未配置时返回错误，其它模式下退回默认值
+func lookupSecret() (string, error) {
+	secretVal := os.Getenv(HMAC_SECRET_KEY)
+	if secretVal != "" {
+		return secretVal, nil
+	}
+	if isProductionMode() {
+		return "", ErrSecretNotConfigured
+	}
+
+	defaultSecretWarnOnce.Do(func() {
+		slog.Warn("HMAC_SECRET is not configured, using built-in default secret; do not use it in production")
+	})
+	return hmacSecretDefault, nil
+}
+
+// SigningKey 签名密钥，HS256 使用 Secret，其它算法使用 PrivateKey；仅用于验签的密钥只设置 PublicKey
+type SigningKey struct {
+	KID        string
+	Algorithm  string
+	Secret     []byte
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+}
+
+// NewHMACKey 创建 HS256 密钥
+func NewHMACKey(kid string, secret []byte) *SigningKey {
+	return &SigningKey{KID: kid, Algorithm: AlgorithmHS256, Secret: secret}
+}
+
+// NewSigningKey 使用私钥创建 RS256 / ES256 / EdDSA 签名密钥
+func NewSigningKey(kid, algorithm string, privateKey crypto.Signer) (*SigningKey, error) {
+	if privateKey == nil {
+		return nil, fmt.Errorf("private key is nil")
+	}
+
+	key := &SigningKey{KID: kid, Algorithm: algorithm, PrivateKey: privateKey, PublicKey: privateKey.Public()}
+	if err := key.validate(); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// NewVerificationKey 使用公钥创建仅用于验签的密钥，例如其它服务发布的公钥
+func NewVerificationKey(kid, algorithm string, publicKey crypto.PublicKey) (*SigningKey, error) {
+	if publicKey == nil {
+		return nil, fmt.Errorf("public key is nil")
+	}
+
+	key := &SigningKey{KID: kid, Algorithm: algorithm, PublicKey: publicKey}
+	if err := key.validate(); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// GenerateSigningKey 按算法生成新的随机密钥，kid 随机生成
+func GenerateSigningKey(algorithm string) (*SigningKey, error) {
+	kid := util.NewUUID()[:16]
+	switch algorithm {
+	case AlgorithmHS256:
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		return NewHMACKey(kid, secret), nil
+	case AlgorithmRS256:
+		privateKey, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		return NewSigningKey(kid, algorithm, privateKey)
+	case AlgorithmES256:
+		privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewSigningKey(kid, algorithm, privateKey)
+	case AlgorithmEdDSA:
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewSigningKey(kid, algorithm, privateKey)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", algorithm)
+	}
+}
+
+func (s *SigningKey) validate() error {
+	switch s.Algorithm {
+	case AlgorithmHS256:
+		if len(s.Secret) == 0 {
+			return fmt.Errorf("HS256 key requires secret")
+		}
+	case AlgorithmRS256:
+		if _, ok := s.PublicKey.(*rsa.PublicKey); !ok {
+			return fmt.Errorf("RS256 key requires rsa key")
+		}
+	case AlgorithmES256:
+		publicKey, ok := s.PublicKey.(*ecdsa.PublicKey)
+		if !ok || publicKey.Curve != elliptic.P256() {
+			return fmt.Errorf("ES256 key requires ecdsa P-256 key")
+		}
+	case AlgorithmEdDSA:
+		if _, ok := s.PublicKey.(ed25519.PublicKey); !ok {
+			return fmt.Errorf("EdDSA key requires ed25519 key")
+		}
+	default:
+		return fmt.Errorf("unsupported signing algorithm: %s", s.Algorithm)
+	}
+
+	return nil
+}
+
+func (s *SigningKey) signingMethod() jwt.SigningMethod {
+	return jwt.GetSigningMethod(s.Algorithm)
+}
+
+func (s *SigningKey) signKey() (any, error) {
+	if s.Algorithm == AlgorithmHS256 {
+		return s.Secret, nil
+	}
+	if s.PrivateKey == nil {
+		return nil, fmt.Errorf("key %s can only be used for verification", s.KID)
+	}
+	return s.PrivateKey, nil
+}
+
+func (s *SigningKey) verifyKey() any {
+	if s.Algorithm == AlgorithmHS256 {
+		return s.Secret
+	}
+	return s.PublicKey
+}
+
+// KeyManager 管理 JWT 签名和验签密钥
+type KeyManager interface {
+	// SigningKey 返回当前用于签名的密钥
+	SigningKey() (*SigningKey, error)
+	// VerificationKey 根据 kid 返回验签密钥，kid 为空表示未携带 kid 的历史 token
+	VerificationKey(kid string) (*SigningKey, error)
+	// JWKS 以 JSON Web Key Set 格式导出所有非对称公钥
+	JWKS() ([]byte, error)
+}
+
+var (
+	keyManagerLock    sync.RWMutex
+	currentKeyManager KeyManager
+)
+
+// SetKeyManager 设置全局 KeyManager，传入 nil 时恢复为基于 HMAC_SECRET 的默认实现
+func SetKeyManager(keyManager KeyManager) {
+	keyManagerLock.Lock()
+	currentKeyManager = keyManager
+	keyManagerLock.Unlock()
+}
+
+func getKeyManager() KeyManager {
+	keyManagerLock.RLock()
+	defer keyManagerLock.RUnlock()
+
+	if currentKeyManager != nil {
+		return currentKeyManager
+	}
+	return envKeyManager{}
+}
+
+// envKeyManager 兼容历史行为，每次从 HMAC_SECRET 读取 HS256 密钥，token 不携带 kid
+type envKeyManager struct{}
+
+func (s envKeyManager) SigningKey() (*SigningKey, error) {
+	secretVal, err := lookupSecret()
+	if err != nil {
+		return nil, err
+	}
+	return NewHMACKey("", []byte(secretVal)), nil
+}
+
+func (s envKeyManager) VerificationKey(kid string) (*SigningKey, error) {
+	if kid != "" {
+		return nil, ErrSigningKeyNotFound
+	}
+	return s.SigningKey()
+}
+
+func (s envKeyManager) JWKS() ([]byte, error) {
+	return json.Marshal(jwkSet{Keys: []jwk{}})
+}
+
+type retiredKey struct {
+	key      *SigningKey
+	expireAt time.Time
+}
+
+// RotatingKeyManager 支持多密钥和轮换的 KeyManager。
+// 轮换后旧密钥在宽限期内仍可验签，宽限期后自动失效。
+type RotatingKeyManager struct {
+	mu           sync.RWMutex
+	active       *SigningKey
+	retired      map[string]retiredKey
+	verification map[string]*SigningKey
+	gracePeriod  time.Duration
+
+	rotationOnce sync.Once
+	stopOnce     sync.Once
+	stopCh       chan struct{}
+}
+
+// NewRotatingKeyManager 创建 KeyManager，active 为当前签名密钥，gracePeriod 为旧密钥轮换后的验签宽限期
+func NewRotatingKeyManager(active *SigningKey, gracePeriod time.Duration) (*RotatingKeyManager, error) {
+	if active == nil {
+		return nil, fmt.Errorf("active signing key is nil")
+	}
+	if err := active.validate(); err != nil {
+		return nil, err
+	}
+	if _, err := active.signKey(); err != nil {
+		return nil, err
+	}
+
+	return &RotatingKeyManager{
+		active:       active,
+		retired:      map[string]retiredKey{},
+		verification: map[string]*SigningKey{},
+		gracePeriod:  gracePeriod,
+		stopCh:       make(chan struct{}),
+	}, nil
+}
+
+func (s *RotatingKeyManager) SigningKey() (*SigningKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.active, nil
+}
+
+func (s *RotatingKeyManager) VerificationKey(kid string) (*SigningKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if kid == "" || kid == s.active.KID {
+		return s.active, nil
+	}
+	if key, ok := s.verification[kid]; ok {
+		return key, nil
+	}
+	if val, ok := s.retired[kid]; ok && time.Now().Before(val.expireAt) {
+		return val.key, nil
+	}
+
+	return nil, ErrSigningKeyNotFound
+}
+
+// Rotate 切换签名密钥，原签名密钥在宽限期内仍可用于验签
+func (s *RotatingKeyManager) Rotate(key *SigningKey) error {
+	if key == nil {
+		return fmt.Errorf("signing key is nil")
+	}
+	if err := key.validate(); err != nil {
+		return err
+	}
+	if _, err := key.signKey(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nowTime := time.Now()
+	for kid, val := range s.retired {
+		if !nowTime.Before(val.expireAt) {
+			delete(s.retired, kid)
+		}
+	}
+	if s.gracePeriod > 0 && s.active.KID != key.KID {
+		s.retired[s.active.KID] = retiredKey{key: s.active, expireAt: nowTime.Add(s.gracePeriod)}
+	}
+	s.active = key
+	return nil
+}
+
+// AddVerificationKey 添加长期有效的验签密钥
+func (s *RotatingKeyManager) AddVerificationKey(key *SigningKey) error {
+	if key == nil || key.KID == "" {
+		return fmt.Errorf("verification key requires kid")
+	}
+	if err := key.validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.verification[key.KID] = key
+	s.mu.Unlock()
+	return nil
+}
+
+// RemoveVerificationKey 移除验签密钥
+func (s *RotatingKeyManager) RemoveVerificationKey(kid string) {
+	s.mu.Lock()
+	delete(s.verification, kid)
+	s.mu.Unlock()
+}
+
+// StartRotation 按 interval 周期生成新密钥并轮换，重复调用只生效一次
+func (s *RotatingKeyManager) StartRotation(interval time.Duration, generator func() (*SigningKey, error)) {
+	if interval <= 0 || generator == nil {
+		return
+	}
+
+	s.rotationOnce.Do(func() {
+		go func() {
+			rotationTicker := time.NewTicker(interval)
+			defer rotationTicker.Stop()
+
+			for {
+				select {
+				case <-s.stopCh:
+					return
+				case <-rotationTicker.C:
+					key, err := generator()
+					if err == nil {
+						err = s.Rotate(key)
+					}
+					if err != nil {
+						slog.Error("rotate signing key failed", "error", err)
+					}
+				}
+			}
+		}()
+	})
+}
+
+// Stop 停止周期轮换
+func (s *RotatingKeyManager) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopCh)
+	})
+}
+
+func (s *RotatingKeyManager) JWKS() ([]byte, error) {
+	s.mu.RLock()
+	keys := []*SigningKey{s.active}
+	nowTime := time.Now()
+	for _, val := range s.retired {
+		if nowTime.Before(val.expireAt) {
+			keys = append(keys, val.key)
+		}
+	}
+	for _, val := range s.verification {
+		keys = append(keys, val)
+	}
+	s.mu.RUnlock()
+
+	keySet := jwkSet{Keys: []jwk{}}
+	for _, val := range keys {
+		item, ok := toJWK(val)
+		if ok {
+			keySet.Keys = append(keySet.Keys, item)
+		}
+	}
+
+	return json.Marshal(keySet)
+}
+
+type jwk struct {
+	KTY string `json:"kty"`
+	KID string `json:"kid,omitempty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	CRV string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+// toJWK 转换为 JWK，对称密钥不导出
+func toJWK(key *SigningKey) (jwk, bool) {
+	encode := base64.RawURLEncoding.EncodeToString
+	ret := jwk{KID: key.KID, Use: "sig", Alg: key.Algorithm}
+	switch publicKey := key.PublicKey.(type) {
+	case *rsa.PublicKey:
+		ret.KTY = "RSA"
+		ret.N = encode(publicKey.N.Bytes())
+		ret.E = encode(big.NewInt(int64(publicKey.E)).Bytes())
+	case *ecdsa.PublicKey:
+		ret.KTY = "EC"
+		ret.CRV = "P-256"
+		ret.X = encode(publicKey.X.FillBytes(make([]byte, 32)))
+		ret.Y = encode(publicKey.Y.FillBytes(make([]byte, 32)))
+	case ed25519.PublicKey:
+		ret.KTY = "OKP"
+		ret.CRV = "Ed25519"
+		ret.X = encode(publicKey)
+	default:
+		return ret, false
+	}
+
+	return ret, true
+}
+
+// fromJWK 解析 JWK 公钥，未指定 alg 时按密钥类型推断
+func fromJWK(item jwk) (*SigningKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	algorithm := item.Alg
+	var publicKey crypto.PublicKey
+	switch item.KTY {
+	case "RSA":
+		nVal, nErr := decode(item.N)
+		eVal, eErr := decode(item.E)
+		if nErr != nil || eErr != nil {
+			return nil, fmt.Errorf("illegal rsa jwk %s", item.KID)
+		}
+		publicKey = &rsa.PublicKey{N: new(big.Int).SetBytes(nVal), E: int(new(big.Int).SetBytes(eVal).Int64())}
+		if algorithm == "" {
+			algorithm = AlgorithmRS256
+		}
+	case "EC":
+		xVal, xErr := decode(item.X)
+		yVal, yErr := decode(item.Y)
+		if xErr != nil || yErr != nil || item.CRV != "P-256" {
+			return nil, fmt.Errorf("illegal ec jwk %s", item.KID)
+		}
+		publicKey = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(xVal), Y: new(big.Int).SetBytes(yVal)}
+		if algorithm == "" {
+			algorithm = AlgorithmES256
+		}
+	case "OKP":
+		xVal, xErr := decode(item.X)
+		if xErr != nil || item.CRV != "Ed25519" || len(xVal) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("illegal okp jwk %s", item.KID)
+		}
+		publicKey = ed25519.PublicKey(xVal)
+		if algorithm == "" {
+			algorithm = AlgorithmEdDSA
+		}
+	default:
+		return nil, fmt.Errorf("unsupported jwk key type: %s", item.KTY)
+	}
+
+	return NewVerificationKey(item.KID, algorithm, publicKey)
+}
@@ -0,0 +1,122 @@
+package session
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func signAndDecode(t *testing.T, sessionID string) *sessionImpl {
+	t.Helper()
+
+	sigVal, sigErr := SignatureJWT(jwt.MapClaims{innerSessionID: sessionID})
+	if !assert.Nil(t, sigErr) {
+		return nil
+	}
+	return decodeJWT(sigVal)
+}
+
+func TestKeyManagerAlgorithms(t *testing.T) {
+	defer SetKeyManager(nil)
+
+	for _, algorithm := range []string{AlgorithmHS256, AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA} {
+		signingKey, keyErr := GenerateSigningKey(algorithm)
+		assert.Nil(t, keyErr, algorithm)
+		keyManager, keyErr := NewRotatingKeyManager(signingKey, time.Minute)
+		assert.Nil(t, keyErr, algorithm)
+		SetKeyManager(keyManager)
+
+		sigVal, sigErr := SignatureJWT(jwt.MapClaims{innerSessionID: algorithm})
+		assert.Nil(t, sigErr, algorithm)
+		token, _, _ := new(jwt.Parser).ParseUnverified(sigVal, jwt.MapClaims{})
+		assert.Equal(t, signingKey.KID, token.Header["kid"], algorithm)
+		assert.Equal(t, algorithm, token.Header["alg"], algorithm)
+
+		sessionPtr := decodeJWT(sigVal)
+		if assert.NotNil(t, sessionPtr, algorithm) {
+			assert.Equal(t, algorithm, sessionPtr.id)
+		}
+	}
+}
+
+func TestKeyManagerRotationGracePeriod(t *testing.T) {
+	defer SetKeyManager(nil)
+
+	oldKey, _ := GenerateSigningKey(AlgorithmRS256)
+	keyManager, _ := NewRotatingKeyManager(oldKey, 50*time.Millisecond)
+	SetKeyManager(keyManager)
+
+	oldToken, _ := SignatureJWT(jwt.MapClaims{innerSessionID: "old"})
+
+	newKey, _ := GenerateSigningKey(AlgorithmEdDSA)
+	assert.Nil(t, keyManager.Rotate(newKey))
+
+	// 宽限期内旧密钥签发的 token 仍可验签
+	assert.NotNil(t, decodeJWT(oldToken))
+	assert.NotNil(t, signAndDecode(t, "new"))
+
+	time.Sleep(80 * time.Millisecond)
+	assert.Nil(t, decodeJWT(oldToken))
+	assert.NotNil(t, signAndDecode(t, "new"))
+}
+
+func TestKeyManagerRejectsAlgorithmMismatch(t *testing.T) {
+	defer SetKeyManager(nil)
+
+	rsaKey, _ := GenerateSigningKey(AlgorithmRS256)
+	keyManager, _ := NewRotatingKeyManager(rsaKey, 0)
+	SetKeyManager(keyManager)
+
+	// 使用同一 kid 但算法为 HS256 的伪造 token
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{innerSessionID: "forged"})
+	token.Header["kid"] = rsaKey.KID
+	sigVal, _ := token.SignedString([]byte("forged"))
+	assert.Nil(t, decodeJWT(sigVal))
+}
+
+func TestKeyManagerJWKS(t *testing.T) {
+	activeKey, _ := GenerateSigningKey(AlgorithmES256)
+	keyManager, _ := NewRotatingKeyManager(activeKey, time.Minute)
+
+	edKey, _ := GenerateSigningKey(AlgorithmEdDSA)
+	publicKey, keyErr := NewVerificationKey(edKey.KID, AlgorithmEdDSA, edKey.PrivateKey.Public())
+	assert.Nil(t, keyErr)
+	assert.Nil(t, keyManager.AddVerificationKey(publicKey))
+
+	hmacKey, _ := GenerateSigningKey(AlgorithmHS256)
+	assert.Nil(t, keyManager.Rotate(hmacKey))
+
+	jwksVal, jwksErr := keyManager.JWKS()
+	assert.Nil(t, jwksErr)
+
+	keySet := map[string][]map[string]string{}
+	assert.Nil(t, json.Unmarshal(jwksVal, &keySet))
+	keys := map[string]map[string]string{}
+	for _, val := range keySet["keys"] {
+		keys[val["kid"]] = val
+	}
+	// 对称密钥不导出
+	assert.Len(t, keys, 2)
+	assert.Equal(t, "EC", keys[activeKey.KID]["kty"])
+	assert.Equal(t, "P-256", keys[activeKey.KID]["crv"])
+	assert.NotEmpty(t, keys[activeKey.KID]["y"])
+	assert.Equal(t, "OKP", keys[edKey.KID]["kty"])
+	assert.Equal(t, "EdDSA", keys[edKey.KID]["alg"])
+}
+
+func TestSecretRequiredInProductionMode(t *testing.T) {
+	t.Setenv(HMAC_SECRET_KEY, "")
+	t.Setenv(RUN_MODE_KEY, RunModeProduction)
+
+	_, sigErr := SignatureJWT(jwt.MapClaims{innerSessionID: "prod"})
+	assert.ErrorIs(t, sigErr, ErrSecretNotConfigured)
+
+	_, encErr := EncryptEndpoint(&Endpoint{Endpoint: "demo", Context: map[string]any{}})
+	assert.ErrorIs(t, encErr, ErrSecretNotConfigured)
+
+	t.Setenv(RUN_MODE_KEY, "")
+	assert.NotNil(t, signAndDecode(t, "dev"))
+}
@@ -0,0 +1,222 @@
+package session
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	DefaultCSRFCookieName = "csrf_token"
+	DefaultCSRFHeaderName = "X-CSRF-Token"
+	DefaultCSRFFormField  = "csrf_token"
+)
+
+// MiddlewareOption Middleware 配置项
+type MiddlewareOption func(*middlewareOptions)
+
+type middlewareOptions struct {
+	refreshWindow  time.Duration
+	csrfEnabled    bool
+	csrfCookieName string
+	csrfHeaderName string
+	csrfFormField  string
+	csrfExempt     func(req *http.Request) bool
+	csrfSecure     func(req *http.Request) bool
+	onCSRFFailure  http.Handler
+}
+
+// WithRefreshWindow token 剩余有效期小于 window 时重新签发 cookie，默认为会话超时时间的一半
+func WithRefreshWindow(window time.Duration) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		if window > 0 {
+			o.refreshWindow = window
+		}
+	}
+}
+
+// WithoutCSRF 关闭 CSRF 校验
+func WithoutCSRF() MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.csrfEnabled = false
+	}
+}
+
+// WithCSRFNames 指定 CSRF cookie、header 和表单字段名称，空值保持默认
+func WithCSRFNames(cookieName, headerName, formField string) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		if cookieName != "" {
+			o.csrfCookieName = cookieName
+		}
+		if headerName != "" {
+			o.csrfHeaderName = headerName
+		}
+		if formField != "" {
+			o.csrfFormField = formField
+		}
+	}
+}
+
+// WithCSRFExempt 指定不做 CSRF 校验的请求，例如第三方回调
+func WithCSRFExempt(exempt func(req *http.Request) bool) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.csrfExempt = exempt
+	}
+}
+
+// WithCSRFCookieSecure 指定 CSRF cookie 是否设置 Secure，
+// 默认按请求判断：TLS 连接或代理传递 X-Forwarded-Proto: https 时设置
+func WithCSRFCookieSecure(secure bool) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.csrfSecure = func(*http.Request) bool { return secure }
+	}
+}
+
+// WithCSRFFailureHandler 指定 CSRF 校验失败时的处理，默认返回 403
+func WithCSRFFailureHandler(handler http.Handler) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.onCSRFFailure = handler
+	}
+}
+
+type csrfContextKey struct{}
+
+// CSRFTokenFromContext 返回当前请求的 CSRF token，用于写入页面或响应
+func CSRFTokenFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	val, _ := ctx.Value(csrfContextKey{}).(string)
+	return val
+}
+
+// Middleware 会话中间件：
+// 1. 解析会话并写入请求 context，可通过 FromRequest / FromContext 读取；
+// 2. cookie 中的 token 临近过期时重新签发；
+// 3. 对携带会话 cookie 的非安全方法请求执行 double-submit cookie CSRF 校验。
+func Middleware(reg Registry, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	middlewareOpts := &middlewareOptions{
+		csrfEnabled:    true,
+		csrfCookieName: DefaultCSRFCookieName,
+		csrfHeaderName: DefaultCSRFHeaderName,
+		csrfFormField:  DefaultCSRFFormField,
+		csrfSecure:     isHTTPSRequest,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(middlewareOpts)
+		}
+	}
+	if middlewareOpts.onCSRFFailure == nil {
+		middlewareOpts.onCSRFFailure = http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+			http.Error(res, "invalid csrf token", http.StatusForbidden)
+		})
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			sessionToken := ReadSessionTokenFromCookie(req)
+			var csrfToken string
+			if middlewareOpts.csrfEnabled {
+				var csrfOK bool
+				csrfToken, csrfOK = middlewareOpts.verifyCSRF(res, req, sessionToken != "")
+				if !csrfOK {
+					middlewareOpts.onCSRFFailure.ServeHTTP(res, req)
+					return
+				}
+			}
+
+			curSession, verifiedNonce := lookupSession(reg, req)
+			if curSession != nil && sessionToken != "" {
+				middlewareOpts.slideSessionToken(res, curSession, sessionToken)
+			}
+			if curSession == nil {
+				curSession = ResolveSession(reg, req)
+			}
+
+			// 查找会话之后再构造下游 context，携带签名校验结果，handler 再次获取会话时不视为重放
+			ctx := withVerifiedNonce(req.Context(), verifiedNonce)
+			if middlewareOpts.csrfEnabled {
+				ctx = context.WithValue(ctx, csrfContextKey{}, csrfToken)
+			}
+			next.ServeHTTP(res, req.WithContext(NewContext(ctx, curSession)))
+		})
+	}
+}
+
+// isHTTPSRequest 请求经 TLS 到达，或 TLS 在代理终止且代理标记了原始协议
+func isHTTPSRequest(req *http.Request) bool {
+	return req.TLS != nil || strings.EqualFold(req.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// verifyCSRF 返回当前 CSRF token，cookie 中没有时生成并写入；
+// 只有携带会话 cookie 的非安全方法请求需要提交与 cookie 一致的 token
+func (s *middlewareOptions) verifyCSRF(res http.ResponseWriter, req *http.Request, cookieSession bool) (string, bool) {
+	var csrfToken string
+	if cookie, err := req.Cookie(s.csrfCookieName); err == nil {
+		csrfToken = cookie.Value
+	}
+
+	if cookieSession && !isSafeMethod(req.Method) && (s.csrfExempt == nil || !s.csrfExempt(req)) {
+		submitted := req.Header.Get(s.csrfHeaderName)
+		if submitted == "" && s.csrfFormField != "" {
+			submitted = req.PostFormValue(s.csrfFormField)
+		}
+		if csrfToken == "" || subtle.ConstantTimeCompare([]byte(csrfToken), []byte(submitted)) != 1 {
+			return "", false
+		}
+	}
+
+	if csrfToken == "" {
+		csrfToken = createUUID()
+		// 页面脚本需要读取该 cookie 并回填 header，因此不能设置 HttpOnly
+		http.SetCookie(res, &http.Cookie{
+			Name:     s.csrfCookieName,
+			Value:    csrfToken,
+			Path:     "/",
+			Secure:   s.csrfSecure(req),
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+
+	return csrfToken, true
+}
+
+// slideSessionToken cookie 中 token 的剩余有效期小于刷新窗口时重新签发
+func (s *middlewareOptions) slideSessionToken(res http.ResponseWriter, curSession Session, sessionToken string) {
+	refreshWindow := s.refreshWindow
+	if refreshWindow <= 0 {
+		refreshWindow = GetSessionTimeOutValue() / 2
+	}
+
+	// token 已在 LookupSession 中完成验签，这里只读取其中的过期时间
+	claims := jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(sessionToken, claims); err != nil {
+		return
+	}
+	tokenSession := &sessionImpl{context: claims}
+	expireTime := tokenSession.getExpireTime()
+	if time.Until(time.UnixMilli(expireTime)) >= refreshWindow {
+		return
+	}
+
+	sigVal, sigErr := curSession.Signature()
+	if sigErr != nil {
+		slog.Warn("re-issue session token failed", "session", curSession.ID(), "error", sigErr)
+		return
+	}
+	WriteSessionTokenToCookie(res, sigVal)
+}
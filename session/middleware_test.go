@@ -0,0 +1,202 @@
+package session
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newMiddlewareTestHandler(t *testing.T, registry Registry, opts ...MiddlewareOption) http.Handler {
+	t.Helper()
+
+	return Middleware(registry, opts...)(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		curSession, ok := FromRequest(req)
+		if !ok {
+			t.Errorf("expected session in request context")
+		}
+		nameVal, _ := ContextValue[string](req.Context(), "name")
+		res.Header().Set("X-Session-ID", curSession.ID())
+		res.Header().Set("X-Session-Name", nameVal)
+		res.Header().Set("X-CSRF", CSRFTokenFromContext(req.Context()))
+	}))
+}
+
+func newCookieSessionToken(t *testing.T, registry Registry, expireIn time.Duration) string {
+	t.Helper()
+
+	sessionPtr := registry.(*sessionRegistryImpl).insertSession(&sessionImpl{
+		id:       "cookie-session",
+		context:  map[string]any{"name": "demo"},
+		observer: map[string]Observer{},
+	})
+	sessionPtr.SetOption(innerExpireTime, time.Now().Add(expireIn).UTC().UnixMilli())
+	token, err := sessionPtr.Signature()
+	if err != nil {
+		t.Fatalf("signature failed: %v", err)
+	}
+	return token
+}
+
+func findCookie(res *http.Response, name string) *http.Cookie {
+	for _, val := range res.Cookies() {
+		if val.Name == name {
+			return val
+		}
+	}
+	return nil
+}
+
+func TestMiddlewareResolvesSession(t *testing.T) {
+	registry := NewRegistryWithOptions(nil)
+	defer registry.Release()
+	handler := newMiddlewareTestHandler(t, registry)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+	assert.NotEmpty(t, recorder.Header().Get("X-Session-ID"))
+	csrfCookie := findCookie(recorder.Result(), DefaultCSRFCookieName)
+	if assert.NotNil(t, csrfCookie) {
+		assert.False(t, csrfCookie.HttpOnly)
+		assert.Equal(t, csrfCookie.Value, recorder.Header().Get("X-CSRF"))
+	}
+
+	token := newCookieSessionToken(t, registry, time.Hour)
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	req.AddCookie(&http.Cookie{Name: SessionToken, Value: token})
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	assert.Equal(t, "cookie-session", recorder.Header().Get("X-Session-ID"))
+	assert.Equal(t, "demo", recorder.Header().Get("X-Session-Name"))
+	// 剩余有效期充足时不重新签发
+	assert.Nil(t, findCookie(recorder.Result(), SessionToken))
+}
+
+func TestMiddlewareSlidingReissue(t *testing.T) {
+	registry := NewRegistryWithOptions(nil)
+	defer registry.Release()
+	handler := newMiddlewareTestHandler(t, registry, WithRefreshWindow(5*time.Minute))
+
+	token := newCookieSessionToken(t, registry, time.Minute)
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	req.AddCookie(&http.Cookie{Name: SessionToken, Value: token})
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	sessionCookie := findCookie(recorder.Result(), SessionToken)
+	if assert.NotNil(t, sessionCookie) {
+		assert.NotEqual(t, token, sessionCookie.Value)
+		reissued := decodeJWT(sessionCookie.Value)
+		if assert.NotNil(t, reissued) {
+			assert.Equal(t, "cookie-session", reissued.id)
+		}
+	}
+}
+
+func TestMiddlewareCSRF(t *testing.T) {
+	registry := NewRegistryWithOptions(nil)
+	defer registry.Release()
+	handler := newMiddlewareTestHandler(t, registry, WithCSRFExempt(func(req *http.Request) bool {
+		return req.URL.Path == "/callback"
+	}))
+	token := newCookieSessionToken(t, registry, time.Hour)
+
+	newPost := func(path, csrfCookie, csrfHeader string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "http://example.com"+path, nil)
+		req.AddCookie(&http.Cookie{Name: SessionToken, Value: token})
+		if csrfCookie != "" {
+			req.AddCookie(&http.Cookie{Name: DefaultCSRFCookieName, Value: csrfCookie})
+		}
+		if csrfHeader != "" {
+			req.Header.Set(DefaultCSRFHeaderName, csrfHeader)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	assert.Equal(t, http.StatusForbidden, newPost("/orders", "", "").Code)
+	assert.Equal(t, http.StatusForbidden, newPost("/orders", "abc", "").Code)
+	assert.Equal(t, http.StatusForbidden, newPost("/orders", "abc", "xyz").Code)
+	assert.Equal(t, http.StatusOK, newPost("/orders", "abc", "abc").Code)
+	assert.Equal(t, http.StatusOK, newPost("/callback", "", "").Code)
+
+	// Bearer token 不依赖 cookie，不做 CSRF 校验
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/orders", nil)
+	req.Header.Set(Authorization, "Bearer "+token)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "cookie-session", recorder.Header().Get("X-Session-ID"))
+}
+
+func TestMiddlewareCSRFCookieSecure(t *testing.T) {
+	registry := NewRegistryWithOptions(nil)
+	defer registry.Release()
+
+	csrfCookie := func(handler http.Handler, target string, header http.Header) *http.Cookie {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return findCookie(recorder.Result(), DefaultCSRFCookieName)
+	}
+
+	// 默认按请求协议设置 Secure，非 TLS 部署中浏览器仍能保存 cookie
+	handler := newMiddlewareTestHandler(t, registry)
+	if cookie := csrfCookie(handler, "http://example.com/", nil); assert.NotNil(t, cookie) {
+		assert.False(t, cookie.Secure)
+	}
+	if cookie := csrfCookie(handler, "https://example.com/", nil); assert.NotNil(t, cookie) {
+		assert.True(t, cookie.Secure)
+	}
+	if cookie := csrfCookie(handler, "http://example.com/", http.Header{"X-Forwarded-Proto": {"https"}}); assert.NotNil(t, cookie) {
+		assert.True(t, cookie.Secure)
+	}
+
+	handler = newMiddlewareTestHandler(t, registry, WithCSRFCookieSecure(true))
+	if cookie := csrfCookie(handler, "http://example.com/", nil); assert.NotNil(t, cookie) {
+		assert.True(t, cookie.Secure)
+	}
+	handler = newMiddlewareTestHandler(t, registry, WithCSRFCookieSecure(false))
+	if cookie := csrfCookie(handler, "https://example.com/", nil); assert.NotNil(t, cookie) {
+		assert.False(t, cookie.Secure)
+	}
+}
+
+func TestMiddlewareSignedRequestLookupAgain(t *testing.T) {
+	registry := NewRegistryWithOptions(nil)
+	defer registry.Release()
+
+	authSecret := newSignedTestSecret(t)
+	handler := Middleware(registry)(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		// handler 再次获取会话不视为重放
+		curSession := LookupSession(registry, req)
+		if curSession == nil {
+			return
+		}
+		nameVal, _ := curSession.GetString("name")
+		res.Header().Set("X-Session-Name", nameVal)
+		res.Header().Set("X-Same-Session", fmt.Sprint(registry.GetSession(res, req).ID() == curSession.ID()))
+		body, _ := io.ReadAll(req.Body)
+		res.Header().Set("X-Body", string(body))
+	}))
+
+	req := newSignedRequest(t, authSecret, `{"id":1}`)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	assert.Equal(t, "billing", recorder.Header().Get("X-Session-Name"))
+	assert.Equal(t, `{"id":1}`, recorder.Header().Get("X-Body"))
+	assert.Equal(t, "true", recorder.Header().Get("X-Same-Session"))
+
+	// 重放的请求在 handler 中仍然没有会话
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, replayRequest(req, `{"id":1}`))
+	assert.Empty(t, recorder.Header().Get("X-Session-Name"))
+}
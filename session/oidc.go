@@ -0,0 +1,508 @@
+package session
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// AuthOIDCSession 外部 OpenID Connect 身份提供方签发的 ID token
+	AuthOIDCSession = "oidc"
+
+	// OIDCSubject OIDC 会话中保存 sub 声明的键
+	OIDCSubject = "sub"
+
+	oidcDiscoveryPath = "/.well-known/openid-configuration"
+
+	DefaultOIDCJWKSCacheTTL = time.Hour
+	DefaultOIDCClockSkew    = time.Minute
+
+	// oidcMinRefreshInterval 遇到未知 kid 时重新拉取 JWKS 的最小间隔，避免被伪造 kid 放大请求
+	oidcMinRefreshInterval = 10 * time.Second
+	oidcMaxResponseSize    = 1 << 20
+)
+
+var (
+	ErrOIDCIssuerMismatch   = errors.New("oidc issuer mismatch")
+	ErrOIDCAudienceMismatch = errors.New("oidc audience mismatch")
+	ErrOIDCNonceMismatch    = errors.New("oidc nonce mismatch")
+	ErrOIDCTokenExpired     = errors.New("oidc token expired")
+)
+
+// oidcProtocolClaims 协议字段，不写入会话
+var oidcProtocolClaims = map[string]bool{
+	"iss": true, "aud": true, "exp": true, "iat": true, "nbf": true,
+	"nonce": true, "azp": true, "at_hash": true, "c_hash": true, "auth_time": true, "sid": true, "jti": true,
+}
+
+// OIDCDiscovery OpenID Connect discovery 文档
+type OIDCDiscovery struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserinfoEndpoint      string   `json:"userinfo_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	SigningAlgorithms     []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// OIDCToken 授权码换取的 token
+type OIDCToken struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	IDToken      string `json:"id_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// OIDCOption OIDCProvider 配置项
+type OIDCOption func(*OIDCProvider)
+
+// WithOIDCCredentials 指定授权码流程使用的 client secret 和回调地址
+func WithOIDCCredentials(clientSecret, redirectURL string) OIDCOption {
+	return func(o *OIDCProvider) {
+		o.clientSecret = clientSecret
+		o.redirectURL = redirectURL
+	}
+}
+
+// WithOIDCScopes 指定授权请求的 scope，默认 openid profile email
+func WithOIDCScopes(scopes ...string) OIDCOption {
+	return func(o *OIDCProvider) {
+		if len(scopes) > 0 {
+			o.scopes = scopes
+		}
+	}
+}
+
+// WithOIDCHTTPClient 指定访问身份提供方使用的 http.Client
+func WithOIDCHTTPClient(client *http.Client) OIDCOption {
+	return func(o *OIDCProvider) {
+		if client != nil {
+			o.httpClient = client
+		}
+	}
+}
+
+// WithOIDCJWKSCacheTTL 指定 JWKS 缓存时间
+func WithOIDCJWKSCacheTTL(ttl time.Duration) OIDCOption {
+	return func(o *OIDCProvider) {
+		if ttl > 0 {
+			o.jwksTTL = ttl
+		}
+	}
+}
+
+// WithOIDCClockSkew 指定校验 exp/nbf/iat 允许的时钟偏差
+func WithOIDCClockSkew(skew time.Duration) OIDCOption {
+	return func(o *OIDCProvider) {
+		if skew >= 0 {
+			o.clockSkew = skew
+		}
+	}
+}
+
+// WithOIDCClaimMapping 指定 ID token 声明到会话键的映射，未指定时写入全部非协议声明
+func WithOIDCClaimMapping(mapping map[string]string) OIDCOption {
+	return func(o *OIDCProvider) {
+		o.claimMapping = mapping
+	}
+}
+
+// OIDCProvider OpenID Connect 身份提供方，负责 discovery、JWKS 缓存和 ID token 校验
+type OIDCProvider struct {
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	scopes       []string
+	claimMapping map[string]string
+	httpClient   *http.Client
+	jwksTTL      time.Duration
+	clockSkew    time.Duration
+	discovery    OIDCDiscovery
+
+	keysLock      sync.RWMutex
+	keys          map[string]*SigningKey
+	keysFetchedAt time.Time
+	keysGroup     singleflight.Group
+}
+
+// NewOIDCProvider 读取 issuer 的 discovery 文档并加载 JWKS
+func NewOIDCProvider(ctx context.Context, issuer, clientID string, opts ...OIDCOption) (*OIDCProvider, error) {
+	if issuer == "" || clientID == "" {
+		return nil, fmt.Errorf("oidc issuer and client id are required")
+	}
+
+	provider := &OIDCProvider{
+		clientID:   clientID,
+		scopes:     []string{"openid", "profile", "email"},
+		httpClient: http.DefaultClient,
+		jwksTTL:    DefaultOIDCJWKSCacheTTL,
+		clockSkew:  DefaultOIDCClockSkew,
+		keys:       map[string]*SigningKey{},
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(provider)
+		}
+	}
+
+	if err := provider.getJSON(ctx, strings.TrimSuffix(issuer, "/")+oidcDiscoveryPath, &provider.discovery); err != nil {
+		return nil, fmt.Errorf("fetch oidc discovery failed: %w", err)
+	}
+	if provider.discovery.Issuer != issuer {
+		return nil, fmt.Errorf("%w: expect %s, discovery %s", ErrOIDCIssuerMismatch, issuer, provider.discovery.Issuer)
+	}
+	if provider.discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc discovery missing jwks_uri")
+	}
+	if err := provider.refreshKeys(ctx); err != nil {
+		return nil, err
+	}
+
+	return provider, nil
+}
+
+// Issuer 返回身份提供方标识
+func (s *OIDCProvider) Issuer() string {
+	return s.discovery.Issuer
+}
+
+// Discovery 返回 discovery 文档
+func (s *OIDCProvider) Discovery() OIDCDiscovery {
+	return s.discovery
+}
+
+// AuthCodeURL 返回授权码流程的登录地址，state 和 nonce 由调用方生成并保存
+func (s *OIDCProvider) AuthCodeURL(state, nonce string) string {
+	values := url.Values{}
+	values.Set("response_type", "code")
+	values.Set("client_id", s.clientID)
+	values.Set("scope", strings.Join(s.scopes, " "))
+	values.Set("state", state)
+	if s.redirectURL != "" {
+		values.Set("redirect_uri", s.redirectURL)
+	}
+	if nonce != "" {
+		values.Set("nonce", nonce)
+	}
+
+	separator := "?"
+	if strings.Contains(s.discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return s.discovery.AuthorizationEndpoint + separator + values.Encode()
+}
+
+// Exchange 使用授权码换取 token
+func (s *OIDCProvider) Exchange(ctx context.Context, code string) (*OIDCToken, error) {
+	if s.discovery.TokenEndpoint == "" {
+		return nil, fmt.Errorf("oidc discovery missing token_endpoint")
+	}
+
+	values := url.Values{}
+	values.Set("grant_type", "authorization_code")
+	values.Set("code", code)
+	values.Set("client_id", s.clientID)
+	if s.clientSecret != "" {
+		values.Set("client_secret", s.clientSecret)
+	}
+	if s.redirectURL != "" {
+		values.Set("redirect_uri", s.redirectURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.discovery.TokenEndpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	token := &OIDCToken{}
+	if err = s.doJSON(req, token); err != nil {
+		return nil, fmt.Errorf("exchange oidc code failed: %w", err)
+	}
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("oidc token response missing id_token")
+	}
+	return token, nil
+}
+
+// VerifyIDToken 校验 ID token 的签名、issuer、audience、有效期，nonce 非空时同时校验 nonce
+func (s *OIDCProvider) VerifyIDToken(ctx context.Context, rawToken, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation())
+	_, err := parser.ParseWithClaims(rawToken, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, keyErr := s.verificationKey(ctx, kid)
+		if keyErr != nil {
+			return nil, keyErr
+		}
+		if token.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return key.verifyKey(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.validateClaims(claims, nonce); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (s *OIDCProvider) validateClaims(claims jwt.MapClaims, nonce string) error {
+	if issuer, _ := claims["iss"].(string); issuer != s.discovery.Issuer {
+		return ErrOIDCIssuerMismatch
+	}
+
+	var audiences []string
+	switch val := claims["aud"].(type) {
+	case string:
+		audiences = []string{val}
+	case []any:
+		for _, item := range val {
+			if audVal, ok := item.(string); ok {
+				audiences = append(audiences, audVal)
+			}
+		}
+	}
+	audOK := false
+	for _, val := range audiences {
+		audOK = audOK || val == s.clientID
+	}
+	if !audOK {
+		return ErrOIDCAudienceMismatch
+	}
+	// 多个 audience 时 azp 必须是当前 client
+	if azp, ok := claims["azp"].(string); (ok || len(audiences) > 1) && azp != s.clientID {
+		return ErrOIDCAudienceMismatch
+	}
+
+	nowTime := time.Now().Unix()
+	skew := int64(s.clockSkew / time.Second)
+	if !claims.VerifyExpiresAt(nowTime-skew, true) {
+		return ErrOIDCTokenExpired
+	}
+	if !claims.VerifyNotBefore(nowTime+skew, false) || !claims.VerifyIssuedAt(nowTime+skew, false) {
+		return fmt.Errorf("oidc token used before issued")
+	}
+
+	if nonce != "" {
+		if nonceVal, _ := claims["nonce"].(string); nonceVal != nonce {
+			return ErrOIDCNonceMismatch
+		}
+	}
+	return nil
+}
+
+// verificationKey 按 kid 查找验签密钥，缓存过期或遇到未知 kid 时重新拉取 JWKS
+func (s *OIDCProvider) verificationKey(ctx context.Context, kid string) (*SigningKey, error) {
+	s.keysLock.RLock()
+	key, ok := s.lookupKey(kid)
+	fetchedAt := s.keysFetchedAt
+	s.keysLock.RUnlock()
+
+	sinceFetch := time.Since(fetchedAt)
+	if (ok && sinceFetch < s.jwksTTL) || (!ok && sinceFetch < oidcMinRefreshInterval) {
+		if ok {
+			return key, nil
+		}
+		return nil, ErrSigningKeyNotFound
+	}
+
+	if err := s.refreshKeysShared(ctx); err != nil {
+		// 身份提供方不可用时继续使用已缓存的密钥
+		if ok {
+			return key, nil
+		}
+		return nil, err
+	}
+
+	s.keysLock.RLock()
+	defer s.keysLock.RUnlock()
+	if key, ok = s.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, ErrSigningKeyNotFound
+}
+
+// lookupKey 调用前必须持有 keysLock，kid 为空时只有唯一密钥才可匹配
+func (s *OIDCProvider) lookupKey(kid string) (*SigningKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, val := range s.keys {
+			return val, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// refreshKeysShared 并发请求共用一次 JWKS 拉取，距上次拉取不足 oidcMinRefreshInterval 时不再拉取
+func (s *OIDCProvider) refreshKeysShared(ctx context.Context) error {
+	_, err, _ := s.keysGroup.Do("jwks", func() (any, error) {
+		s.keysLock.RLock()
+		fetchedAt := s.keysFetchedAt
+		s.keysLock.RUnlock()
+		if time.Since(fetchedAt) < oidcMinRefreshInterval {
+			return nil, nil
+		}
+		return nil, s.refreshKeys(ctx)
+	})
+	return err
+}
+
+func (s *OIDCProvider) refreshKeys(ctx context.Context) error {
+	keySet := jwkSet{}
+	if err := s.getJSON(ctx, s.discovery.JWKSURI, &keySet); err != nil {
+		s.keysLock.Lock()
+		s.keysFetchedAt = time.Now()
+		s.keysLock.Unlock()
+		return fmt.Errorf("fetch oidc jwks failed: %w", err)
+	}
+
+	keys := map[string]*SigningKey{}
+	for _, val := range keySet.Keys {
+		if val.Use != "" && val.Use != "sig" {
+			continue
+		}
+		key, keyErr := fromJWK(val)
+		if keyErr != nil {
+			// 不支持的密钥类型不影响其它密钥
+			continue
+		}
+		keys[key.KID] = key
+	}
+
+	s.keysLock.Lock()
+	s.keys = keys
+	s.keysFetchedAt = time.Now()
+	s.keysLock.Unlock()
+	return nil
+}
+
+func (s *OIDCProvider) getJSON(ctx context.Context, urlVal string, result any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlVal, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	return s.doJSON(req, result)
+}
+
+func (s *OIDCProvider) doJSON(req *http.Request, result any) error {
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, oidcMaxResponseSize))
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d: %s", res.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, result)
+}
+
+// oidcReservedKey 会话内部字段，不从 IdP 声明复制
+func oidcReservedKey(key string) bool {
+	return strings.HasPrefix(key, "_") || key == innerExpireTime || key == InnerStartTime || key == AuthExpireTime
+}
+
+// newSession 按 ID token 声明构造会话，同一 IdP 会话的 token 映射到同一会话ID
+func (s *OIDCProvider) newSession(claims jwt.MapClaims) *sessionImpl {
+	subject, _ := claims["sub"].(string)
+	sessionKey, _ := claims["sid"].(string)
+	if sessionKey == "" {
+		sessionKey = fmt.Sprintf("%v", claims["iat"])
+	}
+	hashVal := sha256.Sum256([]byte(s.discovery.Issuer + "|" + subject + "|" + sessionKey))
+
+	context := map[string]any{}
+	if len(s.claimMapping) > 0 {
+		for claim, key := range s.claimMapping {
+			if val, ok := claims[claim]; ok && !oidcReservedKey(key) {
+				context[key] = val
+			}
+		}
+	} else {
+		for k, v := range claims {
+			if !oidcProtocolClaims[k] && !oidcReservedKey(k) {
+				context[k] = v
+			}
+		}
+	}
+	// 内部字段在声明之后写入，IdP 声明不能覆盖
+	context[InnerAuthType] = AuthOIDCSession
+	context[OIDCSubject] = subject
+	if tokenID, ok := claims["jti"].(string); ok {
+		context[InnerTokenID] = tokenID
+	}
+	if issuedAt, ok := claims["iat"].(float64); ok {
+		context[innerIssuedAt] = int64(issuedAt) * 1000
+	}
+	if expireAt, ok := claims["exp"].(float64); ok {
+		context[AuthExpireTime] = int64(expireAt) * 1000
+	}
+
+	return &sessionImpl{id: hex.EncodeToString(hashVal[:16]), context: context, observer: map[string]Observer{}}
+}
+
+// decodeOIDCToken 校验 Bearer 携带的 ID token，API 调用不校验 nonce
+func (s *sessionRegistryImpl) decodeOIDCToken(req *http.Request, rawToken string) *sessionImpl {
+	if s.oidcProvider == nil {
+		return nil
+	}
+
+	claims, err := s.oidcProvider.VerifyIDToken(req.Context(), rawToken, "")
+	if err != nil {
+		return nil
+	}
+	return s.oidcProvider.newSession(claims)
+}
+
+// NewOIDCSession 登录回调中校验 ID token 和 nonce 并创建会话，
+// 调用方可随后通过 Session.Signature 签发本系统的会话 token
+func NewOIDCSession(ctx context.Context, reg Registry, provider *OIDCProvider, rawIDToken, nonce string) (Session, error) {
+	impl, err := registryImplOf(reg)
+	if err != nil {
+		return nil, err
+	}
+	if provider == nil {
+		return nil, fmt.Errorf("oidc provider is nil")
+	}
+	if nonce == "" {
+		return nil, ErrOIDCNonceMismatch
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, rawIDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionPtr := impl.insertSession(provider.newSession(claims))
+	if sessionPtr.isFinal() {
+		return nil, fmt.Errorf("session rejected")
+	}
+	if impl.sessionObserver != nil {
+		sessionPtr.BindObserver(impl.sessionObserver)
+	}
+	return sessionPtr, nil
+}
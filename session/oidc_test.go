@@ -0,0 +1,305 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeOIDCProvider 进程内身份提供方
+type fakeOIDCProvider struct {
+	mu     sync.Mutex
+	server *httptest.Server
+	keys   []*SigningKey
+	idTok  string
+	// jwksHits JWKS 接口被请求的次数
+	jwksHits int
+}
+
+func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
+	t.Helper()
+
+	key, err := GenerateSigningKey(AlgorithmRS256)
+	if err != nil {
+		t.Fatalf("generate key failed: %v", err)
+	}
+
+	fake := &fakeOIDCProvider{keys: []*SigningKey{key}}
+	mux := http.NewServeMux()
+	mux.HandleFunc(oidcDiscoveryPath, func(res http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(res).Encode(OIDCDiscovery{
+			Issuer:                fake.server.URL,
+			AuthorizationEndpoint: fake.server.URL + "/authorize",
+			TokenEndpoint:         fake.server.URL + "/token",
+			JWKSURI:               fake.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(res http.ResponseWriter, _ *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		fake.jwksHits++
+		keySet := jwkSet{}
+		for _, val := range fake.keys {
+			item, _ := toJWK(val)
+			keySet.Keys = append(keySet.Keys, item)
+		}
+		_ = json.NewEncoder(res).Encode(keySet)
+	})
+	mux.HandleFunc("/token", func(res http.ResponseWriter, req *http.Request) {
+		if req.PostFormValue("code") != "auth-code" || req.PostFormValue("client_secret") != "secret" {
+			res.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(res).Encode(OIDCToken{AccessToken: "access", TokenType: "Bearer", IDToken: fake.idTok})
+	})
+	fake.server = httptest.NewServer(mux)
+	t.Cleanup(fake.server.Close)
+	return fake
+}
+
+func (s *fakeOIDCProvider) rotate(t *testing.T) {
+	key, err := GenerateSigningKey(AlgorithmRS256)
+	if err != nil {
+		t.Fatalf("generate key failed: %v", err)
+	}
+	s.mu.Lock()
+	s.keys = append([]*SigningKey{key}, s.keys...)
+	s.mu.Unlock()
+}
+
+func (s *fakeOIDCProvider) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+
+	s.mu.Lock()
+	key := s.keys[0]
+	s.mu.Unlock()
+	base := jwt.MapClaims{
+		"iss": s.server.URL,
+		"aud": "demo-client",
+		"sub": "user-1",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		base[k] = v
+	}
+	token := jwt.NewWithClaims(key.signingMethod(), base)
+	token.Header["kid"] = key.KID
+	signedVal, err := token.SignedString(key.PrivateKey)
+	if err != nil {
+		t.Fatalf("sign id token failed: %v", err)
+	}
+	return signedVal
+}
+
+func TestOIDCVerifyIDToken(t *testing.T) {
+	fake := newFakeOIDCProvider(t)
+	provider, err := NewOIDCProvider(context.Background(), fake.server.URL, "demo-client", WithOIDCClockSkew(0))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	claims, err := provider.VerifyIDToken(context.Background(), fake.sign(t, jwt.MapClaims{"nonce": "n-1"}), "n-1")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "user-1", claims["sub"])
+	}
+
+	_, err = provider.VerifyIDToken(context.Background(), fake.sign(t, jwt.MapClaims{"nonce": "n-1"}), "n-2")
+	assert.ErrorIs(t, err, ErrOIDCNonceMismatch)
+	_, err = provider.VerifyIDToken(context.Background(), fake.sign(t, jwt.MapClaims{"aud": "other"}), "")
+	assert.ErrorIs(t, err, ErrOIDCAudienceMismatch)
+	_, err = provider.VerifyIDToken(context.Background(), fake.sign(t, jwt.MapClaims{"aud": []any{"demo-client", "other"}}), "")
+	assert.ErrorIs(t, err, ErrOIDCAudienceMismatch)
+	_, err = provider.VerifyIDToken(context.Background(), fake.sign(t, jwt.MapClaims{"iss": "https://evil.example.com"}), "")
+	assert.ErrorIs(t, err, ErrOIDCIssuerMismatch)
+	_, err = provider.VerifyIDToken(context.Background(), fake.sign(t, jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}), "")
+	assert.ErrorIs(t, err, ErrOIDCTokenExpired)
+
+	// IdP 轮换密钥后，未知 kid 触发重新拉取 JWKS
+	fake.rotate(t)
+	provider.keysLock.Lock()
+	provider.keysFetchedAt = time.Now().Add(-oidcMinRefreshInterval)
+	provider.keysLock.Unlock()
+	_, err = provider.VerifyIDToken(context.Background(), fake.sign(t, nil), "")
+	assert.Nil(t, err)
+}
+
+func TestOIDCConcurrentKeyRefresh(t *testing.T) {
+	fake := newFakeOIDCProvider(t)
+	provider, err := NewOIDCProvider(context.Background(), fake.server.URL, "demo-client")
+	if !assert.Nil(t, err) {
+		return
+	}
+	jwksHits := func() int {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		return fake.jwksHits
+	}
+	assert.Equal(t, 1, jwksHits())
+
+	// 并发请求遇到同一个未知 kid 只拉取一次 JWKS
+	fake.rotate(t)
+	provider.keysLock.Lock()
+	provider.keysFetchedAt = time.Now().Add(-oidcMinRefreshInterval)
+	provider.keysLock.Unlock()
+	idToken := fake.sign(t, nil)
+	var wg sync.WaitGroup
+	for idx := 0; idx < 50; idx++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, verifyErr := provider.VerifyIDToken(context.Background(), idToken, "")
+			assert.Nil(t, verifyErr)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 2, jwksHits())
+
+	// 伪造的 kid 在最小间隔内不再触发拉取
+	forgedKey, err := GenerateSigningKey(AlgorithmRS256)
+	if !assert.Nil(t, err) {
+		return
+	}
+	forged := jwt.NewWithClaims(forgedKey.signingMethod(), jwt.MapClaims{
+		"iss": fake.server.URL,
+		"aud": "demo-client",
+		"sub": "user-1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	forged.Header["kid"] = forgedKey.KID
+	forgedToken, err := forged.SignedString(forgedKey.PrivateKey)
+	if !assert.Nil(t, err) {
+		return
+	}
+	for idx := 0; idx < 10; idx++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, verifyErr := provider.VerifyIDToken(context.Background(), forgedToken, "")
+			assert.NotNil(t, verifyErr)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 2, jwksHits())
+}
+
+func TestOIDCDiscoveryIssuerMismatch(t *testing.T) {
+	fake := newFakeOIDCProvider(t)
+	_, err := NewOIDCProvider(context.Background(), fake.server.URL+"/", "demo-client")
+	assert.ErrorIs(t, err, ErrOIDCIssuerMismatch)
+}
+
+func TestOIDCBearerSession(t *testing.T) {
+	fake := newFakeOIDCProvider(t)
+	provider, err := NewOIDCProvider(context.Background(), fake.server.URL, "demo-client",
+		WithOIDCClaimMapping(map[string]string{"email": "email"}))
+	if !assert.Nil(t, err) {
+		return
+	}
+	registry := NewRegistryWithOptions(nil, WithOIDCProvider(provider))
+	defer registry.Release()
+
+	idToken := fake.sign(t, jwt.MapClaims{"email": "demo@example.com", "sid": "idp-session"})
+	curSession := lookupByToken(registry, idToken)
+	if assert.NotNil(t, curSession) {
+		authType, _ := curSession.GetString(InnerAuthType)
+		email, _ := curSession.GetString("email")
+		subject, _ := curSession.GetString(OIDCSubject)
+		assert.Equal(t, AuthOIDCSession, authType)
+		assert.Equal(t, "demo@example.com", email)
+		assert.Equal(t, "user-1", subject)
+
+		nextSession := lookupByToken(registry, fake.sign(t, jwt.MapClaims{"sid": "idp-session"}))
+		if assert.NotNil(t, nextSession) {
+			assert.Equal(t, curSession.ID(), nextSession.ID())
+		}
+	}
+
+	assert.Nil(t, lookupByToken(registry, fake.sign(t, jwt.MapClaims{"aud": "other"})))
+}
+
+func TestOIDCClaimsCannotOverwriteInternalKeys(t *testing.T) {
+	fake := newFakeOIDCProvider(t)
+	provider, err := NewOIDCProvider(context.Background(), fake.server.URL, "demo-client")
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	forgedExpire := time.Now().Add(365 * 24 * time.Hour).UnixMilli()
+	idToken := fake.sign(t, jwt.MapClaims{
+		"email":         "demo@example.com",
+		InnerAuthType:   AuthJWTSession,
+		innerExpireTime: forgedExpire,
+		InnerStartTime:  forgedExpire,
+		InnerTokenID:    "forged-jti",
+	})
+	claims, err := provider.VerifyIDToken(context.Background(), idToken, "")
+	if !assert.Nil(t, err) {
+		return
+	}
+	delete(claims, "iat")
+
+	sessionPtr := provider.newSession(claims)
+	assert.Equal(t, AuthOIDCSession, sessionPtr.context[InnerAuthType])
+	assert.Equal(t, "demo@example.com", sessionPtr.context["email"])
+	assert.NotContains(t, sessionPtr.context, innerExpireTime)
+	assert.NotContains(t, sessionPtr.context, InnerStartTime)
+	assert.NotContains(t, sessionPtr.context, InnerTokenID)
+	assert.NotContains(t, sessionPtr.context, innerIssuedAt)
+
+	registry := NewRegistryWithOptions(nil, WithOIDCProvider(provider))
+	defer registry.Release()
+	curSession := lookupByToken(registry, idToken)
+	if assert.NotNil(t, curSession) {
+		authType, _ := curSession.GetString(InnerAuthType)
+		assert.Equal(t, AuthOIDCSession, authType)
+		expireVal, _ := curSession.GetInt(innerExpireTime)
+		assert.NotEqual(t, forgedExpire, expireVal)
+	}
+}
+
+func TestOIDCAuthorizationCodeLogin(t *testing.T) {
+	fake := newFakeOIDCProvider(t)
+	provider, err := NewOIDCProvider(context.Background(), fake.server.URL, "demo-client",
+		WithOIDCCredentials("secret", "https://app.example.com/callback"))
+	if !assert.Nil(t, err) {
+		return
+	}
+	registry := NewRegistryWithOptions(nil)
+	defer registry.Release()
+
+	loginURL, _ := url.Parse(provider.AuthCodeURL("state-1", "nonce-1"))
+	assert.Equal(t, "nonce-1", loginURL.Query().Get("nonce"))
+	assert.Equal(t, "https://app.example.com/callback", loginURL.Query().Get("redirect_uri"))
+
+	fake.idTok = fake.sign(t, jwt.MapClaims{"nonce": "nonce-1", "name": "demo"})
+	token, err := provider.Exchange(context.Background(), "auth-code")
+	if !assert.Nil(t, err) {
+		return
+	}
+	_, err = NewOIDCSession(context.Background(), registry, provider, token.IDToken, "nonce-2")
+	assert.ErrorIs(t, err, ErrOIDCNonceMismatch)
+
+	curSession, err := NewOIDCSession(context.Background(), registry, provider, token.IDToken, "nonce-1")
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// 登录后使用本系统签发的会话 token
+	sessionToken, err := curSession.Signature()
+	assert.Nil(t, err)
+	lookupSession := lookupByToken(registry, sessionToken)
+	if assert.NotNil(t, lookupSession) {
+		assert.Equal(t, curSession.ID(), lookupSession.ID())
+		nameVal, _ := lookupSession.GetString("name")
+		assert.Equal(t, "demo", nameVal)
+	}
+}
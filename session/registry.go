@@ -1,9 +1,12 @@
 package session
 
 import (
-	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"maps"
 	"net/http"
 	"os"
+	"reflect"
 	"strconv"
 	"strings"
 	"sync"
@@ -20,13 +23,9 @@ const (
 	SESSION_TIMEOUT_VALUE_KEY = "SESSION_TIMEOUT_VALUE"
 )
 
-func getSecret() string {
-	secretVal := os.Getenv(HMAC_SECRET_KEY)
-	if secretVal != "" {
-		return secretVal
-	}
-
-	return hmacSecretDefault
+// getSecret 返回 endpoint 加密使用的密钥，生产模式下未配置 HMAC_SECRET 时返回错误
+func getSecret() (string, error) {
+	return lookupSecret()
 }
 
 func ReadSessionTokenFromCookie(req *http.Request) string {
@@ -86,20 +85,26 @@ type Registry interface {
 }
 
 func LookupSession(reg Registry, req *http.Request) Session {
+	curSession, _ := lookupSession(reg, req)
+	return curSession
+}
+
+// lookupSession 查找会话，同时返回签名请求校验通过的 nonce，供调用方写入下游请求的 context
+func lookupSession(reg Registry, req *http.Request) (Session, string) {
 	if reg == nil || req == nil {
-		return nil
+		return nil, ""
 	}
 
 	impl, ok := reg.(*sessionRegistryImpl)
 	if !ok {
-		return nil
+		return nil, ""
 	}
 
-	curSession := impl.getSession(req)
+	curSession, verifiedNonce := impl.getSession(req)
 	if curSession == nil {
-		return nil
+		return nil, ""
 	}
-	return curSession
+	return curSession, verifiedNonce
 }
 
 func ResolveSession(reg Registry, req *http.Request) Session {
@@ -116,12 +121,157 @@ func createUUID() string {
 	return util.RandomAlphanumeric(32)
 }
 
+// createSecureToken 使用 crypto/rand 生成 size 字节随机数并以 URL 安全的 base64 编码，用于凭据和 nonce
+func createSecureToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// RegistryOption Registry 配置项
+type RegistryOption func(*registryOptions)
+
+type registryOptions struct {
+	store           SessionStore
+	cleanupInterval time.Duration
+	tokenStore      TokenStore
+	userClaim       string
+	refreshTokenTTL time.Duration
+	nonceCache      NonceCache
+	signatureSkew   time.Duration
+	signedBodyLimit int64
+	signedOnly      bool
+	auditSink       AuditSink
+	sessionLimit    int
+	limitPolicy     LimitPolicy
+	oidcProvider    *OIDCProvider
+}
+
+// WithSessionStore 指定会话存储，默认使用进程内存储
+func WithSessionStore(store SessionStore) RegistryOption {
+	return func(o *registryOptions) {
+		if store != nil {
+			o.store = store
+		}
+	}
+}
+
+// WithCleanupInterval 指定会话存储的过期清理周期
+func WithCleanupInterval(interval time.Duration) RegistryOption {
+	return func(o *registryOptions) {
+		if interval > 0 {
+			o.cleanupInterval = interval
+		}
+	}
+}
+
+// WithTokenStore 指定 refresh token 和吊销列表的存储，默认使用进程内存储
+func WithTokenStore(store TokenStore) RegistryOption {
+	return func(o *registryOptions) {
+		if store != nil {
+			o.tokenStore = store
+		}
+	}
+}
+
+// WithUserClaim 指定标识用户的会话声明，按用户吊销会话时使用
+func WithUserClaim(claim string) RegistryOption {
+	return func(o *registryOptions) {
+		o.userClaim = claim
+	}
+}
+
+// WithRefreshTokenTTL 指定 refresh token 有效期
+func WithRefreshTokenTTL(ttl time.Duration) RegistryOption {
+	return func(o *registryOptions) {
+		if ttl > 0 {
+			o.refreshTokenTTL = ttl
+		}
+	}
+}
+
+// WithNonceCache 指定请求签名的 nonce 缓存，默认使用进程内缓存
+func WithNonceCache(cache NonceCache) RegistryOption {
+	return func(o *registryOptions) {
+		if cache != nil {
+			o.nonceCache = cache
+		}
+	}
+}
+
+// WithSignatureSkew 指定请求签名允许的时钟偏差
+func WithSignatureSkew(skew time.Duration) RegistryOption {
+	return func(o *registryOptions) {
+		if skew > 0 {
+			o.signatureSkew = skew
+		}
+	}
+}
+
+// WithSignedBodyLimit 指定校验请求签名时读取的最大 body 长度，超过时拒绝，默认 DefaultSignedBodyLimit
+func WithSignedBodyLimit(limit int64) RegistryOption {
+	return func(o *registryOptions) {
+		if limit > 0 {
+			o.signedBodyLimit = limit
+		}
+	}
+}
+
+// WithSignedEndpointOnly endpoint 只接受签名请求，拒绝可重放的 Sig token
+func WithSignedEndpointOnly() RegistryOption {
+	return func(o *registryOptions) {
+		o.signedOnly = true
+	}
+}
+
+// WithAuditSink 指定会话审计记录的输出
+func WithAuditSink(sink AuditSink) RegistryOption {
+	return func(o *registryOptions) {
+		o.auditSink = sink
+	}
+}
+
+// WithSessionLimit 限制每个用户的并发会话数，需要同时通过 WithUserClaim 指定用户声明
+func WithSessionLimit(limit int, policy LimitPolicy) RegistryOption {
+	return func(o *registryOptions) {
+		o.sessionLimit = limit
+		o.limitPolicy = policy
+	}
+}
+
+// WithOIDCProvider 接受外部 OpenID Connect 身份提供方签发的 Bearer ID token
+func WithOIDCProvider(provider *OIDCProvider) RegistryOption {
+	return func(o *registryOptions) {
+		o.oidcProvider = provider
+	}
+}
+
+// sessionRegistryImpl 会话仓库。
+// sessionStore 保存会话数据，是多副本之间共享的数据来源；
+// sessionMap 缓存本进程内的会话对象，用于保持 Observer 绑定和对象复用。
 type sessionRegistryImpl struct {
-	registryCancelFunc context.CancelFunc
-	registryLock       sync.RWMutex
-	sessionMap         map[string]*sessionImpl
-	sessionObserver    Observer
-	releaseOnce        sync.Once
+	registryLock    sync.RWMutex
+	sessionMap      map[string]*sessionImpl
+	sessionStore    SessionStore
+	tokenStore      TokenStore
+	userClaim       string
+	refreshTokenTTL time.Duration
+	nonceCache      NonceCache
+	signatureSkew   time.Duration
+	signedBodyLimit int64
+	signedOnly      bool
+	auditSink       AuditSink
+	sessionLimit    int
+	limitPolicy     LimitPolicy
+	oidcProvider    *OIDCProvider
+	sessionObserver Observer
+	releaseOnce     sync.Once
+
+	// verifiedRequests 同一请求多次获取会话时跳过 nonce 重放检查
+	verifiedRequests verifiedRequests
 }
 
 // DefaultRegistry 创建Session仓库
@@ -130,20 +280,51 @@ func DefaultRegistry() Registry {
 }
 
 func NewRegistry(obSvr Observer) Registry {
-	registryCtx, registryCancel := context.WithCancel(context.Background())
-	impl := sessionRegistryImpl{
+	return NewRegistryWithOptions(obSvr)
+}
+
+// NewRegistryWithOptions 创建带可选配置的Session仓库
+func NewRegistryWithOptions(obSvr Observer, opts ...RegistryOption) Registry {
+	registryOpts := &registryOptions{cleanupInterval: defaultStoreCleanupInterval, refreshTokenTTL: DefaultRefreshTokenTTL, signatureSkew: DefaultSignatureSkew, signedBodyLimit: DefaultSignedBodyLimit}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(registryOpts)
+		}
+	}
+	if registryOpts.store == nil {
+		registryOpts.store = NewMemoryStore()
+	}
+	if registryOpts.tokenStore == nil {
+		registryOpts.tokenStore = NewMemoryTokenStore()
+	}
+	if registryOpts.nonceCache == nil {
+		registryOpts.nonceCache = NewMemoryNonceCache()
+	}
+
+	impl := &sessionRegistryImpl{
 		sessionObserver: obSvr,
 		sessionMap:      map[string]*sessionImpl{},
+		sessionStore:    registryOpts.store,
+		tokenStore:      registryOpts.tokenStore,
+		userClaim:       registryOpts.userClaim,
+		refreshTokenTTL: registryOpts.refreshTokenTTL,
+		nonceCache:      registryOpts.nonceCache,
+		signatureSkew:   registryOpts.signatureSkew,
+		signedBodyLimit: registryOpts.signedBodyLimit,
+		signedOnly:      registryOpts.signedOnly,
+		auditSink:       registryOpts.auditSink,
+		sessionLimit:    registryOpts.sessionLimit,
+		limitPolicy:     registryOpts.limitPolicy,
+		oidcProvider:    registryOpts.oidcProvider,
 	}
-	impl.registryCancelFunc = registryCancel
-	go impl.checkTimer(registryCtx)
+	impl.sessionStore.StartCleanup(registryOpts.cleanupInterval, impl.onSessionExpired)
 
-	return &impl
+	return impl
 }
 
 // GetSession 获取Session对象
 func (s *sessionRegistryImpl) GetSession(res http.ResponseWriter, req *http.Request) Session {
-	sessionInfo := s.getSession(req)
+	sessionInfo, _ := s.getSession(req)
 	if sessionInfo != nil {
 		return sessionInfo
 	}
@@ -153,7 +334,12 @@ func (s *sessionRegistryImpl) GetSession(res http.ResponseWriter, req *http.Requ
 
 func (s *sessionRegistryImpl) Release() {
 	s.releaseOnce.Do(func() {
-		s.registryCancelFunc()
+		if err := s.sessionStore.Close(); err != nil {
+			slog.Warn("close session store failed", "error", err)
+		}
+		if err := s.tokenStore.Close(); err != nil {
+			slog.Warn("close token store failed", "error", err)
+		}
 	})
 }
 
@@ -161,8 +347,10 @@ func (s *sessionRegistryImpl) CountSession(filter util.Filter) int {
 	return s.count(filter)
 }
 
-func (s *sessionRegistryImpl) getSession(req *http.Request) *sessionImpl {
+// getSession 从请求中恢复会话，签名请求同时返回校验通过的 nonce
+func (s *sessionRegistryImpl) getSession(req *http.Request) (*sessionImpl, string) {
 	var sessionPtr *sessionImpl
+	var verifiedNonce string
 	func() {
 		defer func() {
 			if err := recover(); err != nil {
@@ -186,18 +374,29 @@ func (s *sessionRegistryImpl) getSession(req *http.Request) *sessionImpl {
 
 			if authorizationValue[:offset] == jwtToken {
 				sessionPtr = decodeJWT(authorizationValue[offset+1:])
+				if sessionPtr == nil {
+					sessionPtr = s.decodeOIDCToken(req, authorizationValue[offset+1:])
+				}
 			}
 
-			if authorizationValue[:offset] == sigToken {
+			if authorizationValue[:offset] == sigToken && !s.signedOnly {
 				sessionPtr = decodeEndpointToken(authorizationValue[offset+1:])
 			}
+
+			if authorizationValue[:offset] == signedToken {
+				var signErr error
+				sessionPtr, verifiedNonce, signErr = s.verifySignedRequest(req, authorizationValue[offset+1:])
+				if signErr != nil {
+					slog.Warn("verify signed request failed", "error", signErr)
+				}
+			}
 		}
 
 		if sessionPtr != nil {
 			sessionPtr.mu.RLock()
 			expireTime := sessionPtr.getExpireTime()
 			sessionPtr.mu.RUnlock()
-			if expireTime < nowTime {
+			if expireTime < nowTime || s.checkRevoked(sessionPtr) {
 				sessionPtr = nil
 			}
 		}
@@ -224,9 +423,26 @@ func (s *sessionRegistryImpl) getSession(req *http.Request) *sessionImpl {
 		sessionPtr.context[InnerRemoteAccessAddr] = fn.GetHTTPRemoteAddress(req)
 		sessionPtr.context[InnerUseAgent] = req.UserAgent()
 		sessionPtr.mu.Unlock()
+		if !s.persistSession(sessionPtr) {
+			sessionPtr = nil
+		}
 	}
 
-	return sessionPtr
+	if sessionPtr == nil {
+		return nil, ""
+	}
+	return sessionPtr, verifiedNonce
+}
+
+// signedClaims 返回参与签名的会话声明
+func signedClaims(context map[string]any) map[string]any {
+	ret := make(map[string]any, len(context))
+	for k, v := range context {
+		if !excludeSessionSignatureKey(k) && k != innerExpireTime && k != AuthExpireTime && k != InnerStartTime {
+			ret[k] = v
+		}
+	}
+	return ret
 }
 
 func (s *sessionRegistryImpl) refreshSessionClaims(target, source *sessionImpl) {
@@ -241,6 +457,13 @@ func (s *sessionRegistryImpl) refreshSessionClaims(target, source *sessionImpl)
 	}
 	source.mu.RUnlock()
 
+	target.mu.RLock()
+	claimsChanged := !reflect.DeepEqual(signedClaims(target.context), signedClaims(contextCopy))
+	target.mu.RUnlock()
+	if claimsChanged {
+		defer s.audit(AuditClaimsChanged, target.id, target, "")
+	}
+
 	target.mu.Lock()
 	remoteAccessAddr := target.context[InnerRemoteAccessAddr]
 	useAgent := target.context[InnerUseAgent]
@@ -281,18 +504,133 @@ func (s *sessionRegistryImpl) createSession(req *http.Request, sessionID string)
 	return sessionPtr
 }
 
+// findSession 查找会话，本地对象与存储中的数据合并；存储中已不存在的本地会话视为过期
 func (s *sessionRegistryImpl) findSession(sessionID string) *sessionImpl {
 	s.registryLock.RLock()
 	sessionPtr := s.sessionMap[sessionID]
 	s.registryLock.RUnlock()
+	if sessionPtr != nil && sessionPtr.isFinal() {
+		return sessionPtr
+	}
+
+	record, err := s.sessionStore.Load(sessionID)
+	if err != nil {
+		slog.Warn("load session from store failed", "session", sessionID, "error", err)
+		return sessionPtr
+	}
+
+	if record == nil {
+		if sessionPtr != nil {
+			s.removeSession(sessionID)
+			s.audit(AuditExpired, sessionID, sessionPtr, "")
+			go sessionPtr.terminate()
+		}
+		return nil
+	}
+
+	if sessionPtr != nil {
+		// 以存储中的数据为准，其它实例删除的字段同步删除；只保留本地尚未写入的更晚有效期
+		sessionPtr.mu.Lock()
+		localExpire, _ := sessionPtr.getInt(innerExpireTime)
+		sessionPtr.context = maps.Clone(record.Context)
+		if storedExpire, _ := sessionPtr.getInt(innerExpireTime); localExpire > storedExpire {
+			sessionPtr.context[innerExpireTime] = localExpire
+		}
+		sessionPtr.persisted = record
+		sessionPtr.mu.Unlock()
+		return sessionPtr
+	}
+
+	s.registryLock.Lock()
+	defer s.registryLock.Unlock()
+	if curSession, ok := s.sessionMap[sessionID]; ok {
+		return curSession
+	}
+
+	sessionPtr = &sessionImpl{
+		id:           record.ID,
+		context:      maps.Clone(record.Context),
+		observer:     map[string]Observer{},
+		registry:     s,
+		status:       sessionActive,
+		admittedUser: userIDOf(record.Context, s.userClaim),
+		persisted:    record,
+	}
+	s.sessionMap[sessionID] = sessionPtr
 	return sessionPtr
 }
 
+// persistSession 将会话数据写入存储，会话已结束或超过用户并发会话上限被拒绝时返回 false。
+// 数据没有变化且有效期延长不足会话超时的 1/10 时跳过写入，避免每个请求都写存储
+func (s *sessionRegistryImpl) persistSession(sessionPtr *sessionImpl) bool {
+	if !s.admitUser(sessionPtr) {
+		return false
+	}
+
+	sessionPtr.mu.Lock()
+	if sessionPtr.status == sessionTerminate {
+		sessionPtr.mu.Unlock()
+		return false
+	}
+	record := &SessionRecord{
+		ID:       sessionPtr.id,
+		Context:  maps.Clone(sessionPtr.context),
+		ExpireAt: sessionPtr.getExpireTime(),
+	}
+	if !persistRequired(sessionPtr.persisted, record) {
+		sessionPtr.mu.Unlock()
+		return true
+	}
+	sessionPtr.persisted = record
+	sessionPtr.mu.Unlock()
+
+	if err := s.sessionStore.Save(record); err != nil {
+		slog.Warn("save session to store failed", "session", record.ID, "error", err)
+		sessionPtr.mu.Lock()
+		if sessionPtr.persisted == record {
+			sessionPtr.persisted = nil
+		}
+		sessionPtr.mu.Unlock()
+	}
+	return true
+}
+
+// persistRequired 比较除 innerExpireTime 外的会话数据和有效期，有效期以 ExpireAt 比较
+func persistRequired(persisted, record *SessionRecord) bool {
+	if persisted == nil || len(persisted.Context) != len(record.Context) {
+		return true
+	}
+	for k, v := range record.Context {
+		if k == innerExpireTime {
+			continue
+		}
+		if storedVal, ok := persisted.Context[k]; !ok || !reflect.DeepEqual(storedVal, v) {
+			return true
+		}
+	}
+	// 有效期缩短时立即写入
+	extended := record.ExpireAt - persisted.ExpireAt
+	return extended < 0 || extended >= GetSessionTimeOutValue().Milliseconds()/10
+}
+
+// onSessionExpired 存储清理过期会话后，结束本地会话对象并通知 Observer
+func (s *sessionRegistryImpl) onSessionExpired(sessionID string) {
+	s.registryLock.Lock()
+	sessionPtr, ok := s.sessionMap[sessionID]
+	delete(s.sessionMap, sessionID)
+	s.registryLock.Unlock()
+
+	if ok {
+		s.audit(AuditExpired, sessionID, sessionPtr, "")
+		go sessionPtr.terminate()
+		return
+	}
+	s.audit(AuditExpired, sessionID, nil, "")
+}
+
 func (s *sessionRegistryImpl) insertSession(sessionPtr *sessionImpl) *sessionImpl {
 	sessionPtr.registry = s
 	s.registryLock.Lock()
-	defer s.registryLock.Unlock()
-
 	curSession, curOK := s.sessionMap[sessionPtr.id]
 	if !curOK {
 		curSession = &sessionImpl{
@@ -305,7 +643,12 @@ func (s *sessionRegistryImpl) insertSession(sessionPtr *sessionImpl) *sessionImp
 		curSession.context[InnerStartTime] = time.Now().UTC().UnixMilli()
 		s.sessionMap[sessionPtr.id] = curSession
 	}
+	s.registryLock.Unlock()
+
 	curSession.refresh()
+	if s.persistSession(curSession) && !curOK {
+		s.audit(AuditCreated, curSession.id, curSession, "")
+	}
 	return curSession
 }
 
@@ -323,6 +666,7 @@ func (s *sessionRegistryImpl) updateSession(sessionPtr *sessionImpl) bool {
 	}
 
 	if curSession == sessionPtr {
+		s.persistSession(curSession)
 		return true
 	}
 
@@ -343,49 +687,31 @@ func (s *sessionRegistryImpl) updateSession(sessionPtr *sessionImpl) bool {
 	curSession.observer = observerCopy
 	curSession.status = statusVal
 	curSession.mu.Unlock()
+	s.persistSession(curSession)
 	return true
 }
 
-func (s *sessionRegistryImpl) checkTimer(ctx context.Context) {
-	timeOutTimer := time.NewTicker(5 * time.Second)
-	defer timeOutTimer.Stop() // 确保在函数退出时停止定时器
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-timeOutTimer.C:
-			var removeList []*sessionImpl
-
-			s.registryLock.Lock()
-			for id, val := range s.sessionMap {
-				if val.timeout() {
-					removeList = append(removeList, val)
-					delete(s.sessionMap, id)
-				}
-			}
-			s.registryLock.Unlock()
-
-			for _, val := range removeList {
-				go val.terminate()
-			}
-		}
-	}
-}
-
 func (s *sessionRegistryImpl) count(filter util.Filter) int {
-	s.registryLock.RLock()
-	defer s.registryLock.RUnlock()
-
-	if filter == nil {
-		return len(s.sessionMap)
-	}
-
 	count := 0
-	for _, val := range s.sessionMap {
-		if filter.Filter(val) {
+	err := s.sessionStore.Range(func(record *SessionRecord) bool {
+		if filter == nil {
+			count++
+			return true
+		}
+
+		s.registryLock.RLock()
+		sessionPtr, ok := s.sessionMap[record.ID]
+		s.registryLock.RUnlock()
+		if !ok {
+			sessionPtr = &sessionImpl{id: record.ID, context: record.Context, observer: map[string]Observer{}, status: sessionActive}
+		}
+		if filter.Filter(sessionPtr) {
 			count++
 		}
+		return true
+	})
+	if err != nil {
+		slog.Warn("count sessions failed", "error", err)
 	}
 	return count
 }
@@ -394,4 +720,8 @@ func (s *sessionRegistryImpl) removeSession(sessionID string) {
 	s.registryLock.Lock()
 	delete(s.sessionMap, sessionID)
 	s.registryLock.Unlock()
+
+	if err := s.sessionStore.Delete(sessionID); err != nil {
+		slog.Warn("delete session from store failed", "session", sessionID, "error", err)
+	}
 }
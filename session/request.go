@@ -0,0 +1,311 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	cd "github.com/muidea/magicCommon/def"
+)
+
+// Request 类型化 HTTP 请求，自动携带 BaseClient 的 Context header 和认证信息，响应按 JSON 解码为 T。
+// 幂等请求在网络错误、429、502、503、504 时按 RetryPolicy 重试；每个 host 使用独立熔断器；
+// 超时和取消通过 Do 的 context 控制。
+type Request[T any] struct {
+	client     *BaseClient
+	method     string
+	path       string
+	query      url.Values
+	header     http.Header
+	body       any
+	idempotent bool
+}
+
+// NewRequest 创建请求，path 为相对 ServerURL 的路径或完整 URL
+func NewRequest[T any](client *BaseClient, method, path string) *Request[T] {
+	return &Request[T]{
+		client: client,
+		method: strings.ToUpper(method),
+		path:   path,
+		query:  url.Values{},
+		header: http.Header{},
+	}
+}
+
+// Query 添加查询参数
+func (r *Request[T]) Query(key, value string) *Request[T] {
+	r.query.Add(key, value)
+	return r
+}
+
+// QueryValues 添加多个查询参数
+func (r *Request[T]) QueryValues(values url.Values) *Request[T] {
+	for key, items := range values {
+		for _, item := range items {
+			r.query.Add(key, item)
+		}
+	}
+	return r
+}
+
+// Header 设置请求 header，覆盖 Context 中的同名 header
+func (r *Request[T]) Header(key, value string) *Request[T] {
+	r.header.Set(key, value)
+	return r
+}
+
+// Body 设置请求参数，按 JSON 编码
+func (r *Request[T]) Body(param any) *Request[T] {
+	r.body = param
+	return r
+}
+
+// Idempotent 声明非幂等方法的请求可以安全重试，例如携带幂等键的 POST
+func (r *Request[T]) Idempotent() *Request[T] {
+	r.idempotent = true
+	return r
+}
+
+func (r *Request[T]) retriable() bool {
+	switch r.method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return r.idempotent
+}
+
+func (r *Request[T]) buildURL() (*url.URL, error) {
+	rawURL := r.path
+	if !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {
+		rawURL = strings.TrimSuffix(r.client.GetServerURL(), "/") + "/" + strings.TrimPrefix(r.path, "/")
+	}
+
+	reqURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if reqURL.Host == "" {
+		return nil, fmt.Errorf("illegal request url: %s", rawURL)
+	}
+	if len(r.query) > 0 {
+		values := reqURL.Query()
+		for key, items := range r.query {
+			values[key] = append(values[key], items...)
+		}
+		reqURL.RawQuery = values.Encode()
+	}
+	return reqURL, nil
+}
+
+// Do 发送请求，返回解码后的结果；响应实现 cd.ResultWithError 且携带错误时同时返回该错误
+func (r *Request[T]) Do(ctx context.Context) (T, *cd.Error) {
+	var ret T
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if r.client == nil {
+		return ret, cd.NewError(cd.InvalidOperation, "client is nil")
+	}
+	httpClient := r.client.GetHTTPClient()
+	if httpClient == nil {
+		return ret, cd.NewError(cd.InvalidOperation, "client is released")
+	}
+
+	reqURL, err := r.buildURL()
+	if err != nil {
+		return ret, cd.NewError(cd.IllegalParam, err.Error())
+	}
+	var payload []byte
+	if r.body != nil {
+		if payload, err = json.Marshal(r.body); err != nil {
+			return ret, cd.NewError(cd.IllegalParam, fmt.Sprintf("marshal request body failed: %v", err))
+		}
+	}
+
+	policy := DefaultRetryPolicy()
+	if r.client.retryPolicy != nil {
+		policy = *r.client.retryPolicy
+	}
+	maxAttempts := 1
+	if r.retriable() {
+		maxAttempts = max(policy.MaxAttempts, 1)
+	}
+	breakers := r.client.breakers
+	if breakers == nil {
+		breakers = defaultBreakers
+	}
+	breaker := breakers.get(reqURL.Host)
+
+	for attempt := 1; ; attempt++ {
+		if !breaker.allow() {
+			return ret, cd.NewError(cd.ServiceUnavailable, fmt.Sprintf("circuit breaker is open for %s", reqURL.Host))
+		}
+
+		res, content, resErr := r.execute(ctx, httpClient, reqURL, payload)
+		var lastErr *cd.Error
+		retryable := false
+		switch {
+		case resErr != nil && ctx.Err() != nil:
+			breaker.release()
+			return ret, contextError(ctx)
+		case resErr != nil:
+			breaker.record(false)
+			lastErr = cd.NewError(cd.NetworkError, resErr.Error())
+			retryable = true
+		default:
+			breaker.record(res.StatusCode < http.StatusInternalServerError)
+			if res.StatusCode >= http.StatusOK && res.StatusCode < http.StatusMultipleChoices {
+				return decodeResponse[T](content)
+			}
+			lastErr = statusError(res.StatusCode, content)
+			retryable = retryableStatus(res.StatusCode)
+		}
+
+		if !retryable || attempt >= maxAttempts {
+			return ret, lastErr
+		}
+
+		timer := time.NewTimer(policy.backoff(attempt, res))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ret, contextError(ctx)
+		case <-timer.C:
+		}
+	}
+}
+
+func (r *Request[T]) execute(ctx context.Context, httpClient *http.Client, reqURL *url.URL, payload []byte) (*http.Response, []byte, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, reqURL.String(), body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for key, items := range r.client.GetContextValues() {
+		req.Header[http.CanonicalHeaderKey(key)] = items
+	}
+	for key, items := range r.header {
+		req.Header[key] = items
+	}
+	if payload != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	content, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, content, nil
+}
+
+func decodeResponse[T any](content []byte) (T, *cd.Error) {
+	var ret T
+	if len(bytes.TrimSpace(content)) == 0 {
+		return ret, nil
+	}
+	if err := json.Unmarshal(content, &ret); err != nil {
+		return ret, cd.NewError(cd.Unexpected, fmt.Sprintf("decode response failed: %v", err))
+	}
+
+	resultVal, ok := any(&ret).(cd.ResultWithError)
+	if !ok {
+		resultVal, ok = any(ret).(cd.ResultWithError)
+	}
+	if ok {
+		if errVal := resultVal.GetError(); errVal != nil && errVal.Code != cd.Success {
+			return ret, errVal
+		}
+	}
+	return ret, nil
+}
+
+// statusError 优先使用响应中的 cd.Result 错误，否则按状态码转换
+func statusError(statusCode int, content []byte) *cd.Error {
+	result := &cd.Result{}
+	if err := json.Unmarshal(content, result); err == nil && result.Fail() {
+		return result.Error
+	}
+
+	message := fmt.Sprintf("unexpected statusCode: %d", statusCode)
+	switch statusCode {
+	case http.StatusBadRequest:
+		return cd.NewError(cd.IllegalParam, message)
+	case http.StatusUnauthorized:
+		return cd.NewError(cd.Unauthorized, message)
+	case http.StatusForbidden:
+		return cd.NewError(cd.Forbidden, message)
+	case http.StatusNotFound:
+		return cd.NewError(cd.NotFound, message)
+	case http.StatusConflict:
+		return cd.NewError(cd.VersionConflict, message)
+	case http.StatusTooManyRequests:
+		return cd.NewError(cd.TooManyRequests, message)
+	case http.StatusNotImplemented:
+		return cd.NewError(cd.NotImplemented, message)
+	case http.StatusBadGateway:
+		return cd.NewError(cd.BadGateway, message)
+	case http.StatusServiceUnavailable:
+		return cd.NewError(cd.ServiceUnavailable, message)
+	case http.StatusGatewayTimeout:
+		return cd.NewError(cd.Timeout, message)
+	}
+	if statusCode >= http.StatusInternalServerError {
+		return cd.NewError(cd.ExternalServiceError, message)
+	}
+	return cd.NewError(cd.Unexpected, message)
+}
+
+func retryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func contextError(ctx context.Context) *cd.Error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return cd.NewError(cd.Timeout, "request timeout")
+	}
+	return cd.NewError(cd.Unexpected, fmt.Sprintf("request canceled: %v", ctx.Err()))
+}
+
+// GetJSON 发送 GET 请求
+func GetJSON[T any](ctx context.Context, client *BaseClient, path string, query url.Values) (T, *cd.Error) {
+	return NewRequest[T](client, http.MethodGet, path).QueryValues(query).Do(ctx)
+}
+
+// PostJSON 发送 POST 请求，默认不重试
+func PostJSON[T any](ctx context.Context, client *BaseClient, path string, param any) (T, *cd.Error) {
+	return NewRequest[T](client, http.MethodPost, path).Body(param).Do(ctx)
+}
+
+// PutJSON 发送 PUT 请求
+func PutJSON[T any](ctx context.Context, client *BaseClient, path string, param any) (T, *cd.Error) {
+	return NewRequest[T](client, http.MethodPut, path).Body(param).Do(ctx)
+}
+
+// DeleteJSON 发送 DELETE 请求
+func DeleteJSON[T any](ctx context.Context, client *BaseClient, path string, query url.Values) (T, *cd.Error) {
+	return NewRequest[T](client, http.MethodDelete, path).QueryValues(query).Do(ctx)
+}
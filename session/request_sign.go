@@ -0,0 +1,339 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"weak"
+)
+
+const (
+	// signedToken 请求签名认证方式，Authorization: HMAC token=...,ts=...,nonce=...,signature=...
+	signedToken = "HMAC"
+
+	// DefaultSignatureSkew 默认允许的时钟偏差
+	DefaultSignatureSkew = 5 * time.Minute
+	// DefaultSignedBodyLimit 校验签名时默认读取的最大 body 长度
+	DefaultSignedBodyLimit int64 = 10 << 20
+
+	signSecretPrefix = "endpoint-sign:"
+	// nonceBytes nonce 的随机字节数，编码后为 24 个字符
+	nonceBytes = 18
+)
+
+var (
+	errIllegalSignature = errors.New("illegal request signature")
+	errSignatureExpired = errors.New("request signature timestamp out of range")
+	errNonceReplayed    = errors.New("request nonce replayed")
+	errBodyTooLarge     = errors.New("signed request body too large")
+)
+
+// NonceCache 记录已使用的 nonce，多副本部署时需要使用共享实现
+type NonceCache interface {
+	// CheckAndStore nonce 未出现过时记录并返回 true，记录保留到 expireAt
+	CheckAndStore(nonce string, expireAt int64) (bool, error)
+}
+
+type memoryNonceCache struct {
+	mu        sync.Mutex
+	nonces    map[string]int64
+	lastPurge time.Time
+}
+
+// NewMemoryNonceCache 创建进程内 nonce 缓存
+func NewMemoryNonceCache() NonceCache {
+	return &memoryNonceCache{nonces: map[string]int64{}}
+}
+
+func (s *memoryNonceCache) CheckAndStore(nonce string, expireAt int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	nowTime := now.UTC().UnixMilli()
+	if now.Sub(s.lastPurge) >= tokenStorePurgeInterval {
+		s.lastPurge = now
+		for k, v := range s.nonces {
+			if v < nowTime {
+				delete(s.nonces, k)
+			}
+		}
+	}
+
+	if val, ok := s.nonces[nonce]; ok && val >= nowTime {
+		return false, nil
+	}
+	s.nonces[nonce] = expireAt
+	return true, nil
+}
+
+// verifiedRequests 记录签名校验通过的请求，同一 *http.Request 再次获取会话时不视为重放；
+// 使用弱引用，不延长请求对象的生命周期
+type verifiedRequests struct {
+	mu        sync.Mutex
+	requests  map[string]verifiedRequest
+	lastPurge time.Time
+}
+
+type verifiedRequest struct {
+	req      weak.Pointer[http.Request]
+	expireAt int64
+}
+
+func (s *verifiedRequests) verified(key string, req *http.Request) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	val, ok := s.requests[key]
+	return ok && val.req.Value() == req
+}
+
+func (s *verifiedRequests) store(key string, req *http.Request, expireAt int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.requests == nil {
+		s.requests = map[string]verifiedRequest{}
+	}
+	if now.Sub(s.lastPurge) >= tokenStorePurgeInterval {
+		s.lastPurge = now
+		nowTime := now.UTC().UnixMilli()
+		for k, v := range s.requests {
+			if v.expireAt < nowTime || v.req.Value() == nil {
+				delete(s.requests, k)
+			}
+		}
+	}
+	s.requests[key] = verifiedRequest{req: weak.Make(req), expireAt: expireAt}
+}
+
+// IssueEndpointSecret 签发 endpoint 凭据，AuthToken 用于标识 endpoint，SignSecret 用于请求签名且不会在请求中传输
+func IssueEndpointSecret(endpoint *Endpoint) (*AuthSecret, error) {
+	authToken, err := EncryptEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	signSecret, err := deriveSignSecret(authToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthSecret{Endpoint: endpoint.Endpoint, AuthToken: authToken, SignSecret: signSecret}, nil
+}
+
+// deriveSignSecret 由服务端密钥和 AuthToken 派生签名密钥，服务端无需保存
+func deriveSignSecret(authToken string) (string, error) {
+	secretVal, err := getSecret()
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, []byte(secretVal))
+	mac.Write([]byte(signSecretPrefix + authToken))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// SignRequest 使用 endpoint 凭据为请求签名，签名覆盖 method、path、query、body 摘要、时间戳和 nonce
+func SignRequest(req *http.Request, authSecret *AuthSecret) error {
+	if req == nil || authSecret == nil || authSecret.AuthToken == "" || authSecret.SignSecret == "" {
+		return fmt.Errorf("illegal request or auth secret")
+	}
+
+	bodyHash, err := requestBodyHash(req, 0)
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().UTC().UnixMilli(), 10)
+	nonce, err := createSecureToken(nonceBytes)
+	if err != nil {
+		return err
+	}
+	signature := computeSignature(authSecret.SignSecret, canonicalRequest(req, timestamp, nonce, bodyHash))
+	req.Header.Set(Authorization, fmt.Sprintf("%s token=%s,ts=%s,nonce=%s,signature=%s", signedToken, authSecret.AuthToken, timestamp, nonce, signature))
+	return nil
+}
+
+// requestBodyHash 计算 body 摘要，读取后恢复 body 以便后续继续使用；
+// limit 大于 0 时最多读取 limit 字节，超过时返回 errBodyTooLarge，已读取的部分同样恢复
+func requestBodyHash(req *http.Request, limit int64) (string, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		reader := io.Reader(req.Body)
+		if limit > 0 {
+			reader = io.LimitReader(req.Body, limit+1)
+		}
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return "", err
+		}
+		if limit > 0 && int64(len(data)) > limit {
+			req.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(data), req.Body), req.Body}
+			return "", errBodyTooLarge
+		}
+		_ = req.Body.Close()
+		body = data
+		req.Body = io.NopCloser(bytes.NewReader(data))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+	}
+
+	hashVal := sha256.Sum256(body)
+	return hex.EncodeToString(hashVal[:]), nil
+}
+
+func canonicalRequest(req *http.Request, timestamp, nonce, bodyHash string) string {
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if req.URL.RawQuery != "" {
+		path += "?" + req.URL.RawQuery
+	}
+
+	return strings.Join([]string{strings.ToUpper(req.Method), path, timestamp, nonce, bodyHash}, "\n")
+}
+
+func computeSignature(signSecret, canonical string) string {
+	mac := hmac.New(sha256.New, []byte(signSecret))
+	mac.Write([]byte(canonical))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type signedParams struct {
+	token     string
+	timestamp string
+	nonce     string
+	signature string
+}
+
+func parseSignedParams(val string) (*signedParams, error) {
+	ret := &signedParams{}
+	for _, item := range strings.Split(val, ",") {
+		offset := strings.Index(item, "=")
+		if offset == -1 {
+			return nil, errIllegalSignature
+		}
+
+		itemVal := item[offset+1:]
+		switch strings.TrimSpace(item[:offset]) {
+		case "token":
+			ret.token = itemVal
+		case "ts":
+			ret.timestamp = itemVal
+		case "nonce":
+			ret.nonce = itemVal
+		case "signature":
+			ret.signature = itemVal
+		}
+	}
+	if ret.token == "" || ret.timestamp == "" || ret.nonce == "" || ret.signature == "" {
+		return nil, errIllegalSignature
+	}
+
+	return ret, nil
+}
+
+// verifiedNonceKey 标记请求已通过签名校验，同一请求多次获取会话时不视为重放
+type verifiedNonceKey struct{}
+
+// withVerifiedNonce 将签名校验通过的 nonce 写入 context，nonce 为空时原样返回
+func withVerifiedNonce(ctx context.Context, nonce string) context.Context {
+	if nonce == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, verifiedNonceKey{}, nonce)
+}
+
+// verifySignedRequest 校验请求签名，通过后返回 endpoint 会话和本次校验的 nonce；
+// 同一请求再次校验，或请求 context 中已标记同一 nonce 时跳过重放检查，调用方负责将 nonce 写入下游请求的 context
+func (s *sessionRegistryImpl) verifySignedRequest(req *http.Request, val string) (*sessionImpl, string, error) {
+	params, err := parseSignedParams(val)
+	if err != nil {
+		return nil, "", err
+	}
+
+	timestamp, err := strconv.ParseInt(params.timestamp, 10, 64)
+	if err != nil {
+		return nil, "", errIllegalSignature
+	}
+	nowTime := time.Now().UTC().UnixMilli()
+	skewVal := s.signatureSkew.Milliseconds()
+	if timestamp < nowTime-skewVal || timestamp > nowTime+skewVal {
+		return nil, "", errSignatureExpired
+	}
+
+	// 先确认 token 有效再读取 body，未认证的请求不会触发读取
+	sessionPtr := decodeEndpointToken(params.token)
+	if sessionPtr == nil {
+		return nil, "", errIllegalSignature
+	}
+	signSecret, err := deriveSignSecret(params.token)
+	if err != nil {
+		return nil, "", err
+	}
+	bodyHash, err := requestBodyHash(req, s.signedBodyLimit)
+	if err != nil {
+		return nil, "", err
+	}
+	expectSignature := computeSignature(signSecret, canonicalRequest(req, params.timestamp, params.nonce, bodyHash))
+	if !hmac.Equal([]byte(expectSignature), []byte(params.signature)) {
+		return nil, "", errIllegalSignature
+	}
+
+	nonceKey := params.token + "/" + params.nonce
+	verified, _ := req.Context().Value(verifiedNonceKey{}).(string)
+	if verified != params.nonce && !s.verifiedRequests.verified(nonceKey, req) {
+		ok, nonceErr := s.nonceCache.CheckAndStore(nonceKey, timestamp+skewVal)
+		if nonceErr != nil {
+			return nil, "", nonceErr
+		}
+		if !ok {
+			return nil, "", errNonceReplayed
+		}
+		s.verifiedRequests.store(nonceKey, req, timestamp+skewVal)
+	}
+
+	return sessionPtr, params.nonce, nil
+}
+
+// signingTransport 为 BaseClient 发出的请求自动签名
+type signingTransport struct {
+	base       http.RoundTripper
+	authSecret AuthSecret
+}
+
+func (s *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := s.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	// 显式指定了其它认证方式时不签名
+	authorization := req.Header.Get(Authorization)
+	if authorization != "" && !strings.HasPrefix(authorization, sigToken+" ") {
+		return base.RoundTrip(req)
+	}
+
+	signedReq := req.Clone(req.Context())
+	if err := SignRequest(signedReq, &s.authSecret); err != nil {
+		return nil, err
+	}
+	return base.RoundTrip(signedReq)
+}
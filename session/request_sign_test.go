@@ -0,0 +1,187 @@
+package session
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newSignedTestSecret(t *testing.T) *AuthSecret {
+	t.Helper()
+
+	authSecret, err := IssueEndpointSecret(&Endpoint{
+		Endpoint: "billing",
+		Context:  map[string]any{"name": "billing", AuthExpireTime: time.Now().Add(time.Hour).UTC().UnixMilli()},
+	})
+	if err != nil {
+		t.Fatalf("issue endpoint secret failed: %v", err)
+	}
+	return authSecret
+}
+
+func newSignedRequest(t *testing.T, authSecret *AuthSecret, body string) *http.Request {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/api/v1/orders?page=1", strings.NewReader(body))
+	if err := SignRequest(req, authSecret); err != nil {
+		t.Fatalf("sign request failed: %v", err)
+	}
+	return req
+}
+
+// replayRequest 复制请求头和 body，模拟重放
+func replayRequest(req *http.Request, body string) *http.Request {
+	replayReq := httptest.NewRequest(req.Method, req.URL.String(), strings.NewReader(body))
+	replayReq.Header = req.Header.Clone()
+	return replayReq
+}
+
+func TestSignedRequestVerify(t *testing.T) {
+	registry := NewRegistryWithOptions(nil)
+	defer registry.Release()
+
+	authSecret := newSignedTestSecret(t)
+	req := newSignedRequest(t, authSecret, `{"id":1}`)
+	assert.NotContains(t, req.Header.Get(Authorization), authSecret.SignSecret)
+
+	curSession, verifiedNonce := lookupSession(registry, req)
+	if assert.NotNil(t, curSession) {
+		nameVal, _ := curSession.GetString("name")
+		assert.Equal(t, "billing", nameVal)
+	}
+	assert.NotEmpty(t, verifiedNonce)
+	assert.Nil(t, req.Context().Value(verifiedNonceKey{}))
+
+	// 下游请求携带校验结果时再次获取会话不视为重放，body 仍可读取
+	downstreamReq := req.WithContext(withVerifiedNonce(req.Context(), verifiedNonce))
+	assert.NotNil(t, LookupSession(registry, downstreamReq))
+	body, _ := io.ReadAll(downstreamReq.Body)
+	assert.Equal(t, `{"id":1}`, string(body))
+
+	assert.Nil(t, LookupSession(registry, replayRequest(req, `{"id":1}`)))
+}
+
+func TestSignedRequestLookupTwiceWithoutMiddleware(t *testing.T) {
+	registry := NewRegistryWithOptions(nil)
+	defer registry.Release()
+
+	authSecret := newSignedTestSecret(t)
+	req := newSignedRequest(t, authSecret, `{"id":1}`)
+
+	// 同一请求多次获取会话不视为重放
+	first := LookupSession(registry, req)
+	second := LookupSession(registry, req)
+	if assert.NotNil(t, first) && assert.NotNil(t, second) {
+		assert.Equal(t, first.ID(), second.ID())
+		assert.Equal(t, first.ID(), registry.GetSession(nil, req).ID())
+	}
+	body, _ := io.ReadAll(req.Body)
+	assert.Equal(t, `{"id":1}`, string(body))
+
+	// 相同签名的其它请求仍视为重放
+	assert.Nil(t, LookupSession(registry, replayRequest(req, `{"id":1}`)))
+}
+
+func TestSignedRequestRejectsTamperAndSkew(t *testing.T) {
+	registry := NewRegistryWithOptions(nil, WithSignatureSkew(time.Second))
+	defer registry.Release()
+
+	authSecret := newSignedTestSecret(t)
+	req := newSignedRequest(t, authSecret, `{"amount":1}`)
+	assert.Nil(t, LookupSession(registry, replayRequest(req, `{"amount":100}`)))
+
+	forged := *authSecret
+	forged.SignSecret = "forged"
+	assert.Nil(t, LookupSession(registry, newSignedRequest(t, &forged, "")))
+
+	staleReq := httptest.NewRequest(http.MethodGet, "http://example.com/api", nil)
+	timestamp := time.Now().Add(-time.Minute).UTC().UnixMilli()
+	canonical := canonicalRequest(staleReq, fmt.Sprint(timestamp), "nonce", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+	staleReq.Header.Set(Authorization, fmt.Sprintf("%s token=%s,ts=%d,nonce=nonce,signature=%s", signedToken, authSecret.AuthToken, timestamp, computeSignature(authSecret.SignSecret, canonical)))
+	assert.Nil(t, LookupSession(registry, staleReq))
+}
+
+// countingReader 记录读取的字节数
+type countingReader struct {
+	reader io.Reader
+	count  int
+}
+
+func (s *countingReader) Read(p []byte) (int, error) {
+	n, err := s.reader.Read(p)
+	s.count += n
+	return n, err
+}
+
+func TestSignedRequestBodyLimit(t *testing.T) {
+	registry := NewRegistryWithOptions(nil, WithSignedBodyLimit(16))
+	defer registry.Release()
+
+	authSecret := newSignedTestSecret(t)
+	assert.NotNil(t, LookupSession(registry, newSignedRequest(t, authSecret, `{"id":1}`)))
+
+	// 超过限制时拒绝，body 仍可完整读取
+	body := strings.Repeat("x", 64)
+	req := newSignedRequest(t, authSecret, body)
+	assert.Nil(t, LookupSession(registry, req))
+	data, _ := io.ReadAll(req.Body)
+	assert.Equal(t, body, string(data))
+
+	// token 无效时不读取 body
+	reader := &countingReader{reader: strings.NewReader(body)}
+	forgedReq := httptest.NewRequest(http.MethodPost, "http://example.com/api", reader)
+	forgedReq.Header.Set(Authorization, fmt.Sprintf("%s token=forged,ts=%d,nonce=nonce,signature=sig", signedToken, time.Now().UTC().UnixMilli()))
+	assert.Nil(t, LookupSession(registry, forgedReq))
+	assert.Equal(t, 0, reader.count)
+}
+
+func TestSignedEndpointOnlyRejectsLegacyToken(t *testing.T) {
+	registry := NewRegistryWithOptions(nil, WithSignedEndpointOnly())
+	defer registry.Release()
+
+	authSecret := newSignedTestSecret(t)
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api", nil)
+	req.Header.Set(Authorization, fmt.Sprintf("%s %s", sigToken, authSecret.AuthToken))
+	assert.Nil(t, LookupSession(registry, req))
+}
+
+func TestBaseClientSignsRequests(t *testing.T) {
+	registry := NewRegistryWithOptions(nil)
+	defer registry.Release()
+
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if LookupSession(registry, req) == nil {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(req.Body)
+		_, _ = res.Write(body)
+	}))
+	defer server.Close()
+
+	client := NewBaseClient(server.URL)
+	defer client.Release()
+	client.BindAuthSecret(newSignedTestSecret(t))
+
+	for idx := 0; idx < 2; idx++ {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/orders", bytes.NewBufferString("payload"))
+		for key, vals := range client.GetContextValues() {
+			req.Header[key] = vals
+		}
+		res, err := client.GetHTTPClient().Do(req)
+		if !assert.Nil(t, err) {
+			return
+		}
+		body, _ := io.ReadAll(res.Body)
+		_ = res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "payload", string(body))
+	}
+}
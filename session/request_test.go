@@ -0,0 +1,153 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	cd "github.com/muidea/magicCommon/def"
+)
+
+type orderResult struct {
+	cd.Result
+	Value struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"value"`
+}
+
+func newRequestTestClient(serverURL string) BaseClient {
+	client := NewBaseClient(serverURL)
+	return client.WithRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond})
+}
+
+func TestRequestDecodesResult(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Header.Get(Authorization) != "Bearer demo" || req.Header.Get("X-Mp-Tenant") != "t1" {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if req.URL.Query().Get("id") == "404" {
+			_ = json.NewEncoder(res).Encode(cd.Result{Error: cd.NewError(cd.NotFound, "order not found")})
+			return
+		}
+		_, _ = res.Write([]byte(`{"value":{"id":1,"name":"demo"}}`))
+	}))
+	defer server.Close()
+
+	client := newRequestTestClient(server.URL)
+	defer client.Release()
+	headerContext := NewDefaultHeaderContext()
+	headerContext.Set("X-Mp-Tenant", "t1")
+	client.AttachContext(headerContext)
+	client.AttachAuthorization("Bearer demo")
+
+	result, err := GetJSON[orderResult](context.Background(), &client, "/orders", url.Values{"id": {"1"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "demo", result.Value.Name)
+
+	_, err = GetJSON[orderResult](context.Background(), &client, "/orders", url.Values{"id": {"404"}})
+	if assert.NotNil(t, err) {
+		assert.Equal(t, cd.Code(cd.NotFound), err.Code)
+	}
+
+	client.DetachAuthorization()
+	_, err = GetJSON[orderResult](context.Background(), &client, "/orders", nil)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, cd.Code(cd.Unauthorized), err.Code)
+	}
+}
+
+func TestRequestRetriesIdempotentCalls(t *testing.T) {
+	var count atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+		if count.Add(1) < 3 {
+			res.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = res.Write([]byte(`{"value":{"id":1}}`))
+	}))
+	defer server.Close()
+
+	client := newRequestTestClient(server.URL)
+	defer client.Release()
+
+	result, err := GetJSON[orderResult](context.Background(), &client, "/orders", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, result.Value.ID)
+	assert.Equal(t, int32(3), count.Load())
+
+	// 非幂等请求不重试
+	count.Store(0)
+	_, err = PostJSON[orderResult](context.Background(), &client, "/orders", map[string]any{"name": "demo"})
+	if assert.NotNil(t, err) {
+		assert.Equal(t, cd.Code(cd.ServiceUnavailable), err.Code)
+	}
+	assert.Equal(t, int32(1), count.Load())
+
+	count.Store(0)
+	_, err = NewRequest[orderResult](&client, http.MethodPost, "/orders").Idempotent().Do(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), count.Load())
+}
+
+func TestRequestCircuitBreaker(t *testing.T) {
+	var count atomic.Int32
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+		count.Add(1)
+		if !healthy.Load() {
+			res.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = res.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client := NewBaseClient(server.URL)
+	defer client.Release()
+	client = client.WithRetryPolicy(RetryPolicy{MaxAttempts: 1})
+	client = client.WithCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 2, OpenTimeout: 50 * time.Millisecond})
+
+	for idx := 0; idx < 2; idx++ {
+		_, err := GetJSON[orderResult](context.Background(), &client, "/orders", nil)
+		assert.Equal(t, cd.Code(cd.ExternalServiceError), err.Code)
+	}
+	_, err := GetJSON[orderResult](context.Background(), &client, "/orders", nil)
+	assert.Equal(t, cd.Code(cd.ServiceUnavailable), err.Code)
+	assert.Equal(t, int32(2), count.Load())
+
+	healthy.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	_, err = GetJSON[orderResult](context.Background(), &client, "/orders", nil)
+	assert.Nil(t, err)
+	_, err = GetJSON[orderResult](context.Background(), &client, "/orders", nil)
+	assert.Nil(t, err)
+}
+
+func TestRequestContextTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		select {
+		case <-req.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	client := newRequestTestClient(server.URL)
+	defer client.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := GetJSON[orderResult](ctx, &client, "/slow", nil)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, cd.Code(cd.Timeout), err.Code)
+	}
+}
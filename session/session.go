@@ -32,6 +32,10 @@ const (
 	InnerStartTime = "innerSessionStartTime"
 	// innerExpireTime 会话有效期，该有效性必须要定期刷新，否则就会在超过该有效期时失效
 	innerExpireTime = "innerExpireTime"
+	// InnerTokenID 当前请求 token 的 jti，可用于 RevokeToken
+	InnerTokenID = "_jti"
+	// innerIssuedAt token 签发时间，用于判断 token 是否早于吊销时间
+	innerIssuedAt = "_issuedAt"
 	// AuthExpireTime 会话强制有效期，该有效期通过session Option进行强制设置，与innerExpireTime在使用时，取两者之间最大值为实际会话有效期
 	AuthExpireTime = "authExpireTime"
 	// Authorization info, from request header
@@ -48,6 +52,8 @@ const (
 	jwtToken = "Bearer"
 	sigToken = "Sig"
 
+	jwtIDClaim = "jti"
+
 	DefaultSessionTimeOutValue = 10 * time.Minute // 10 minute
 )
 
@@ -89,6 +95,10 @@ type sessionImpl struct {
 	observer map[string]Observer
 	registry *sessionRegistryImpl
 	status   int
+	// admittedUser 已通过并发会话上限检查的用户
+	admittedUser string
+	// persisted 最近一次写入或从存储读取的会话数据，用于跳过没有变化的写入
+	persisted *SessionRecord
 }
 
 func (s *sessionImpl) ID() string {
@@ -109,6 +119,8 @@ func (s *sessionImpl) Signature() (string, error) {
 	if s.id != "" {
 		mc[innerSessionID] = s.id
 	}
+	mc[jwtIDClaim] = createUUID()
+	mc[innerIssuedAt] = time.Now().UTC().UnixMilli()
 
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -255,6 +267,7 @@ func (s *sessionImpl) SubmitOptions() {
 	}
 	s.mu.Unlock()
 
+	s.registry.audit(AuditClaimsChanged, s.id, s, "")
 	for _, val := range observers {
 		go val.OnStatusChange(s, StatusUpdate)
 	}
@@ -272,24 +285,6 @@ func (s *sessionImpl) refresh() {
 	s.context[innerExpireTime] = expireValue
 }
 
-func (s *sessionImpl) timeout() (ret bool) {
-	var innerExpireTimeInt64 int64
-	nowTime := time.Now().UTC().UnixMilli()
-
-	s.mu.RLock()
-	innerExpireTimeInt64 = s.getExpireTime()
-	s.mu.RUnlock()
-
-	// 过期时间小于当前时间就说明已经过期
-	ret = innerExpireTimeInt64 < nowTime
-	if ret {
-		s.mu.Lock()
-		s.status = sessionTerminate
-		s.mu.Unlock()
-	}
-	return
-}
-
 // 该函数调用前必须确保 session 已加锁
 func (s *sessionImpl) getExpireTime() int64 {
 	var innerExpireTimeInt64 int64
@@ -0,0 +1,194 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"sync"
+	"time"
+)
+
+const defaultStoreCleanupInterval = 5 * time.Second
+
+var errInvalidSessionRecord = errors.New("invalid session record")
+
+// SessionRecord 会话持久化记录
+type SessionRecord struct {
+	ID       string         `json:"id"`
+	Context  map[string]any `json:"context"`
+	ExpireAt int64          `json:"expireAt"`
+}
+
+func (s *SessionRecord) expired(nowTime int64) bool {
+	return s.ExpireAt < nowTime
+}
+
+func (s *SessionRecord) clone() *SessionRecord {
+	return &SessionRecord{
+		ID:       s.ID,
+		Context:  maps.Clone(s.Context),
+		ExpireAt: s.ExpireAt,
+	}
+}
+
+// ExpireHandler store 清理过期会话后的回调
+type ExpireHandler func(sessionID string)
+
+// SessionStore 会话存储，Registry 通过它持久化和共享会话数据。
+// 过期清理由 store 负责，清理掉的会话通过 ExpireHandler 通知 Registry。
+type SessionStore interface {
+	// Load 加载会话，不存在或已过期时返回 nil, nil
+	Load(sessionID string) (*SessionRecord, error)
+	Save(record *SessionRecord) error
+	Delete(sessionID string) error
+	// Range 遍历未过期的会话，visitor 返回 false 时停止遍历
+	Range(visitor func(record *SessionRecord) bool) error
+	// StartCleanup 启动 TTL 清理，重复调用只生效一次
+	StartCleanup(interval time.Duration, onExpire ExpireHandler)
+	Close() error
+}
+
+// cleanupFunc 删除已过期的会话并返回被删除的会话ID
+type cleanupFunc func(nowTime int64) ([]string, error)
+
+// storeCleaner store 公共的 TTL 清理循环
+type storeCleaner struct {
+	startOnce sync.Once
+	stopOnce  sync.Once
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+func (s *storeCleaner) start(interval time.Duration, cleanup cleanupFunc, onExpire ExpireHandler) {
+	s.startOnce.Do(func() {
+		if interval <= 0 {
+			interval = defaultStoreCleanupInterval
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		s.cancel = cancel
+		s.done = make(chan struct{})
+		go s.run(ctx, interval, cleanup, onExpire)
+	})
+}
+
+func (s *storeCleaner) run(ctx context.Context, interval time.Duration, cleanup cleanupFunc, onExpire ExpireHandler) {
+	defer close(s.done)
+
+	cleanupTicker := time.NewTicker(interval)
+	defer cleanupTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-cleanupTicker.C:
+			expiredIDs, err := cleanup(time.Now().UTC().UnixMilli())
+			if err != nil {
+				slog.Warn("cleanup expired sessions failed", "error", err)
+			}
+			if onExpire == nil {
+				continue
+			}
+			for _, id := range expiredIDs {
+				onExpire(id)
+			}
+		}
+	}
+}
+
+func (s *storeCleaner) stop() {
+	s.stopOnce.Do(func() {
+		s.startOnce.Do(func() {})
+		if s.cancel != nil {
+			s.cancel()
+			<-s.done
+		}
+	})
+}
+
+// memoryStore 进程内会话存储
+type memoryStore struct {
+	cleaner storeCleaner
+	mu      sync.RWMutex
+	records map[string]*SessionRecord
+}
+
+// NewMemoryStore 创建进程内会话存储，进程重启后会话丢失
+func NewMemoryStore() SessionStore {
+	return &memoryStore{records: map[string]*SessionRecord{}}
+}
+
+func (s *memoryStore) Load(sessionID string) (*SessionRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.records[sessionID]
+	if !ok || record.expired(time.Now().UTC().UnixMilli()) {
+		return nil, nil
+	}
+
+	return record.clone(), nil
+}
+
+func (s *memoryStore) Save(record *SessionRecord) error {
+	if record == nil || record.ID == "" {
+		return errInvalidSessionRecord
+	}
+
+	s.mu.Lock()
+	s.records[record.ID] = record.clone()
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *memoryStore) Delete(sessionID string) error {
+	s.mu.Lock()
+	delete(s.records, sessionID)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *memoryStore) Range(visitor func(record *SessionRecord) bool) error {
+	nowTime := time.Now().UTC().UnixMilli()
+
+	s.mu.RLock()
+	records := make([]*SessionRecord, 0, len(s.records))
+	for _, val := range s.records {
+		if !val.expired(nowTime) {
+			records = append(records, val.clone())
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, val := range records {
+		if !visitor(val) {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) StartCleanup(interval time.Duration, onExpire ExpireHandler) {
+	s.cleaner.start(interval, s.cleanup, onExpire)
+}
+
+func (s *memoryStore) cleanup(nowTime int64) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expiredIDs []string
+	for id, val := range s.records {
+		if val.expired(nowTime) {
+			expiredIDs = append(expiredIDs, id)
+			delete(s.records, id)
+		}
+	}
+	return expiredIDs, nil
+}
+
+func (s *memoryStore) Close() error {
+	s.cleaner.stop()
+	return nil
+}
@@ -0,0 +1,191 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	cd "github.com/muidea/magicCommon/def"
+	"github.com/muidea/magicCommon/foundation/dao"
+)
+
+// DefaultSessionTable 默认会话表名
+const DefaultSessionTable = "magic_session"
+
+// daoStore 基于 foundation/dao 的会话存储，支持 MySQL 和 PostgreSQL。
+// Dao 本身不是并发安全的，daoStore 内部串行化访问，建议使用独立的 Dao。
+type daoStore struct {
+	cleaner    storeCleaner
+	mu         sync.Mutex
+	dao        dao.Dao
+	tableName  string
+	driverName string
+}
+
+// NewDaoStore 创建基于数据库表的会话存储，tableName 为空时使用 DefaultSessionTable，表不存在时自动创建
+func NewDaoStore(daoPtr dao.Dao, tableName string) (SessionStore, *cd.Error) {
+	if daoPtr == nil {
+		return nil, cd.NewError(cd.InvalidParameter, "dao is nil")
+	}
+	if tableName == "" {
+		tableName = DefaultSessionTable
+	}
+	if !dao.IsValidTableName(tableName) {
+		return nil, cd.NewError(cd.InvalidParameter, fmt.Sprintf("illegal session table name: %s", tableName))
+	}
+
+	driverName, ok := dao.DriverNameOf(daoPtr)
+	if !ok {
+		return nil, cd.NewError(cd.NotImplemented, "dao does not report driver name")
+	}
+
+	store := &daoStore{dao: daoPtr, tableName: tableName, driverName: driverName}
+	createSQL := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (session_id VARCHAR(128) NOT NULL PRIMARY KEY, context TEXT NOT NULL, expire_at BIGINT NOT NULL)", tableName)
+	if _, err := daoPtr.Execute(createSQL); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (s *daoStore) bindVar(idx int) string {
+	return dao.Placeholder(s.driverName, idx)
+}
+
+func (s *daoStore) Load(sessionID string) (*SessionRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	querySQL := fmt.Sprintf("SELECT session_id, context, expire_at FROM %s WHERE session_id = %s AND expire_at >= %s", s.tableName, s.bindVar(1), s.bindVar(2))
+	records, err := s.queryRecords(querySQL, sessionID, time.Now().UTC().UnixMilli())
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+
+	return records[0], nil
+}
+
+func (s *daoStore) Save(record *SessionRecord) error {
+	if record == nil || record.ID == "" {
+		return errInvalidSessionRecord
+	}
+
+	content, err := json.Marshal(record.Context)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updateSQL := fmt.Sprintf("UPDATE %s SET context = %s, expire_at = %s WHERE session_id = %s", s.tableName, s.bindVar(1), s.bindVar(2), s.bindVar(3))
+	affected, updateErr := s.dao.Execute(updateSQL, string(content), record.ExpireAt, record.ID)
+	if updateErr != nil {
+		return updateErr
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	insertSQL := fmt.Sprintf("INSERT INTO %s (session_id, context, expire_at) VALUES (%s, %s, %s)", s.tableName, s.bindVar(1), s.bindVar(2), s.bindVar(3))
+	_, insertErr := s.dao.Execute(insertSQL, record.ID, string(content), record.ExpireAt)
+	if insertErr == nil {
+		return nil
+	}
+
+	// MySQL 在数据未变化时 UPDATE 影响行数为 0，插入冲突时确认记录已存在即可
+	existSQL := fmt.Sprintf("SELECT session_id, context, expire_at FROM %s WHERE session_id = %s", s.tableName, s.bindVar(1))
+	records, queryErr := s.queryRecords(existSQL, record.ID)
+	if queryErr == nil && len(records) > 0 {
+		return nil
+	}
+
+	return insertErr
+}
+
+func (s *daoStore) Delete(sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleteSQL := fmt.Sprintf("DELETE FROM %s WHERE session_id = %s", s.tableName, s.bindVar(1))
+	_, err := s.dao.Execute(deleteSQL, sessionID)
+	return cd.ToStdError(err)
+}
+
+func (s *daoStore) Range(visitor func(record *SessionRecord) bool) error {
+	s.mu.Lock()
+	querySQL := fmt.Sprintf("SELECT session_id, context, expire_at FROM %s WHERE expire_at >= %s", s.tableName, s.bindVar(1))
+	records, err := s.queryRecords(querySQL, time.Now().UTC().UnixMilli())
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, val := range records {
+		if !visitor(val) {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *daoStore) StartCleanup(interval time.Duration, onExpire ExpireHandler) {
+	s.cleaner.start(interval, s.cleanup, onExpire)
+}
+
+func (s *daoStore) cleanup(nowTime int64) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	querySQL := fmt.Sprintf("SELECT session_id, context, expire_at FROM %s WHERE expire_at < %s", s.tableName, s.bindVar(1))
+	records, err := s.queryRecords(querySQL, nowTime)
+	if err != nil {
+		return nil, err
+	}
+
+	// 逐条删除，保证多个副本同时清理时每个会话只被上报一次
+	deleteSQL := fmt.Sprintf("DELETE FROM %s WHERE session_id = %s AND expire_at < %s", s.tableName, s.bindVar(1), s.bindVar(2))
+	var expiredIDs []string
+	for _, val := range records {
+		affected, deleteErr := s.dao.Execute(deleteSQL, val.ID, nowTime)
+		if deleteErr != nil {
+			return expiredIDs, deleteErr
+		}
+		if affected > 0 {
+			expiredIDs = append(expiredIDs, val.ID)
+		}
+	}
+
+	return expiredIDs, nil
+}
+
+func (s *daoStore) Close() error {
+	s.cleaner.stop()
+	return nil
+}
+
+// queryRecords 调用前必须持有 s.mu
+func (s *daoStore) queryRecords(querySQL string, args ...any) ([]*SessionRecord, error) {
+	if err := s.dao.Query(querySQL, args...); err != nil {
+		return nil, err
+	}
+	defer func() { _ = s.dao.Finish() }()
+
+	var records []*SessionRecord
+	for s.dao.Next() {
+		var sessionID, content string
+		var expireAt int64
+		if err := s.dao.GetField(&sessionID, &content, &expireAt); err != nil {
+			return nil, err
+		}
+
+		record := &SessionRecord{ID: sessionID, Context: map[string]any{}, ExpireAt: expireAt}
+		if err := json.Unmarshal([]byte(content), &record.Context); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
@@ -0,0 +1,177 @@
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const fileStoreSuffix = ".session"
+
+// fileStore 基于目录的会话存储，每个会话一个 JSON 文件，多个进程共享同一目录时可以共享会话
+type fileStore struct {
+	cleaner storeCleaner
+	dir     string
+}
+
+// NewFileStore 创建基于目录的会话存储，目录不存在时自动创建
+func NewFileStore(dir string) (SessionStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("session store directory is empty")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &fileStore{dir: dir}, nil
+}
+
+// recordPath 会话ID来自JWT声明，不能直接作为文件名，统一使用摘要
+func (s *fileStore) recordPath(sessionID string) string {
+	digest := sha256.Sum256([]byte(sessionID))
+	return filepath.Join(s.dir, hex.EncodeToString(digest[:])+fileStoreSuffix)
+}
+
+func (s *fileStore) Load(sessionID string) (*SessionRecord, error) {
+	record, err := s.readRecord(s.recordPath(sessionID))
+	if err != nil || record == nil {
+		return nil, err
+	}
+	if record.ID != sessionID || record.expired(time.Now().UTC().UnixMilli()) {
+		return nil, nil
+	}
+
+	return record, nil
+}
+
+func (s *fileStore) Save(record *SessionRecord) error {
+	if record == nil || record.ID == "" {
+		return errInvalidSessionRecord
+	}
+
+	content, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := os.CreateTemp(s.dir, "tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+	_, err = tmpFile.Write(content)
+	closeErr := tmpFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	if err = os.Rename(tmpPath, s.recordPath(record.ID)); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+func (s *fileStore) Delete(sessionID string) error {
+	err := os.Remove(s.recordPath(sessionID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *fileStore) Range(visitor func(record *SessionRecord) bool) error {
+	nowTime := time.Now().UTC().UnixMilli()
+	return s.walk(func(_ string, record *SessionRecord) bool {
+		if record.expired(nowTime) {
+			return true
+		}
+		return visitor(record)
+	})
+}
+
+func (s *fileStore) StartCleanup(interval time.Duration, onExpire ExpireHandler) {
+	s.cleaner.start(interval, s.cleanup, onExpire)
+}
+
+func (s *fileStore) cleanup(nowTime int64) ([]string, error) {
+	var expiredIDs []string
+	var removeErr error
+	err := s.walk(func(filePath string, record *SessionRecord) bool {
+		if !record.expired(nowTime) {
+			return true
+		}
+
+		// 多个进程同时清理时，只有删除成功的一方上报过期
+		if err := os.Remove(filePath); err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				removeErr = err
+			}
+			return true
+		}
+		expiredIDs = append(expiredIDs, record.ID)
+		return true
+	})
+	if err == nil {
+		err = removeErr
+	}
+
+	return expiredIDs, err
+}
+
+func (s *fileStore) Close() error {
+	s.cleaner.stop()
+	return nil
+}
+
+func (s *fileStore) walk(visitor func(filePath string, record *SessionRecord) bool) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), fileStoreSuffix) {
+			continue
+		}
+
+		filePath := filepath.Join(s.dir, entry.Name())
+		record, readErr := s.readRecord(filePath)
+		if readErr != nil || record == nil {
+			continue
+		}
+		if !visitor(filePath, record) {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *fileStore) readRecord(filePath string) (*SessionRecord, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	record := &SessionRecord{}
+	if err = json.Unmarshal(content, record); err != nil {
+		return nil, err
+	}
+	if record.Context == nil {
+		record.Context = map[string]any{}
+	}
+	return record, nil
+}
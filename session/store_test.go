@@ -0,0 +1,204 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingStore 统计写入次数的会话存储
+type countingStore struct {
+	SessionStore
+	saves atomic.Int32
+}
+
+func (s *countingStore) Save(record *SessionRecord) error {
+	s.saves.Add(1)
+	return s.SessionStore.Save(record)
+}
+
+func testStoreBasic(t *testing.T, store SessionStore) {
+	t.Helper()
+
+	record := &SessionRecord{
+		ID:       "store-session",
+		Context:  map[string]any{"custom": "value"},
+		ExpireAt: time.Now().Add(time.Minute).UTC().UnixMilli(),
+	}
+	if err := store.Save(record); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	loaded, err := store.Load(record.ID)
+	if err != nil || loaded == nil {
+		t.Fatalf("Load() = %v, %v, want record", loaded, err)
+	}
+	if loaded.Context["custom"] != "value" {
+		t.Fatalf("custom = %v, want value", loaded.Context["custom"])
+	}
+
+	count := 0
+	_ = store.Range(func(*SessionRecord) bool {
+		count++
+		return true
+	})
+	if count != 1 {
+		t.Fatalf("Range() visited %d records, want 1", count)
+	}
+
+	if err = store.Delete(record.ID); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if loaded, _ = store.Load(record.ID); loaded != nil {
+		t.Fatalf("expected deleted record to be gone")
+	}
+
+	expired := &SessionRecord{ID: "expired-session", Context: map[string]any{}, ExpireAt: time.Now().Add(-time.Second).UTC().UnixMilli()}
+	_ = store.Save(expired)
+	if loaded, _ = store.Load(expired.ID); loaded != nil {
+		t.Fatalf("expected expired record to be hidden")
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	defer store.Close()
+
+	testStoreBasic(t, store)
+}
+
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore() failed: %v", err)
+	}
+	defer store.Close()
+
+	testStoreBasic(t, store)
+}
+
+func TestFileStoreCleanupReportsExpired(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore() failed: %v", err)
+	}
+	defer store.Close()
+
+	_ = store.Save(&SessionRecord{ID: "expired-session", Context: map[string]any{}, ExpireAt: time.Now().Add(-time.Second).UTC().UnixMilli()})
+	_ = store.Save(&SessionRecord{ID: "live-session", Context: map[string]any{}, ExpireAt: time.Now().Add(time.Minute).UTC().UnixMilli()})
+
+	expiredCh := make(chan string, 2)
+	store.StartCleanup(10*time.Millisecond, func(sessionID string) {
+		expiredCh <- sessionID
+	})
+
+	select {
+	case id := <-expiredCh:
+		if id != "expired-session" {
+			t.Fatalf("expired id = %s, want expired-session", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for expired session")
+	}
+	if loaded, _ := store.Load("live-session"); loaded == nil {
+		t.Fatal("live session should survive cleanup")
+	}
+}
+
+func TestRegistrySharesSessionsThroughStore(t *testing.T) {
+	dir := t.TempDir()
+	storeA, _ := NewFileStore(dir)
+	storeB, _ := NewFileStore(dir)
+	registryA := NewRegistryWithOptions(nil, WithSessionStore(storeA))
+	defer registryA.Release()
+	registryB := NewRegistryWithOptions(nil, WithSessionStore(storeB))
+	defer registryB.Release()
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	sessionA := registryA.GetSession(nil, req)
+	sessionA.SetOption("_cart", "42")
+
+	token, err := sessionA.Signature()
+	if err != nil {
+		t.Fatalf("Signature() failed: %v", err)
+	}
+
+	nextReq := httptest.NewRequest(http.MethodGet, "http://example.com/next", nil)
+	nextReq.AddCookie(&http.Cookie{Name: SessionToken, Value: token})
+	sessionB := LookupSession(registryB, nextReq)
+	if sessionB == nil || sessionB.ID() != sessionA.ID() {
+		t.Fatalf("expected replica B to load session %s", sessionA.ID())
+	}
+	if val, ok := sessionB.GetString("_cart"); !ok || val != "42" {
+		t.Fatalf("_cart = %q, %v, want 42 from shared store", val, ok)
+	}
+	if got := registryB.CountSession(nil); got != 1 {
+		t.Fatalf("replica B count = %d, want 1", got)
+	}
+
+	// 其它实例删除的字段不能残留在本地会话中
+	sessionA.RemoveOption("_cart")
+	sessionB = LookupSession(registryB, nextReq)
+	if sessionB == nil {
+		t.Fatal("expected replica B to keep session")
+	}
+	if val, ok := sessionB.GetString("_cart"); ok {
+		t.Fatalf("_cart = %q, want removed by replica A", val)
+	}
+}
+
+func TestRegistrySkipsUnchangedSessionWrites(t *testing.T) {
+	store := &countingStore{SessionStore: NewMemoryStore()}
+	registry := NewRegistryWithOptions(nil, WithSessionStore(store))
+	defer registry.Release()
+
+	sessionPtr := registry.GetSession(nil, httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+	token, err := sessionPtr.Signature()
+	if err != nil {
+		t.Fatalf("Signature() failed: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/next", nil)
+	req.AddCookie(&http.Cookie{Name: SessionToken, Value: token})
+	if LookupSession(registry, req) == nil {
+		t.Fatal("expected session lookup to succeed")
+	}
+	saves := store.saves.Load()
+	for idx := 0; idx < 10; idx++ {
+		if LookupSession(registry, req) == nil {
+			t.Fatal("expected session lookup to succeed")
+		}
+	}
+	if got := store.saves.Load(); got != saves {
+		t.Fatalf("saves = %d, want %d for unchanged session", got, saves)
+	}
+
+	sessionPtr.SetOption("custom", "value")
+	if got := store.saves.Load(); got != saves+1 {
+		t.Fatalf("saves = %d, want %d after SetOption", got, saves+1)
+	}
+}
+
+func TestRegistryNotifiesObserverWhenStoreExpiresSession(t *testing.T) {
+	observer := &testSessionObserver{id: "observer-1", statusCh: make(chan Status, 1)}
+	registry := NewRegistryWithOptions(observer, WithCleanupInterval(10*time.Millisecond))
+	defer registry.Release()
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	sessionPtr := registry.GetSession(nil, req)
+	sessionPtr.SetOption(innerExpireTime, time.Now().Add(-time.Second).UTC().UnixMilli())
+
+	select {
+	case status := <-observer.statusCh:
+		if status != StatusTerminate {
+			t.Fatalf("status = %v, want terminate", status)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for terminate notification")
+	}
+	if got := registry.CountSession(nil); got != 0 {
+		t.Fatalf("count = %d, want 0 after expiry", got)
+	}
+}
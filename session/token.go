@@ -0,0 +1,347 @@
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultRefreshTokenTTL refresh token 默认有效期
+	DefaultRefreshTokenTTL = 7 * 24 * time.Hour
+
+	refreshTokenBytes = 32
+
+	revokeSessionPrefix = "session:"
+	revokeTokenPrefix   = "token:"
+	revokeUserPrefix    = "user:"
+)
+
+var (
+	ErrRegistryNotSupported   = errors.New("registry does not support tokens")
+	ErrInvalidRefreshToken    = errors.New("invalid refresh token")
+	ErrRefreshTokenReused     = errors.New("refresh token reused, session revoked")
+	ErrUserClaimNotConfigured = errors.New("user claim is not configured")
+)
+
+// TokenPair 访问 token 和 refresh token
+type TokenPair struct {
+	AccessToken     string `json:"accessToken"`
+	RefreshToken    string `json:"refreshToken"`
+	RefreshExpireAt int64  `json:"refreshExpireAt"`
+}
+
+func registryImplOf(reg Registry) (*sessionRegistryImpl, error) {
+	impl, ok := reg.(*sessionRegistryImpl)
+	if !ok || impl == nil {
+		return nil, ErrRegistryNotSupported
+	}
+	return impl, nil
+}
+
+// IssueTokenPair 为会话签发访问 token 和 refresh token
+func IssueTokenPair(reg Registry, curSession Session) (*TokenPair, error) {
+	impl, err := registryImplOf(reg)
+	if err != nil {
+		return nil, err
+	}
+	sessionPtr, ok := curSession.(*sessionImpl)
+	if !ok || sessionPtr == nil {
+		return nil, fmt.Errorf("illegal session")
+	}
+
+	return impl.issueTokenPair(sessionPtr)
+}
+
+// RefreshTokenPair 使用 refresh token 换取新的 token 对，旧 refresh token 立即失效。
+// 已使用过的 refresh token 再次出现时视为泄露，会话及其全部 refresh token 被吊销。
+func RefreshTokenPair(reg Registry, refreshToken string) (*TokenPair, error) {
+	impl, err := registryImplOf(reg)
+	if err != nil {
+		return nil, err
+	}
+
+	return impl.refreshTokenPair(refreshToken)
+}
+
+// RevokeSession 吊销会话，该会话已签发的 token 和 refresh token 全部失效
+func RevokeSession(reg Registry, sessionID string) error {
+	impl, err := registryImplOf(reg)
+	if err != nil {
+		return err
+	}
+
+	return impl.revokeSession(sessionID)
+}
+
+// RevokeToken 按 jti 吊销单个 token，会话本身不受影响；expireAt 为该 token 的过期时间
+func RevokeToken(reg Registry, tokenID string, expireAt int64) error {
+	impl, err := registryImplOf(reg)
+	if err != nil {
+		return err
+	}
+	if tokenID == "" {
+		return fmt.Errorf("token id is empty")
+	}
+
+	return impl.tokenStore.Revoke(revokeTokenPrefix+tokenID, time.Now().UTC().UnixMilli(), expireAt)
+}
+
+// RevokeUserSessions 吊销用户的全部会话，返回吊销的会话数量，需要通过 WithUserClaim 指定用户声明
+func RevokeUserSessions(reg Registry, userID string) (int, error) {
+	impl, err := registryImplOf(reg)
+	if err != nil {
+		return 0, err
+	}
+
+	return impl.revokeUserSessions(userID)
+}
+
+func hashRefreshToken(refreshToken string) string {
+	hashVal := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(hashVal[:])
+}
+
+// userIDOf 读取用户声明，声明值为对象时取其 id 字段
+func userIDOf(context map[string]any, userClaim string) string {
+	if userClaim == "" {
+		return ""
+	}
+
+	switch val := context[userClaim].(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case map[string]any:
+		if idVal, ok := val["id"]; ok && idVal != nil {
+			return userIDOf(map[string]any{userClaim: idVal}, userClaim)
+		}
+		return ""
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
+
+func (s *sessionRegistryImpl) issueTokenPair(sessionPtr *sessionImpl) (*TokenPair, error) {
+	accessToken, err := sessionPtr.Signature()
+	if err != nil {
+		return nil, err
+	}
+
+	nowTime := time.Now().UTC()
+	sessionPtr.mu.RLock()
+	claims := make(map[string]any, len(sessionPtr.context))
+	for k, v := range sessionPtr.context {
+		if !sessionPtr.excludeKey(k) {
+			claims[k] = v
+		}
+	}
+	userID := userIDOf(sessionPtr.context, s.userClaim)
+	sessionPtr.mu.RUnlock()
+
+	refreshToken, err := createSecureToken(refreshTokenBytes)
+	if err != nil {
+		return nil, err
+	}
+	record := &RefreshTokenRecord{
+		TokenHash: hashRefreshToken(refreshToken),
+		SessionID: sessionPtr.id,
+		UserID:    userID,
+		Claims:    claims,
+		IssuedAt:  nowTime.UnixMilli(),
+		ExpireAt:  nowTime.Add(s.refreshTokenTTL).UnixMilli(),
+	}
+	if err = s.tokenStore.SaveRefreshToken(record); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{AccessToken: accessToken, RefreshToken: refreshToken, RefreshExpireAt: record.ExpireAt}, nil
+}
+
+func (s *sessionRegistryImpl) refreshTokenPair(refreshToken string) (*TokenPair, error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	record, err := s.tokenStore.LoadRefreshToken(hashRefreshToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	consumed, err := s.tokenStore.ConsumeRefreshToken(record.TokenHash)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		slog.Warn("refresh token reuse detected, revoke session", "session", record.SessionID)
+		if err = s.revokeSessionWithReason(record.SessionID, auditReasonTokenReused); err != nil {
+			slog.Warn("revoke session failed", "session", record.SessionID, "error", err)
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	revoked, err := s.isRevoked(record.SessionID, "", record.UserID, record.IssuedAt)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	sessionPtr := s.findSession(record.SessionID)
+	if sessionPtr != nil && sessionPtr.isFinal() {
+		s.removeSession(sessionPtr.id)
+		sessionPtr = nil
+	}
+	if sessionPtr == nil {
+		// 会话数据已过期，按签发时的声明重建
+		context := make(map[string]any, len(record.Claims)+1)
+		for k, v := range record.Claims {
+			context[k] = v
+		}
+		context[InnerAuthType] = AuthJWTSession
+		sessionPtr = s.insertSession(&sessionImpl{id: record.SessionID, context: context, observer: map[string]Observer{}})
+		if s.sessionObserver != nil {
+			sessionPtr.BindObserver(s.sessionObserver)
+		}
+	} else {
+		sessionPtr.refresh()
+		s.persistSession(sessionPtr)
+	}
+	if sessionPtr.isFinal() {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	pair, err := s.issueTokenPair(sessionPtr)
+	if err == nil {
+		s.audit(AuditRefreshed, sessionPtr.id, sessionPtr, "")
+	}
+	return pair, err
+}
+
+// revokeExpireAt 吊销记录需要保留到相关 token 全部过期
+func (s *sessionRegistryImpl) revokeExpireAt(recordExpireAt int64) int64 {
+	return max(recordExpireAt, time.Now().Add(max(GetSessionTimeOutValue(), s.refreshTokenTTL)).UTC().UnixMilli())
+}
+
+func (s *sessionRegistryImpl) revokeSession(sessionID string) error {
+	return s.revokeSessionWithReason(sessionID, auditReasonRevoked)
+}
+
+func (s *sessionRegistryImpl) revokeSessionWithReason(sessionID, reason string) error {
+	if sessionID == "" {
+		return fmt.Errorf("session id is empty")
+	}
+
+	var recordExpireAt int64
+	var auditPtr *sessionImpl
+	if record, err := s.sessionStore.Load(sessionID); err == nil && record != nil {
+		recordExpireAt = record.ExpireAt
+		auditPtr = &sessionImpl{id: record.ID, context: record.Context}
+	}
+
+	if err := s.tokenStore.Revoke(revokeSessionPrefix+sessionID, time.Now().UTC().UnixMilli(), s.revokeExpireAt(recordExpireAt)); err != nil {
+		return err
+	}
+	if err := s.tokenStore.DeleteRefreshTokens(sessionID); err != nil {
+		slog.Warn("delete refresh tokens failed", "session", sessionID, "error", err)
+	}
+
+	s.registryLock.RLock()
+	sessionPtr := s.sessionMap[sessionID]
+	s.registryLock.RUnlock()
+	s.removeSession(sessionID)
+	if sessionPtr != nil {
+		auditPtr = sessionPtr
+	}
+	s.audit(AuditTerminated, sessionID, auditPtr, reason)
+	if sessionPtr != nil {
+		go sessionPtr.terminate()
+	}
+	return nil
+}
+
+func (s *sessionRegistryImpl) revokeUserSessions(userID string) (int, error) {
+	if s.userClaim == "" {
+		return 0, ErrUserClaimNotConfigured
+	}
+	if userID == "" {
+		return 0, fmt.Errorf("user id is empty")
+	}
+
+	sessionIDs := []string{}
+	var maxExpireAt int64
+	err := s.sessionStore.Range(func(record *SessionRecord) bool {
+		if userIDOf(record.Context, s.userClaim) == userID {
+			sessionIDs = append(sessionIDs, record.ID)
+			maxExpireAt = max(maxExpireAt, record.ExpireAt)
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// 用户级吊销记录覆盖未保存在会话存储中的 token
+	err = s.tokenStore.Revoke(revokeUserPrefix+userID, time.Now().UTC().UnixMilli(), s.revokeExpireAt(maxExpireAt))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, sessionID := range sessionIDs {
+		if err = s.revokeSession(sessionID); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(sessionIDs), nil
+}
+
+// isRevoked 检查会话、token 和用户三个维度的吊销记录，issuedAt 不晚于吊销时间的 token 视为已吊销
+func (s *sessionRegistryImpl) isRevoked(sessionID, tokenID, userID string, issuedAt int64) (bool, error) {
+	keys := []string{revokeSessionPrefix + sessionID}
+	if tokenID != "" {
+		keys = append(keys, revokeTokenPrefix+tokenID)
+	}
+	if userID != "" {
+		keys = append(keys, revokeUserPrefix+userID)
+	}
+
+	for _, key := range keys {
+		revokedAt, ok, err := s.tokenStore.RevokedAt(key)
+		if err != nil {
+			return false, err
+		}
+		if ok && issuedAt <= revokedAt {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// checkRevoked 校验请求携带的 token 未被吊销，吊销列表不可用时拒绝
+func (s *sessionRegistryImpl) checkRevoked(sessionPtr *sessionImpl) bool {
+	sessionPtr.mu.RLock()
+	tokenID, _ := sessionPtr.context[InnerTokenID].(string)
+	issuedAt, _ := sessionPtr.getInt(innerIssuedAt)
+	userID := userIDOf(sessionPtr.context, s.userClaim)
+	sessionPtr.mu.RUnlock()
+
+	revoked, err := s.isRevoked(sessionPtr.id, tokenID, userID, issuedAt)
+	if err != nil {
+		slog.Warn("check token revocation failed", "session", sessionPtr.id, "error", err)
+		return true
+	}
+	return revoked
+}
@@ -0,0 +1,167 @@
+package session
+
+import (
+	"errors"
+	"maps"
+	"sync"
+	"time"
+)
+
+const tokenStorePurgeInterval = time.Minute
+
+var errInvalidRefreshTokenRecord = errors.New("invalid refresh token record")
+
+// RefreshTokenRecord refresh token 记录，只保存 token 的摘要
+type RefreshTokenRecord struct {
+	TokenHash string `json:"tokenHash"`
+	SessionID string `json:"sessionID"`
+	UserID    string `json:"userID"`
+	// Claims 签发时的会话声明，会话数据过期后刷新时用于重建会话
+	Claims   map[string]any `json:"claims"`
+	IssuedAt int64          `json:"issuedAt"`
+	ExpireAt int64          `json:"expireAt"`
+	Used     bool           `json:"used"`
+}
+
+func (s *RefreshTokenRecord) clone() *RefreshTokenRecord {
+	ret := *s
+	ret.Claims = maps.Clone(s.Claims)
+	return &ret
+}
+
+// TokenStore 保存 refresh token 和吊销列表，多副本部署时需要使用共享的实现
+type TokenStore interface {
+	SaveRefreshToken(record *RefreshTokenRecord) error
+	// LoadRefreshToken 不存在或已过期时返回 nil, nil
+	LoadRefreshToken(tokenHash string) (*RefreshTokenRecord, error)
+	// ConsumeRefreshToken 原子地将 refresh token 标记为已使用，已被使用过时返回 false
+	ConsumeRefreshToken(tokenHash string) (bool, error)
+	// DeleteRefreshTokens 删除会话的全部 refresh token
+	DeleteRefreshTokens(sessionID string) error
+	// Revoke 记录吊销，revokedAt 之前签发的 token 失效，记录保留到 expireAt
+	Revoke(key string, revokedAt, expireAt int64) error
+	// RevokedAt 返回吊销时间，未吊销时返回 false
+	RevokedAt(key string) (int64, bool, error)
+	Close() error
+}
+
+type revokedEntry struct {
+	revokedAt int64
+	expireAt  int64
+}
+
+// memoryTokenStore 进程内 TokenStore，过期记录在写入时按周期清理
+type memoryTokenStore struct {
+	mu        sync.Mutex
+	refresh   map[string]*RefreshTokenRecord
+	revoked   map[string]revokedEntry
+	lastPurge time.Time
+}
+
+// NewMemoryTokenStore 创建进程内 TokenStore
+func NewMemoryTokenStore() TokenStore {
+	return &memoryTokenStore{
+		refresh: map[string]*RefreshTokenRecord{},
+		revoked: map[string]revokedEntry{},
+	}
+}
+
+func (s *memoryTokenStore) SaveRefreshToken(record *RefreshTokenRecord) error {
+	if record == nil || record.TokenHash == "" || record.SessionID == "" {
+		return errInvalidRefreshTokenRecord
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purge()
+	s.refresh[record.TokenHash] = record.clone()
+	return nil
+}
+
+func (s *memoryTokenStore) LoadRefreshToken(tokenHash string) (*RefreshTokenRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.refresh[tokenHash]
+	if !ok || record.ExpireAt < time.Now().UTC().UnixMilli() {
+		return nil, nil
+	}
+
+	return record.clone(), nil
+}
+
+func (s *memoryTokenStore) ConsumeRefreshToken(tokenHash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.refresh[tokenHash]
+	if !ok || record.Used {
+		return false, nil
+	}
+
+	record.Used = true
+	return true, nil
+}
+
+func (s *memoryTokenStore) DeleteRefreshTokens(sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, v := range s.refresh {
+		if v.SessionID == sessionID {
+			delete(s.refresh, k)
+		}
+	}
+	return nil
+}
+
+func (s *memoryTokenStore) Revoke(key string, revokedAt, expireAt int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purge()
+	if cur, ok := s.revoked[key]; ok {
+		revokedAt = max(revokedAt, cur.revokedAt)
+		expireAt = max(expireAt, cur.expireAt)
+	}
+	s.revoked[key] = revokedEntry{revokedAt: revokedAt, expireAt: expireAt}
+	return nil
+}
+
+func (s *memoryTokenStore) RevokedAt(key string) (int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.revoked[key]
+	if !ok || entry.expireAt < time.Now().UTC().UnixMilli() {
+		return 0, false, nil
+	}
+
+	return entry.revokedAt, true, nil
+}
+
+func (s *memoryTokenStore) Close() error {
+	return nil
+}
+
+// purge 清理过期记录，调用前必须持有锁
+func (s *memoryTokenStore) purge() {
+	now := time.Now()
+	if now.Sub(s.lastPurge) < tokenStorePurgeInterval {
+		return
+	}
+	s.lastPurge = now
+
+	nowTime := now.UTC().UnixMilli()
+	for k, v := range s.refresh {
+		if v.ExpireAt < nowTime {
+			delete(s.refresh, k)
+		}
+	}
+	for k, v := range s.revoked {
+		if v.expireAt < nowTime {
+			delete(s.revoked, k)
+		}
+	}
+}
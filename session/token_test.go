@@ -0,0 +1,144 @@
+package session
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTokenTestSession(registry Registry, sessionID string, userID float64) *sessionImpl {
+	impl := registry.(*sessionRegistryImpl)
+	return impl.insertSession(&sessionImpl{
+		id: sessionID,
+		context: map[string]any{
+			"X-Mp-Auth-Entity": map[string]any{"id": userID, "eName": "demo"},
+		},
+		observer: map[string]Observer{},
+	})
+}
+
+func lookupByToken(registry Registry, token string) Session {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	req.Header.Set(Authorization, "Bearer "+token)
+	return LookupSession(registry, req)
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	registry := NewRegistryWithOptions(nil)
+	defer registry.Release()
+
+	sessionPtr := newTokenTestSession(registry, "refresh-session", 1)
+	pair, err := IssueTokenPair(registry, sessionPtr)
+	assert.Nil(t, err)
+	assert.NotNil(t, lookupByToken(registry, pair.AccessToken))
+
+	raw, decodeErr := base64.RawURLEncoding.DecodeString(pair.RefreshToken)
+	assert.Nil(t, decodeErr)
+	assert.Len(t, raw, refreshTokenBytes)
+
+	nextPair, err := RefreshTokenPair(registry, pair.RefreshToken)
+	assert.Nil(t, err)
+	assert.NotEqual(t, pair.RefreshToken, nextPair.RefreshToken)
+
+	curSession := lookupByToken(registry, nextPair.AccessToken)
+	if assert.NotNil(t, curSession) {
+		assert.Equal(t, "refresh-session", curSession.ID())
+	}
+
+	_, err = RefreshTokenPair(registry, "unknown")
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	registry := NewRegistryWithOptions(nil)
+	defer registry.Release()
+
+	observer := &testSessionObserver{id: "observer", statusCh: make(chan Status, 1)}
+	sessionPtr := newTokenTestSession(registry, "reuse-session", 1)
+	sessionPtr.BindObserver(observer)
+
+	pair, _ := IssueTokenPair(registry, sessionPtr)
+	nextPair, err := RefreshTokenPair(registry, pair.RefreshToken)
+	assert.Nil(t, err)
+
+	_, err = RefreshTokenPair(registry, pair.RefreshToken)
+	assert.ErrorIs(t, err, ErrRefreshTokenReused)
+
+	// 整个会话被吊销，包括最新签发的 token
+	_, err = RefreshTokenPair(registry, nextPair.RefreshToken)
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	assert.Nil(t, lookupByToken(registry, nextPair.AccessToken))
+
+	select {
+	case status := <-observer.statusCh:
+		assert.EqualValues(t, StatusTerminate, status)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for terminate notification")
+	}
+}
+
+func TestRefreshTokenRebuildsExpiredSession(t *testing.T) {
+	registry := NewRegistryWithOptions(nil)
+	defer registry.Release()
+
+	sessionPtr := newTokenTestSession(registry, "rebuild-session", 1)
+	pair, _ := IssueTokenPair(registry, sessionPtr)
+	registry.(*sessionRegistryImpl).removeSession(sessionPtr.id)
+
+	nextPair, err := RefreshTokenPair(registry, pair.RefreshToken)
+	assert.Nil(t, err)
+	curSession := lookupByToken(registry, nextPair.AccessToken)
+	if assert.NotNil(t, curSession) {
+		_, ok := curSession.GetOption("X-Mp-Auth-Entity")
+		assert.True(t, ok)
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	registry := NewRegistryWithOptions(nil)
+	defer registry.Release()
+
+	sessionPtr := newTokenTestSession(registry, "revoke-token", 1)
+	firstToken, _ := sessionPtr.Signature()
+	secondToken, _ := sessionPtr.Signature()
+
+	curSession := lookupByToken(registry, firstToken)
+	if !assert.NotNil(t, curSession) {
+		return
+	}
+	tokenID, ok := curSession.GetString(InnerTokenID)
+	assert.True(t, ok)
+	assert.Nil(t, RevokeToken(registry, tokenID, time.Now().Add(time.Hour).UnixMilli()))
+
+	assert.Nil(t, lookupByToken(registry, firstToken))
+	assert.NotNil(t, lookupByToken(registry, secondToken))
+}
+
+func TestRevokeUserSessions(t *testing.T) {
+	registry := NewRegistryWithOptions(nil, WithUserClaim("X-Mp-Auth-Entity"))
+	defer registry.Release()
+
+	firstSession := newTokenTestSession(registry, "user-session-1", 7)
+	secondSession := newTokenTestSession(registry, "user-session-2", 7)
+	otherSession := newTokenTestSession(registry, "other-session", 8)
+	firstPair, _ := IssueTokenPair(registry, firstSession)
+	secondToken, _ := secondSession.Signature()
+	otherToken, _ := otherSession.Signature()
+
+	count, err := RevokeUserSessions(registry, "7")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+
+	assert.Nil(t, lookupByToken(registry, firstPair.AccessToken))
+	assert.Nil(t, lookupByToken(registry, secondToken))
+	assert.NotNil(t, lookupByToken(registry, otherToken))
+	_, err = RefreshTokenPair(registry, firstPair.RefreshToken)
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+	_, err = RevokeUserSessions(NewRegistryWithOptions(nil), "7")
+	assert.ErrorIs(t, err, ErrUserClaimNotConfigured)
+}
@@ -3,6 +3,9 @@ package task
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -23,17 +26,86 @@ func (s *routineTask) Run() {
 	s.funcPtr()
 }
 
+// ContextTask 支持取消的任务对象，Shutdown 宽限期结束后 ctx 会被取消。
+// 同时实现 Task 和 ContextTask 的任务总是通过 RunContext 执行。
+type ContextTask interface {
+	RunContext(ctx context.Context)
+}
+
+type contextRoutineTask struct {
+	funcPtr func(ctx context.Context)
+}
+
+func (s *contextRoutineTask) Run() {
+	s.funcPtr(context.Background())
+}
+
+func (s *contextRoutineTask) RunContext(ctx context.Context) {
+	s.funcPtr(ctx)
+}
+
+type contextTaskAdapter struct {
+	rawTask ContextTask
+}
+
+func (s *contextTaskAdapter) Run() {
+	s.rawTask.RunContext(context.Background())
+}
+
+func (s *contextTaskAdapter) RunContext(ctx context.Context) {
+	s.rawTask.RunContext(ctx)
+}
+
+func runTask(ctx context.Context, task Task) {
+	if contextTask, ok := task.(ContextTask); ok {
+		contextTask.RunContext(ctx)
+		return
+	}
+
+	task.Run()
+}
+
+// AbandonedTask Shutdown 宽限期结束时仍未完成的任务
+type AbandonedTask struct {
+	Name string
+	// Started 为 false 表示任务仍在队列中，强制取消后不会再执行
+	Started bool
+}
+
+// DrainResult 描述一次排空过程的结果
+type DrainResult struct {
+	// Drained 为 true 表示宽限期内全部任务已完成
+	Drained   bool
+	Abandoned []AbandonedTask
+}
+
 type BackgroundRoutine interface {
 	AsyncTask(task Task) error
+	AsyncContextTask(task ContextTask) error
+	AsyncContextFunction(function func(ctx context.Context)) error
 	SyncTask(task Task) error
 	SyncTaskWithTimeOut(task Task, timeout time.Duration) error
 	AsyncFunction(function func()) error
 	SyncFunction(function func()) error
 	SyncFunctionWithTimeOut(function func(), timeout time.Duration) error
-	Timer(ctx context.Context, task Task, intervalValue time.Duration, offsetValue time.Duration) error
+	Timer(ctx context.Context, task Task, intervalValue time.Duration, offsetValue time.Duration, opts ...TimerOption) error
 	Shutdown(ctx context.Context) bool
+	Drain(ctx context.Context) DrainResult
 }
 
+// RoutineMonitor 查询后台任务的执行统计和最近失败记录，NewBackgroundRoutine 返回的实例均实现该接口：
+//
+//	if monitor, ok := routine.(task.RoutineMonitor); ok {
+//		failures := monitor.RecentFailures()
+//	}
+type RoutineMonitor interface {
+	Stats() execute.Stats
+	// RecentFailures 最近 panic 的任务记录，按时间从新到旧排列
+	RecentFailures() []execute.TaskFailure
+}
+
+var _ RoutineMonitor = (*backgroundRoutine)(nil)
+
 type syncTask struct {
 	resultChannel chan bool
 	rawTask       Task
@@ -41,8 +113,15 @@ type syncTask struct {
 }
 
 func (s *syncTask) Run() {
-	s.rawTask.Run()
+	s.RunContext(context.Background())
+}
+
+func (s *syncTask) RunContext(ctx context.Context) {
+	runTask(ctx, s.rawTask)
+	s.finish()
+}
 
+func (s *syncTask) finish() {
 	if !s.timedOut.Load() {
 		s.resultChannel <- true
 	}
@@ -61,7 +140,15 @@ func (s *syncTask) Wait(timeout time.Duration) {
 	}
 }
 
-type taskChannel chan Task
+// pendingTask 已提交但尚未完成的任务
+type pendingTask struct {
+	id      uint64
+	name    string
+	task    Task
+	started atomic.Bool
+}
+
+type taskChannel chan *pendingTask
 
 // backgroundRoutine backGround routine
 type backgroundRoutine struct {
@@ -72,14 +159,26 @@ type backgroundRoutine struct {
 	closed      bool
 	closeOnce   sync.Once
 	loopDone    chan struct{}
+
+	// taskCtx 传递给 ContextTask，Shutdown 宽限期结束后取消
+	taskCtx    context.Context
+	taskCancel context.CancelFunc
+
+	pendingMu sync.Mutex
+	pending   map[uint64]*pendingTask
+	pendingID atomic.Uint64
 }
 
 // NewBackgroundRoutine new Background routine
 func NewBackgroundRoutine(capacitySize int) BackgroundRoutine {
+	taskCtx, taskCancel := context.WithCancel(context.Background())
 	bg := &backgroundRoutine{
 		Execute:     execute.NewExecute(capacitySize),
 		taskChannel: make(taskChannel, capacitySize),
 		loopDone:    make(chan struct{}),
+		taskCtx:     taskCtx,
+		taskCancel:  taskCancel,
+		pending:     map[uint64]*pendingTask{},
 	}
 
 	bg.run()
@@ -94,16 +193,107 @@ func (s *backgroundRoutine) run() {
 func (s *backgroundRoutine) loop() {
 	defer close(s.loopDone)
 	for task := range s.taskChannel {
-		s.Run(func() {
-			task.Run()
+		s.RunTask(task.name, func() {
+			s.runPending(task)
 		})
 	}
 }
 
+func (s *backgroundRoutine) runPending(task *pendingTask) {
+	defer s.removePending(task.id)
+
+	if s.taskCtx.Err() != nil {
+		// 已强制取消，不再执行排队中的任务，但需要释放同步等待方
+		if st, ok := task.task.(*syncTask); ok {
+			st.finish()
+		}
+		return
+	}
+
+	task.started.Store(true)
+	runTask(s.taskCtx, task.task)
+}
+
+func (s *backgroundRoutine) removePending(id uint64) {
+	s.pendingMu.Lock()
+	delete(s.pending, id)
+	s.pendingMu.Unlock()
+}
+
+func (s *backgroundRoutine) abandonedTasks() []AbandonedTask {
+	s.pendingMu.Lock()
+	defer s.pendingMu.Unlock()
+
+	tasks := make([]*pendingTask, 0, len(s.pending))
+	for _, task := range s.pending {
+		tasks = append(tasks, task)
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].id < tasks[j].id
+	})
+
+	ret := make([]AbandonedTask, 0, len(tasks))
+	for _, task := range tasks {
+		ret = append(ret, AbandonedTask{Name: task.name, Started: task.started.Load()})
+	}
+
+	return ret
+}
+
+// NamedTask 可选接口，实现后任务名称会用于 execute 的回调和失败记录
+type NamedTask interface {
+	Name() string
+}
+
+func taskName(task Task) string {
+	switch val := task.(type) {
+	case NamedTask:
+		return val.Name()
+	case *syncTask:
+		return taskName(val.rawTask)
+	case *contextTaskAdapter:
+		if named, ok := val.rawTask.(NamedTask); ok {
+			return named.Name()
+		}
+		return fmt.Sprintf("%T", val.rawTask)
+	case *routineTask:
+		return funcName(val.funcPtr, task)
+	case *contextRoutineTask:
+		return funcName(val.funcPtr, task)
+	}
+
+	return fmt.Sprintf("%T", task)
+}
+
+func funcName(funcPtr any, task Task) string {
+	if fn := runtime.FuncForPC(reflect.ValueOf(funcPtr).Pointer()); fn != nil {
+		return fn.Name()
+	}
+
+	return fmt.Sprintf("%T", task)
+}
+
 func (s *backgroundRoutine) AsyncTask(task Task) error {
 	return s.submitTask(task)
 }
 
+func (s *backgroundRoutine) AsyncContextTask(task ContextTask) error {
+	if task == nil {
+		return fmt.Errorf("task is nil")
+	}
+	if val, ok := task.(Task); ok {
+		return s.submitTask(val)
+	}
+	return s.submitTask(&contextTaskAdapter{rawTask: task})
+}
+
+func (s *backgroundRoutine) AsyncContextFunction(function func(ctx context.Context)) error {
+	if function == nil {
+		return fmt.Errorf("function is nil")
+	}
+	return s.submitTask(&contextRoutineTask{funcPtr: function})
+}
+
 func (s *backgroundRoutine) SyncTask(task Task) error {
 	_ = s.SyncTaskWithTimeOut(task, -1)
 	return nil
@@ -142,7 +332,7 @@ func (s *backgroundRoutine) SyncFunctionWithTimeOut(function func(), timeout tim
 
 const onDayDuration = 24 * time.Hour
 
-func (s *backgroundRoutine) Timer(ctx context.Context, task Task, intervalValue time.Duration, offsetValue time.Duration) error {
+func (s *backgroundRoutine) Timer(ctx context.Context, task Task, intervalValue time.Duration, offsetValue time.Duration, opts ...TimerOption) error {
 	if ctx == nil {
 		return fmt.Errorf("context is nil")
 	}
@@ -153,6 +343,28 @@ func (s *backgroundRoutine) Timer(ctx context.Context, task Task, intervalValue
 		return fmt.Errorf("intervalValue must be positive")
 	}
 
+	timerOpts := &timerOptions{leaseTTL: intervalValue}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(timerOpts)
+		}
+	}
+	if timerOpts.locker != nil {
+		if timerOpts.leaseKey == "" {
+			return fmt.Errorf("lease key is empty")
+		}
+		if timerOpts.holder == "" {
+			timerOpts.holder = defaultLeaseHolder()
+		}
+		task = &leaseTask{
+			rawTask:  task,
+			locker:   timerOpts.locker,
+			leaseKey: timerOpts.leaseKey,
+			holder:   timerOpts.holder,
+			leaseTTL: timerOpts.leaseTTL,
+		}
+	}
+
 	go func() {
 		curOffset := func() time.Duration {
 			now := time.Now()
@@ -195,6 +407,14 @@ func (s *backgroundRoutine) Timer(ctx context.Context, task Task, intervalValue
 }
 
 func (s *backgroundRoutine) Shutdown(ctx context.Context) bool {
+	return s.Drain(ctx).Drained
+}
+
+// Drain 分阶段关闭后台任务：
+// 1. 停止接收新任务；
+// 2. 在 ctx 结束前等待排队和运行中的任务完成；
+// 3. ctx 结束后取消任务 context，排队中的任务不再执行，并返回未完成的任务列表。
+func (s *backgroundRoutine) Drain(ctx context.Context) DrainResult {
 	if ctx == nil {
 		ctx = context.Background()
 	}
@@ -205,6 +425,17 @@ func (s *backgroundRoutine) Shutdown(ctx context.Context) bool {
 		s.submitMu.Unlock()
 	})
 
+	if s.waitDrained(ctx) {
+		s.taskCancel()
+		return DrainResult{Drained: true, Abandoned: []AbandonedTask{}}
+	}
+
+	abandoned := s.abandonedTasks()
+	s.taskCancel()
+	return DrainResult{Drained: false, Abandoned: abandoned}
+}
+
+func (s *backgroundRoutine) waitDrained(ctx context.Context) bool {
 	select {
 	case <-s.loopDone:
 	case <-ctx.Done():
@@ -225,6 +456,15 @@ func (s *backgroundRoutine) submitTask(task Task) error {
 		return fmt.Errorf("background routine is closed")
 	}
 
-	s.taskChannel <- task
+	pending := &pendingTask{
+		id:   s.pendingID.Add(1),
+		name: taskName(task),
+		task: task,
+	}
+	s.pendingMu.Lock()
+	s.pending[pending.id] = pending
+	s.pendingMu.Unlock()
+
+	s.taskChannel <- pending
 	return nil
 }
@@ -77,3 +77,131 @@ func TestBackgroundRoutineShutdownRejectsNewTasks(t *testing.T) {
 		t.Fatalf("expected submitting task after shutdown to fail")
 	}
 }
+
+type namedPanicTask struct{}
+
+func (s *namedPanicTask) Name() string { return "daily-cleanup" }
+
+func (s *namedPanicTask) Run() {
+	panic("cleanup failed")
+}
+
+func TestBackgroundRoutineRecordsNamedTaskFailure(t *testing.T) {
+	taskRoutine := NewBackgroundRoutine(8)
+	defer taskRoutine.Shutdown(context.Background())
+
+	_ = taskRoutine.SyncTaskWithTimeOut(&namedPanicTask{}, 200*time.Millisecond)
+
+	monitor, ok := taskRoutine.(RoutineMonitor)
+	if !ok {
+		t.Fatalf("expected background routine to implement RoutineMonitor")
+	}
+	failures := monitor.RecentFailures()
+	if len(failures) != 1 || failures[0].Name != "daily-cleanup" {
+		t.Fatalf("expected named task failure, got %+v", failures)
+	}
+	if stats := monitor.Stats(); stats.Panicked != 1 {
+		t.Fatalf("expected one panicked task, got %+v", stats)
+	}
+}
+
+func TestBackgroundRoutineDrainCancelsRunningContextTasks(t *testing.T) {
+	taskRoutine := NewBackgroundRoutine(8)
+
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	err := taskRoutine.AsyncContextFunction(func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+	})
+	if err != nil {
+		t.Fatalf("expected context task submit to succeed: %v", err)
+	}
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	result := taskRoutine.Drain(ctx)
+	if result.Drained {
+		t.Fatalf("expected drain to report unfinished tasks")
+	}
+	if len(result.Abandoned) != 1 || !result.Abandoned[0].Started {
+		t.Fatalf("expected one started abandoned task, got %+v", result.Abandoned)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(200 * time.Millisecond):
+		t.Fatalf("expected running task context to be cancelled")
+	}
+}
+
+func TestBackgroundRoutineDrainSkipsQueuedTasksAfterGracePeriod(t *testing.T) {
+	taskRoutine := NewBackgroundRoutine(2)
+
+	release := make(chan struct{})
+	defer close(release)
+	_ = taskRoutine.AsyncFunction(func() {
+		<-release
+	})
+
+	var queuedRan atomic.Bool
+	_ = taskRoutine.AsyncTask(&namedRunTask{name: "queued", ran: &queuedRan})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	result := taskRoutine.Drain(ctx)
+	if result.Drained {
+		t.Fatalf("expected drain to time out")
+	}
+
+	var found bool
+	for _, val := range result.Abandoned {
+		if val.Name == "queued" {
+			found = true
+			if val.Started {
+				t.Fatalf("expected queued task to be reported as not started")
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("expected queued task to be reported, got %+v", result.Abandoned)
+	}
+
+	release <- struct{}{}
+	time.Sleep(50 * time.Millisecond)
+	if queuedRan.Load() {
+		t.Fatalf("expected queued task to be skipped after forced cancel")
+	}
+}
+
+func TestBackgroundRoutineDrainReportsDrained(t *testing.T) {
+	taskRoutine := NewBackgroundRoutine(2)
+
+	var ran atomic.Bool
+	_ = taskRoutine.AsyncContextTask(&namedRunTask{name: "quick", ran: &ran})
+
+	result := taskRoutine.Drain(context.Background())
+	if !result.Drained || len(result.Abandoned) != 0 {
+		t.Fatalf("expected clean drain, got %+v", result)
+	}
+	if !ran.Load() {
+		t.Fatalf("expected task to run before drain completes")
+	}
+}
+
+type namedRunTask struct {
+	name string
+	ran  *atomic.Bool
+}
+
+func (s *namedRunTask) Name() string { return s.name }
+
+func (s *namedRunTask) Run() {
+	s.ran.Store(true)
+}
+
+func (s *namedRunTask) RunContext(_ context.Context) {
+	s.ran.Store(true)
+}
@@ -0,0 +1,96 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/muidea/magicCommon/foundation/util"
+)
+
+// Locker 租约抽象，用于在多副本之间保证同一任务只在一个副本上执行。
+// foundation/dao.LeaseLocker 和 FileLocker 都实现了该接口。
+type Locker interface {
+	// TryAcquire 尝试以 holder 身份获取 key 的租约，获取成功或续期成功时返回 true
+	TryAcquire(ctx context.Context, key, holder string, ttl time.Duration) (bool, error)
+	// Release 释放 holder 持有的 key 租约
+	Release(ctx context.Context, key, holder string) error
+}
+
+// TimerOption Timer 配置项
+type TimerOption func(*timerOptions)
+
+type timerOptions struct {
+	locker   Locker
+	leaseKey string
+	holder   string
+	leaseTTL time.Duration
+}
+
+// WithSingleRunner 多副本部署时，每次触发前先获取 key 对应的租约，只有持有租约的副本执行任务。
+// 租约在执行后不会主动释放，而是在 TTL 到期后失效，避免触发时间略有偏差的其他副本重复执行。
+func WithSingleRunner(locker Locker, key string) TimerOption {
+	return func(o *timerOptions) {
+		o.locker = locker
+		o.leaseKey = key
+	}
+}
+
+// WithLeaseHolder 指定租约持有者标识，默认使用 hostname、pid 和随机串组合
+func WithLeaseHolder(holder string) TimerOption {
+	return func(o *timerOptions) {
+		if holder != "" {
+			o.holder = holder
+		}
+	}
+}
+
+// WithLeaseTTL 指定租约有效期，默认与 Timer 周期相同
+func WithLeaseTTL(ttl time.Duration) TimerOption {
+	return func(o *timerOptions) {
+		if ttl > 0 {
+			o.leaseTTL = ttl
+		}
+	}
+}
+
+func defaultLeaseHolder() string {
+	hostName, err := os.Hostname()
+	if err != nil || hostName == "" {
+		hostName = "unknown"
+	}
+
+	return fmt.Sprintf("%s-%d-%s", hostName, os.Getpid(), util.NewUUID()[:8])
+}
+
+// leaseTask 执行前获取租约的任务包装
+type leaseTask struct {
+	rawTask  Task
+	locker   Locker
+	leaseKey string
+	holder   string
+	leaseTTL time.Duration
+}
+
+func (s *leaseTask) Name() string {
+	return taskName(s.rawTask)
+}
+
+func (s *leaseTask) Run() {
+	s.RunContext(context.Background())
+}
+
+func (s *leaseTask) RunContext(ctx context.Context) {
+	acquired, err := s.locker.TryAcquire(ctx, s.leaseKey, s.holder, s.leaseTTL)
+	if err != nil {
+		slog.Warn("acquire timer lease failed", "key", s.leaseKey, "holder", s.holder, "error", err)
+		return
+	}
+	if !acquired {
+		return
+	}
+
+	runTask(ctx, s.rawTask)
+}
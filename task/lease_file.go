@@ -0,0 +1,170 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+const (
+	fileLockRetryInterval = 10 * time.Millisecond
+	fileLockStaleTimeout  = 30 * time.Second
+)
+
+var leaseKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+type fileLease struct {
+	Holder   string `json:"holder"`
+	ExpireAt int64  `json:"expireAt"`
+}
+
+// FileLocker 基于共享目录的租约实现，适用于多个进程共享同一文件系统的场景。
+// 每个 key 对应目录下的一个租约文件，读写租约时通过独占创建 .lock 文件互斥。
+type FileLocker struct {
+	dir string
+}
+
+// NewFileLocker 创建基于目录的租约，目录不存在时自动创建
+func NewFileLocker(dir string) (*FileLocker, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("lease directory is empty")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &FileLocker{dir: dir}, nil
+}
+
+// TryAcquire 尝试以 holder 身份获取 key 的租约，获取成功或续期成功时返回 true
+func (s *FileLocker) TryAcquire(ctx context.Context, key, holder string, ttl time.Duration) (bool, error) {
+	if err := validateLeaseArgs(key, holder); err != nil {
+		return false, err
+	}
+	if ttl <= 0 {
+		return false, fmt.Errorf("lease ttl must be positive")
+	}
+
+	unlock, err := s.lock(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	now := time.Now()
+	current, err := s.read(key)
+	if err != nil {
+		return false, err
+	}
+	if current != nil && current.Holder != holder && current.ExpireAt >= now.UnixMilli() {
+		return false, nil
+	}
+
+	return true, s.write(key, &fileLease{Holder: holder, ExpireAt: now.Add(ttl).UnixMilli()})
+}
+
+// Release 释放 holder 持有的 key 租约，未持有时不做任何处理
+func (s *FileLocker) Release(ctx context.Context, key, holder string) error {
+	if err := validateLeaseArgs(key, holder); err != nil {
+		return err
+	}
+
+	unlock, err := s.lock(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, err := s.read(key)
+	if err != nil || current == nil || current.Holder != holder {
+		return err
+	}
+
+	err = os.Remove(s.leasePath(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func validateLeaseArgs(key, holder string) error {
+	if !leaseKeyPattern.MatchString(key) {
+		return fmt.Errorf("illegal lease key: %s", key)
+	}
+	if holder == "" {
+		return fmt.Errorf("lease holder is empty")
+	}
+
+	return nil
+}
+
+func (s *FileLocker) leasePath(key string) string {
+	return filepath.Join(s.dir, key+".lease")
+}
+
+// lock 通过独占创建 .lock 文件实现跨进程互斥，超过 fileLockStaleTimeout 的锁文件视为残留并清理
+func (s *FileLocker) lock(ctx context.Context, key string) (func(), error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	lockPath := filepath.Join(s.dir, key+".lock")
+	for {
+		fileHandle, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			_ = fileHandle.Close()
+			return func() { _ = os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > fileLockStaleTimeout {
+			_ = os.Remove(lockPath)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(fileLockRetryInterval):
+		}
+	}
+}
+
+func (s *FileLocker) read(key string) (*fileLease, error) {
+	content, err := os.ReadFile(s.leasePath(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	lease := &fileLease{}
+	if err := json.Unmarshal(content, lease); err != nil {
+		// 租约文件损坏时视为不存在，由本次写入覆盖
+		return nil, nil
+	}
+
+	return lease, nil
+}
+
+func (s *FileLocker) write(key string, lease *fileLease) error {
+	content, err := json.Marshal(lease)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := s.leasePath(key) + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, s.leasePath(key))
+}
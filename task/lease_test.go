@@ -0,0 +1,134 @@
+package task
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFileLockerAcquireAndRelease(t *testing.T) {
+	locker, err := NewFileLocker(t.TempDir())
+	if err != nil {
+		t.Fatalf("create file locker failed: %v", err)
+	}
+
+	ctx := context.Background()
+	ok, err := locker.TryAcquire(ctx, "cleanup", "replica-a", time.Second)
+	if err != nil || !ok {
+		t.Fatalf("expected replica-a to acquire lease, ok:%v err:%v", ok, err)
+	}
+
+	ok, err = locker.TryAcquire(ctx, "cleanup", "replica-b", time.Second)
+	if err != nil || ok {
+		t.Fatalf("expected replica-b to be rejected, ok:%v err:%v", ok, err)
+	}
+
+	ok, err = locker.TryAcquire(ctx, "cleanup", "replica-a", time.Second)
+	if err != nil || !ok {
+		t.Fatalf("expected replica-a to renew lease, ok:%v err:%v", ok, err)
+	}
+
+	if err = locker.Release(ctx, "cleanup", "replica-b"); err != nil {
+		t.Fatalf("release by non holder should be ignored: %v", err)
+	}
+	ok, _ = locker.TryAcquire(ctx, "cleanup", "replica-b", time.Second)
+	if ok {
+		t.Fatalf("expected lease to stay with replica-a")
+	}
+
+	if err = locker.Release(ctx, "cleanup", "replica-a"); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	ok, err = locker.TryAcquire(ctx, "cleanup", "replica-b", time.Second)
+	if err != nil || !ok {
+		t.Fatalf("expected replica-b to acquire released lease, ok:%v err:%v", ok, err)
+	}
+}
+
+func TestFileLockerExpiredLeaseCanBeTaken(t *testing.T) {
+	locker, err := NewFileLocker(t.TempDir())
+	if err != nil {
+		t.Fatalf("create file locker failed: %v", err)
+	}
+
+	ctx := context.Background()
+	if ok, _ := locker.TryAcquire(ctx, "cleanup", "replica-a", 20*time.Millisecond); !ok {
+		t.Fatalf("expected replica-a to acquire lease")
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if ok, _ := locker.TryAcquire(ctx, "cleanup", "replica-b", time.Second); !ok {
+		t.Fatalf("expected replica-b to take expired lease")
+	}
+}
+
+func TestFileLockerRejectsIllegalKey(t *testing.T) {
+	locker, err := NewFileLocker(t.TempDir())
+	if err != nil {
+		t.Fatalf("create file locker failed: %v", err)
+	}
+
+	if _, err = locker.TryAcquire(context.Background(), "../escape", "replica-a", time.Second); err == nil {
+		t.Fatalf("expected illegal key to be rejected")
+	}
+}
+
+func TestTimerSingleRunnerRunsOnOneReplica(t *testing.T) {
+	locker, err := NewFileLocker(t.TempDir())
+	if err != nil {
+		t.Fatalf("create file locker failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var runsA, runsB atomic.Int32
+	jobA := func() {
+		runsA.Add(1)
+	}
+	jobB := func() {
+		runsB.Add(1)
+	}
+
+	replicaA := NewBackgroundRoutine(8)
+	defer replicaA.Shutdown(context.Background())
+	replicaB := NewBackgroundRoutine(8)
+	defer replicaB.Shutdown(context.Background())
+
+	// 租约有效期覆盖整个测试，持有者每次触发时续期，另一个副本的每次触发都应被拒绝
+	interval := 100 * time.Millisecond
+	if err = replicaA.Timer(ctx, &routineTask{funcPtr: jobA}, interval, 0, WithSingleRunner(locker, "daily-cleanup"), WithLeaseHolder("replica-a"), WithLeaseTTL(time.Minute)); err != nil {
+		t.Fatalf("setup timer failed: %v", err)
+	}
+	if err = replicaB.Timer(ctx, &routineTask{funcPtr: jobB}, interval, 0, WithSingleRunner(locker, "daily-cleanup"), WithLeaseHolder("replica-b"), WithLeaseTTL(time.Minute)); err != nil {
+		t.Fatalf("setup timer failed: %v", err)
+	}
+
+	time.Sleep(350 * time.Millisecond)
+	cancel()
+
+	countA, countB := runsA.Load(), runsB.Load()
+	if countA > 0 && countB > 0 {
+		t.Fatalf("expected timer to run on a single replica, got replica-a:%d replica-b:%d", countA, countB)
+	}
+	// 持有租约的副本每个周期都执行，续期不能让持有者丢失租约
+	if runs := countA + countB; runs < 2 {
+		t.Fatalf("expected lease holder to run on every tick, got %d runs", runs)
+	}
+}
+
+func TestTimerSingleRunnerRequiresKey(t *testing.T) {
+	locker, err := NewFileLocker(t.TempDir())
+	if err != nil {
+		t.Fatalf("create file locker failed: %v", err)
+	}
+
+	routine := NewBackgroundRoutine(2)
+	defer routine.Shutdown(context.Background())
+
+	err = routine.Timer(context.Background(), &routineTask{funcPtr: func() {}}, time.Second, 0, WithSingleRunner(locker, ""))
+	if err == nil {
+		t.Fatalf("expected empty lease key to be rejected")
+	}
+}